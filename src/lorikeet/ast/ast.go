@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"lorikeet/token"
+	"math/big"
+	"sort"
 	"strings"
 )
 
@@ -58,6 +60,7 @@ func (p *Program) Line() int { return 0 }
 type LetStatement struct {
 	Token token.Token // the token.LET token
 	Name  *Identifier
+	Type  *TypeAnnotation // nil when not annotated
 	Value Expression
 	Mut   bool
 }
@@ -71,6 +74,9 @@ func (ls *LetStatement) String() string {
 
 	out.WriteString(ls.TokenLiteral() + " ")
 	out.WriteString(ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -85,6 +91,35 @@ func (ls *LetStatement) String() string {
 // Line return line number
 func (ls *LetStatement) Line() int { return ls.Token.Line }
 
+// DestructureStatement node, let (a, b) = value;
+type DestructureStatement struct {
+	Token token.Token // the token.LET token
+	Names []*Identifier
+	Value Expression
+	Mut   bool
+}
+
+func (ds *DestructureStatement) statementNode() {}
+
+// TokenLiteral return literal for destructure statement
+func (ds *DestructureStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DestructureStatement) String() string {
+	names := []string{}
+	for _, n := range ds.Names {
+		names = append(names, n.String())
+	}
+
+	value := ""
+	if ds.Value != nil {
+		value = ds.Value.String()
+	}
+
+	return ds.TokenLiteral() + " (" + strings.Join(names, ", ") + ") = " + value + ";"
+}
+
+// Line return line number
+func (ds *DestructureStatement) Line() int { return ds.Token.Line }
+
 // ReturnStatement node
 type ReturnStatement struct {
 	Token       token.Token // the 'return' token
@@ -185,6 +220,79 @@ func (ms *MutStatement) String() string {
 // Line return line number
 func (ms *MutStatement) Line() int { return ms.Token.Line }
 
+// IndexAssignStatement node, a[i] = value;
+type IndexAssignStatement struct {
+	Token  token.Token // the ASSIGN token
+	Target *IndexExpression
+	Value  Expression
+}
+
+func (ias *IndexAssignStatement) statementNode() {}
+
+// TokenLiteral return literal for index assign statement
+func (ias *IndexAssignStatement) TokenLiteral() string { return ias.Token.Literal }
+func (ias *IndexAssignStatement) String() string {
+	value := ""
+	if ias.Value != nil {
+		value = ias.Value.String()
+	}
+
+	return ias.Target.String() + " = " + value + ";"
+}
+
+// Line return line number
+func (ias *IndexAssignStatement) Line() int { return ias.Token.Line }
+
+// EnumStatement node
+type EnumStatement struct {
+	Token    token.Token // the 'enum' token
+	Name     *Identifier
+	Variants []*EnumVariant
+}
+
+// EnumVariant is a single variant of an enum with its field names
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (es *EnumStatement) statementNode() {}
+
+// TokenLiteral return literal for enum statement
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) String() string {
+	var out bytes.Buffer
+
+	variants := []string{}
+	for _, v := range es.Variants {
+		variants = append(variants, v.String())
+	}
+
+	out.WriteString(es.TokenLiteral() + " ")
+	out.WriteString(es.Name.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(variants, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// Line return line number
+func (es *EnumStatement) Line() int { return es.Token.Line }
+
+func (ev *EnumVariant) String() string {
+	if len(ev.Fields) == 0 {
+		return ev.Name.String()
+	}
+
+	fields := []string{}
+	for _, f := range ev.Fields {
+		fields = append(fields, f.String())
+	}
+
+	return ev.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
 // Expressions
 
 // Identifier node
@@ -217,10 +325,12 @@ func (b *Boolean) String() string       { return b.Token.Literal }
 // Line return line number
 func (b *Boolean) Line() int { return b.Token.Line }
 
-// IntegerLiteral node
+// IntegerLiteral node, Big holds the value of literals too large for
+// an int64
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int
 }
 
 func (il *IntegerLiteral) expressionNode() {}
@@ -330,12 +440,82 @@ func (ie *IfExpression) String() string {
 // Line return line number
 func (ie *IfExpression) Line() int { return ie.Token.Line }
 
+// MatchExpression node
+type MatchExpression struct {
+	Token   token.Token // The 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+}
+
+// MatchArm is a single pattern of a match expression, an arm
+// matches either an enum variant, a value or anything when
+// both Variant and Value are nil
+type MatchArm struct {
+	Token    token.Token // the first token of the pattern
+	Variant  *Identifier
+	Bindings []*Identifier // variant fields bound in Body
+	Value    Expression
+	Body     *BlockStatement
+}
+
+func (me *MatchExpression) expressionNode() {}
+
+// TokenLiteral return literal for match expression
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) String() string {
+	var out bytes.Buffer
+
+	arms := []string{}
+	for _, a := range me.Arms {
+		arms = append(arms, a.String())
+	}
+
+	out.WriteString("match")
+	out.WriteString(me.Subject.String())
+	out.WriteString(" { ")
+	out.WriteString(strings.Join(arms, ", "))
+	out.WriteString(" }")
+
+	return out.String()
+}
+
+// Line return line number
+func (me *MatchExpression) Line() int { return me.Token.Line }
+
+func (ma *MatchArm) String() string {
+	var out bytes.Buffer
+
+	switch {
+	case ma.Variant != nil:
+		out.WriteString(ma.Variant.String())
+		if len(ma.Bindings) > 0 {
+			bindings := []string{}
+			for _, b := range ma.Bindings {
+				bindings = append(bindings, b.String())
+			}
+			out.WriteString("(" + strings.Join(bindings, ", ") + ")")
+		}
+	case ma.Value != nil:
+		out.WriteString(ma.Value.String())
+	default:
+		out.WriteString("_")
+	}
+
+	out.WriteString(" => ")
+	out.WriteString(ma.Body.String())
+
+	return out.String()
+}
+
 // FunctionLiteral node
 type FunctionLiteral struct {
-	Token      token.Token // The 'fn' token
-	Parameters []*Identifier
-	Body       *BlockStatement
-	Name       string
+	Token          token.Token // The 'fn' or 'fn*' token
+	Parameters     []*Identifier
+	ParameterTypes []*TypeAnnotation // nil entries when not annotated
+	ReturnType     *TypeAnnotation
+	Body           *BlockStatement
+	Name           string
+	Generator      bool
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -346,8 +526,12 @@ func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
-	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+	for i, p := range fl.Parameters {
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			params = append(params, p.String()+": "+fl.ParameterTypes[i].String())
+		} else {
+			params = append(params, p.String())
+		}
 	}
 
 	out.WriteString(fl.TokenLiteral())
@@ -357,6 +541,9 @@ func (fl *FunctionLiteral) String() string {
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
 	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString("-> " + fl.ReturnType.String() + " ")
+	}
 	out.WriteString(fl.Body.String())
 
 	return out.String()
@@ -365,6 +552,68 @@ func (fl *FunctionLiteral) String() string {
 // Line return line number
 func (fl *FunctionLiteral) Line() int { return fl.Token.Line }
 
+// TypeAnnotation node, Name is a type name, "[]" for arrays, "{}" for
+// hashes or "fn" for functions
+type TypeAnnotation struct {
+	Token    token.Token
+	Name     string
+	Elements []*TypeAnnotation // array element, hash key and value or function parameters
+	Return   *TypeAnnotation   // function return, nil when omitted
+}
+
+// TokenLiteral return literal for type annotation
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) String() string {
+	elements := []string{}
+	for _, e := range ta.Elements {
+		elements = append(elements, e.String())
+	}
+
+	switch ta.Name {
+	case "[]":
+		return "[" + strings.Join(elements, "") + "]"
+	case "{}":
+		return "{" + strings.Join(elements, ": ") + "}"
+	case "fn":
+		out := "fn(" + strings.Join(elements, ", ") + ")"
+		if ta.Return != nil {
+			out += " -> " + ta.Return.String()
+		}
+		return out
+	default:
+		return ta.Name
+	}
+}
+
+// Line return line number
+func (ta *TypeAnnotation) Line() int { return ta.Token.Line }
+
+// YieldExpression node
+type YieldExpression struct {
+	Token token.Token // The 'yield' token
+	Value Expression
+}
+
+func (ye *YieldExpression) expressionNode() {}
+
+// TokenLiteral return literal for yield expression
+func (ye *YieldExpression) TokenLiteral() string { return ye.Token.Literal }
+func (ye *YieldExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ye.TokenLiteral())
+	if ye.Value != nil {
+		out.WriteString(" " + ye.Value.String())
+	}
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// Line return line number
+func (ye *YieldExpression) Line() int { return ye.Token.Line }
+
 // CallExpression node
 type CallExpression struct {
 	Token     token.Token // The '(' token
@@ -395,6 +644,39 @@ func (ce *CallExpression) String() string {
 // Line return line number
 func (ce *CallExpression) Line() int { return ce.Token.Line }
 
+// PartialExpression node is a call with _ placeholder arguments,
+// it evaluates to a function that takes the missing arguments
+type PartialExpression struct {
+	Token token.Token // The '(' token
+	Call  *CallExpression
+}
+
+func (pe *PartialExpression) expressionNode() {}
+
+// TokenLiteral return literal for partial expression
+func (pe *PartialExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PartialExpression) String() string       { return pe.Call.String() }
+
+// Line return line number
+func (pe *PartialExpression) Line() int { return pe.Token.Line }
+
+// Placeholders returns the number of _ arguments
+func (pe *PartialExpression) Placeholders() int {
+	count := 0
+	for _, a := range pe.Call.Arguments {
+		if IsPlaceholder(a) {
+			count++
+		}
+	}
+	return count
+}
+
+// IsPlaceholder reports whether the expression is the _ placeholder
+func IsPlaceholder(exp Expression) bool {
+	ident, ok := exp.(*Identifier)
+	return ok && ident.Value == "_"
+}
+
 // StringLiteral node
 type StringLiteral struct {
 	Token token.Token
@@ -410,6 +692,21 @@ func (sl *StringLiteral) String() string       { return sl.Token.Literal }
 // Line return line number
 func (sl *StringLiteral) Line() int { return sl.Token.Line }
 
+// CharLiteral node
+type CharLiteral struct {
+	Token token.Token
+	Value rune
+}
+
+func (cl *CharLiteral) expressionNode() {}
+
+// TokenLiteral return literal for char
+func (cl *CharLiteral) TokenLiteral() string { return cl.Token.Literal }
+func (cl *CharLiteral) String() string       { return "'" + cl.Token.Literal + "'" }
+
+// Line return line number
+func (cl *CharLiteral) Line() int { return cl.Token.Line }
+
 // ArrayLiteral node
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
@@ -438,11 +735,57 @@ func (al *ArrayLiteral) String() string {
 // Line return line number
 func (al *ArrayLiteral) Line() int { return al.Token.Line }
 
+// TupleLiteral node
+type TupleLiteral struct {
+	Token    token.Token // the '(' token
+	Elements []Expression
+}
+
+func (tl *TupleLiteral) expressionNode() {}
+
+// TokenLiteral return literal for tuple
+func (tl *TupleLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TupleLiteral) String() string {
+	elements := []string{}
+	for _, el := range tl.Elements {
+		elements = append(elements, el.String())
+	}
+	if len(elements) == 1 {
+		return "(" + elements[0] + ",)"
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
+// Line return line number
+func (tl *TupleLiteral) Line() int { return tl.Token.Line }
+
+// SetLiteral node
+type SetLiteral struct {
+	Token    token.Token // the '#{' token
+	Elements []Expression
+}
+
+func (sl *SetLiteral) expressionNode() {}
+
+// TokenLiteral return literal for set
+func (sl *SetLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *SetLiteral) String() string {
+	elements := []string{}
+	for _, el := range sl.Elements {
+		elements = append(elements, el.String())
+	}
+	return "#{" + strings.Join(elements, ", ") + "}"
+}
+
+// Line return line number
+func (sl *SetLiteral) Line() int { return sl.Token.Line }
+
 // IndexExpression node
 type IndexExpression struct {
-	Token token.Token // the [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the [, ?[ or ?. token
+	Left     Expression
+	Index    Expression
+	Optional bool // short-circuits to null when Left is null
 }
 
 func (ie *IndexExpression) expressionNode() {}
@@ -454,6 +797,9 @@ func (ie *IndexExpression) String() string {
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -464,10 +810,65 @@ func (ie *IndexExpression) String() string {
 // Line return line number
 func (ie *IndexExpression) Line() int { return ie.Token.Line }
 
+// SliceExpression node
+type SliceExpression struct {
+	Token    token.Token // the [ or ?[ token
+	Left     Expression
+	Start    Expression // nil when omitted
+	End      Expression // nil when omitted
+	Optional bool       // short-circuits to null when Left is null
+}
+
+func (se *SliceExpression) expressionNode() {}
+
+// TokenLiteral return literal for slice
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	if se.Optional {
+		out.WriteString("?")
+	}
+	out.WriteString("[")
+	if se.Start != nil {
+		out.WriteString(se.Start.String())
+	}
+	out.WriteString(":")
+	if se.End != nil {
+		out.WriteString(se.End.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// Line return line number
+func (se *SliceExpression) Line() int { return se.Token.Line }
+
 // HashLiteral node
 type HashLiteral struct {
 	Token token.Token // the '{' token
 	Pairs map[Expression]Expression
+	Keys  []Expression // keys of Pairs in source order
+}
+
+// OrderedKeys returns the keys in source order, literals built
+// without Keys are sorted by their String
+func (hl *HashLiteral) OrderedKeys() []Expression {
+	if len(hl.Keys) == len(hl.Pairs) {
+		return hl.Keys
+	}
+
+	keys := []Expression{}
+	for k := range hl.Pairs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
 }
 
 func (hl *HashLiteral) expressionNode() {}
@@ -478,8 +879,8 @@ func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for key, value := range hl.Pairs {
-		pairs = append(pairs, key.String()+":"+value.String())
+	for _, key := range hl.OrderedKeys() {
+		pairs = append(pairs, key.String()+":"+hl.Pairs[key].String())
 	}
 
 	out.WriteString("{")
@@ -0,0 +1,84 @@
+package ast
+
+import (
+	"fmt"
+
+	"lorikeet/token"
+)
+
+// LowerPartial rewrites a partial application into an immediately called
+// function literal, so the callee and bound arguments are evaluated once:
+//
+//	add(1, _) => fn(@0, @1) { fn(@2) { @0(@1, @2) } }(add, 1)
+//
+// Identifiers cannot contain @, so the names never clash.
+func LowerPartial(pe *PartialExpression) *CallExpression {
+	line := pe.Token.Line
+	callee := hiddenIdentifier(0, line)
+
+	outer := &FunctionLiteral{Token: fnToken(line), Parameters: []*Identifier{callee}}
+	inner := &FunctionLiteral{Token: fnToken(line)}
+	call := &CallExpression{Token: pe.Token, Function: callee}
+	bound := []Expression{pe.Call.Function}
+
+	for i, a := range pe.Call.Arguments {
+		param := hiddenIdentifier(i+1, line)
+		if IsPlaceholder(a) {
+			inner.Parameters = append(inner.Parameters, param)
+		} else {
+			outer.Parameters = append(outer.Parameters, param)
+			bound = append(bound, a)
+		}
+		call.Arguments = append(call.Arguments, param)
+	}
+
+	inner.Body = blockOf(call, line)
+	outer.Body = blockOf(inner, line)
+
+	return &CallExpression{Token: pe.Token, Function: outer, Arguments: bound}
+}
+
+// LowerCompose rewrites f >> g into a function calling g with the result of f:
+//
+//	f >> g => fn(@0, @1) { fn(@2) { @1(@0(@2)) } }(f, g)
+func LowerCompose(ie *InfixExpression) *CallExpression {
+	line := ie.Token.Line
+	first, second, arg := hiddenIdentifier(0, line), hiddenIdentifier(1, line), hiddenIdentifier(2, line)
+	callTok := token.Token{Type: token.LPAREN, Literal: "(", Line: line}
+
+	inner := &FunctionLiteral{
+		Token:      fnToken(line),
+		Parameters: []*Identifier{arg},
+		Body: blockOf(&CallExpression{
+			Token:    callTok,
+			Function: second,
+			Arguments: []Expression{
+				&CallExpression{Token: callTok, Function: first, Arguments: []Expression{arg}},
+			},
+		}, line),
+	}
+	outer := &FunctionLiteral{
+		Token:      fnToken(line),
+		Parameters: []*Identifier{first, second},
+		Body:       blockOf(inner, line),
+	}
+
+	return &CallExpression{Token: callTok, Function: outer, Arguments: []Expression{ie.Left, ie.Right}}
+}
+
+func hiddenIdentifier(i int, line int) *Identifier {
+	name := fmt.Sprintf("@%d", i)
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name, Line: line}, Value: name}
+}
+
+func fnToken(line int) token.Token {
+	return token.Token{Type: token.FUNCTION, Literal: "fn", Line: line}
+}
+
+func blockOf(exp Expression, line int) *BlockStatement {
+	tok := token.Token{Type: token.LBRACE, Literal: "{", Line: line}
+	return &BlockStatement{
+		Token:      tok,
+		Statements: []Statement{&ExpressionStatement{Token: tok, Expression: exp}},
+	}
+}
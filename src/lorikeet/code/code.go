@@ -44,7 +44,25 @@ const (
 	OpClosure
 	OpGetFree
 	OpCurrentClosure
-	OpLazyCall
+	OpTailCall
+	OpRange
+	OpSlice
+	OpJumpNull
+	OpJumpNotNull
+	OpMatchVariant
+	OpGetField
+	OpYield
+	OpTuple
+	OpSet
+	OpUnpack
+	OpIn
+	OpUnion
+	OpIntersect
+	OpSetFree
+	OpFreeze
+	OpSetIndex
+	OpGetModule
+	OpUnset
 )
 
 // Definition of an opcode had two fields.
@@ -87,7 +105,25 @@ var definitions = map[Opcode]*Definition{
 	OpClosure:        {"OpClosure", []int{2, 1}},
 	OpGetFree:        {"OpGetFree", []int{1}},
 	OpCurrentClosure: {"OpCurrentClosure", []int{}},
-	OpLazyCall:       {"OpCall", []int{1}},
+	OpTailCall:       {"OpTailCall", []int{1}},
+	OpRange:          {"OpRange", []int{1}},
+	OpSlice:          {"OpSlice", []int{}},
+	OpJumpNull:       {"OpJumpNull", []int{2}},
+	OpJumpNotNull:    {"OpJumpNotNull", []int{2}},
+	OpMatchVariant:   {"OpMatchVariant", []int{2}},
+	OpGetField:       {"OpGetField", []int{1}},
+	OpYield:          {"OpYield", []int{}},
+	OpTuple:          {"OpTuple", []int{2}},
+	OpSet:            {"OpSet", []int{2}},
+	OpUnpack:         {"OpUnpack", []int{1}},
+	OpIn:             {"OpIn", []int{}},
+	OpUnion:          {"OpUnion", []int{}},
+	OpIntersect:      {"OpIntersect", []int{}},
+	OpSetFree:        {"OpSetFree", []int{1, 1}},
+	OpFreeze:         {"OpFreeze", []int{}},
+	OpSetIndex:       {"OpSetIndex", []int{}},
+	OpGetModule:      {"OpGetModule", []int{1}},
+	OpUnset:          {"OpUnset", []int{}},
 }
 
 // Lookup gets opcode definition by id
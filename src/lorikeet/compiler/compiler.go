@@ -5,7 +5,8 @@ import (
 	"lorikeet/ast"
 	"lorikeet/code"
 	"lorikeet/object"
-	"sort"
+	"lorikeet/token"
+	"strings"
 )
 
 // EmittedInstruction used to track last two instructions
@@ -20,6 +21,18 @@ type CompilationScope struct {
 	instructions        code.Instructions
 	lastInstruction     EmittedInstruction
 	previousInstruction EmittedInstruction
+	generator           bool
+
+	// unset holds the local function declarations that are hoisted but
+	// not set yet, with the closures that captured them before they were
+	unset map[int][]freeSlot
+}
+
+// freeSlot is a free variable of the closure stored in a local symbol,
+// closures that are not stored by a let are kept in a hidden local
+type freeSlot struct {
+	closure Symbol
+	index   int
 }
 
 // Compiler struct
@@ -30,6 +43,19 @@ type Compiler struct {
 
 	scopes     []CompilationScope
 	scopeIndex int
+
+	warnings []string
+
+	// tail is set when the next compiled node is in tail position
+	tail bool
+
+	// hoisted holds the symbols of function declarations that were
+	// defined before the statements of their block were compiled
+	hoisted map[*ast.LetStatement]Symbol
+
+	// closure is set while compiling a function literal that is
+	// stored in a local symbol
+	closure *Symbol
 }
 
 // New inits compiler
@@ -45,19 +71,31 @@ func New() *Compiler {
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
+	for i, m := range object.Modules {
+		symbolTable.DefineModule(i, m.Name)
+	}
 
 	return &Compiler{
 		constants:   []object.Object{},
 		symbolTable: symbolTable,
 		scopes:      []CompilationScope{mainScope},
 		scopeIndex:  0,
+		hoisted:     make(map[*ast.LetStatement]Symbol),
 	}
 }
 
 // Compile turns code into target langauge
 func (c *Compiler) Compile(node ast.Node) error {
+	tail := c.tail
+	c.tail = false
+
 	switch node := node.(type) {
 	case *ast.Program:
+		err := c.hoistFunctions(node.Statements)
+		if err != nil {
+			return err
+		}
+
 		for _, s := range node.Statements {
 			err := c.Compile(s)
 			if err != nil {
@@ -66,13 +104,45 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.ExpressionStatement:
+		c.tail = tail
 		err := c.Compile(node.Expression)
 		if err != nil {
 			return err
 		}
 		c.emit(code.OpPop)
 
+	case *ast.PartialExpression:
+		c.tail = tail
+		return c.Compile(ast.LowerPartial(node))
+
 	case *ast.InfixExpression:
+		if node.Operator == ">>" {
+			c.tail = tail
+			return c.Compile(ast.LowerCompose(node))
+		}
+
+		// Special Case, only compile right node
+		// when left node is null
+		if node.Operator == "??" {
+			err := c.Compile(node.Left)
+			if err != nil {
+				return err
+			}
+
+			// Emit an `OpJumpNotNull` with a bogus value
+			jumpNotNullPos := c.emit(code.OpJumpNotNull, 9999)
+			c.emit(code.OpPop)
+
+			err = c.Compile(node.Right)
+			if err != nil {
+				return err
+			}
+
+			afterRightPos := len(c.currentInstructions())
+			c.changeOperand(jumpNotNullPos, afterRightPos)
+			return nil
+		}
+
 		// Special Case, compile right node first
 		// then emit OpGreater
 		if node.Operator == "<" {
@@ -114,13 +184,26 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpEqual)
 		case "!=":
 			c.emit(code.OpNotEqual)
+		case "..":
+			c.emit(code.OpRange, 0)
+		case "..=":
+			c.emit(code.OpRange, 1)
+		case "in":
+			c.emit(code.OpIn)
+		case "|":
+			c.emit(code.OpUnion)
+		case "&":
+			c.emit(code.OpIntersect)
 		default:
 			return fmt.Errorf("unknown operator %s; line=%d",
 				node.Operator, node.Token.Line)
 		}
 
 	case *ast.IntegerLiteral:
-		integer := &object.Integer{Value: node.Value}
+		var integer object.Object = &object.Integer{Value: node.Value}
+		if node.Big != nil {
+			integer = &object.BigInt{Value: node.Big}
+		}
 		c.emit(code.OpConstant, c.addConstant(integer))
 
 	case *ast.FloatLiteral:
@@ -142,20 +225,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 				return fmt.Errorf("unexpected operator after $; line=%d",
 					node.Token.Line)
 			}
-			err := c.Compile(call.Function)
-			if err != nil {
-				return err
-			}
-
-			for _, a := range call.Arguments {
-				err := c.Compile(a)
-				if err != nil {
-					return err
-				}
+			if !tail {
+				return fmt.Errorf("$ call is not in tail position; line=%d",
+					node.Token.Line)
 			}
 
-			c.emit(code.OpLazyCall, len(call.Arguments))
-			return nil
+			c.tail = true
+			return c.Compile(call)
 		}
 		err := c.Compile(node.Right)
 		if err != nil {
@@ -181,14 +257,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 		// Emit an `OpJumpNotTruthy` with a bogus value
 		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
 
+		c.tail = tail
 		err = c.Compile(node.Consequence)
 		if err != nil {
 			return err
 		}
 
-		if c.lastInstructionIs(code.OpPop) {
-			c.removeLastPop()
-		}
+		c.leaveBlockValue()
 
 		// Emit an `OpJump` with a bogus value
 		jumpPos := c.emit(code.OpJump, 9999)
@@ -199,21 +274,26 @@ func (c *Compiler) Compile(node ast.Node) error {
 		if node.Alternative == nil {
 			c.emit(code.OpNull)
 		} else {
+			c.tail = tail
 			err := c.Compile(node.Alternative)
 			if err != nil {
 				return err
 			}
 
-			if c.lastInstructionIs(code.OpPop) {
-				c.removeLastPop()
-			}
+			c.leaveBlockValue()
 		}
 
 		afterAlternativePos := len(c.currentInstructions())
 		c.changeOperand(jumpPos, afterAlternativePos)
 
 	case *ast.BlockStatement:
-		for _, s := range node.Statements {
+		err := c.hoistFunctions(node.Statements)
+		if err != nil {
+			return err
+		}
+
+		for i, s := range node.Statements {
+			c.tail = tail && i == len(node.Statements)-1
 			err := c.Compile(s)
 			if err != nil {
 				return err
@@ -221,14 +301,26 @@ func (c *Compiler) Compile(node ast.Node) error {
 		}
 
 	case *ast.LetStatement:
-		symbol, err := c.symbolTable.Define(node.Name.Value, node.Mut)
-		if err != nil {
-			return err
+		_, isFunction := node.Value.(*ast.FunctionLiteral)
+		symbol, hoisted := c.hoisted[node]
+		var err error
+		if !hoisted {
+			symbol, err = c.symbolTable.Define(node.Name.Value, node.Mut)
+			if err != nil {
+				return fmt.Errorf("%s; line=%d", err, node.Name.Token.Line)
+			}
+		}
+		if isFunction && symbol.Scope == LocalScope {
+			c.closure = &symbol
 		}
 		err = c.Compile(node.Value)
+		c.closure = nil
 		if err != nil {
 			return err
 		}
+		if node.Token.Type == token.CONST {
+			c.emit(code.OpFreeze)
+		}
 
 		if symbol.Scope == GlobalScope {
 			c.emit(code.OpSetGlobal, symbol.Index)
@@ -236,6 +328,79 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.emit(code.OpSetLocal, symbol.Index)
 		}
 
+		if hoisted {
+			c.patchFree(symbol)
+		}
+
+	case *ast.IndexAssignStatement:
+		err := c.Compile(node.Target.Left)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Target.Index)
+		if err != nil {
+			return err
+		}
+		err = c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex)
+
+	case *ast.DestructureStatement:
+		err := c.Compile(node.Value)
+		if err != nil {
+			return err
+		}
+
+		// OpUnpack leaves the first element on top of the stack
+		c.emit(code.OpUnpack, len(node.Names))
+		for _, name := range node.Names {
+			symbol, err := c.symbolTable.Define(name.Value, node.Mut)
+			if err != nil {
+				return fmt.Errorf("%s; line=%d", err, name.Token.Line)
+			}
+			c.storeSymbol(symbol)
+		}
+
+	case *ast.EnumStatement:
+		enum := &Enum{Name: node.Name.Value, Fields: make(map[string]int)}
+		for _, v := range node.Variants {
+			enum.Variants = append(enum.Variants, v.Name.Value)
+			enum.Fields[v.Name.Value] = len(v.Fields)
+		}
+
+		for _, v := range node.Variants {
+			symbol, err := c.symbolTable.DefineVariant(v.Name.Value, enum)
+			if err != nil {
+				return fmt.Errorf("%s; line=%d", err, v.Name.Token.Line)
+			}
+
+			var variant object.Object
+			if len(v.Fields) == 0 {
+				variant = &object.Variant{Enum: enum.Name, Name: v.Name.Value}
+			} else {
+				fields := []string{}
+				for _, f := range v.Fields {
+					fields = append(fields, f.Value)
+				}
+				variant = &object.Constructor{
+					Enum:   enum.Name,
+					Name:   v.Name.Value,
+					Fields: fields,
+				}
+			}
+
+			c.emit(code.OpConstant, c.addConstant(variant))
+			c.storeSymbol(symbol)
+		}
+
+	case *ast.MatchExpression:
+		err := c.compileMatchExpression(node, tail)
+		if err != nil {
+			return err
+		}
+
 	case *ast.MutStatement:
 		symbol, ok := c.symbolTable.Resolve(node.Name.Value)
 		if !ok {
@@ -267,6 +432,10 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.loadSymbol(symbol)
 
+	case *ast.CharLiteral:
+		char := &object.Char{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(char))
+
 	case *ast.StringLiteral:
 		str := &object.String{Value: node.Value}
 		c.emit(code.OpConstant, c.addConstant(str))
@@ -281,16 +450,28 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpArray, len(node.Elements))
 
-	case *ast.HashLiteral:
-		keys := []ast.Expression{}
-		for k := range node.Pairs {
-			keys = append(keys, k)
+	case *ast.TupleLiteral:
+		for _, el := range node.Elements {
+			err := c.Compile(el)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpTuple, len(node.Elements))
+
+	case *ast.SetLiteral:
+		for _, el := range node.Elements {
+			err := c.Compile(el)
+			if err != nil {
+				return err
+			}
 		}
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
 
-		for _, k := range keys {
+		c.emit(code.OpSet, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, k := range node.OrderedKeys() {
 			err := c.Compile(k)
 			if err != nil {
 				return err
@@ -309,6 +490,8 @@ func (c *Compiler) Compile(node ast.Node) error {
 			return err
 		}
 
+		jumpNullPos := c.emitOptionalJump(node.Optional)
+
 		err = c.Compile(node.Index)
 		if err != nil {
 			return err
@@ -316,8 +499,38 @@ func (c *Compiler) Compile(node ast.Node) error {
 
 		c.emit(code.OpIndex)
 
+		c.patchOptionalJump(jumpNullPos)
+
+	case *ast.SliceExpression:
+		err := c.Compile(node.Left)
+		if err != nil {
+			return err
+		}
+
+		jumpNullPos := c.emitOptionalJump(node.Optional)
+
+		for _, bound := range []ast.Expression{node.Start, node.End} {
+			if bound == nil {
+				c.emit(code.OpNull)
+				continue
+			}
+
+			err := c.Compile(bound)
+			if err != nil {
+				return err
+			}
+		}
+
+		c.emit(code.OpSlice)
+
+		c.patchOptionalJump(jumpNullPos)
+
 	case *ast.FunctionLiteral:
+		closure := c.closure
+		c.closure = nil
+
 		c.enterScope()
+		c.scopes[c.scopeIndex].generator = node.Generator
 
 		if node.Name != "" {
 			c.symbolTable.DefineFunctionName(node.Name)
@@ -327,6 +540,7 @@ func (c *Compiler) Compile(node ast.Node) error {
 			c.symbolTable.Define(p.Value, false)
 		}
 
+		c.tail = true
 		err := c.Compile(node.Body)
 		if err != nil {
 			return err
@@ -343,7 +557,13 @@ func (c *Compiler) Compile(node ast.Node) error {
 		numLocals := c.symbolTable.numDefinitions
 		instructions := c.leaveScope()
 
-		for _, s := range freeSymbols {
+		unsetFree := []int{}
+		for i, s := range freeSymbols {
+			if c.isUnset(s) {
+				unsetFree = append(unsetFree, i)
+				c.emit(code.OpUnset)
+				continue
+			}
 			c.loadSymbol(s)
 		}
 
@@ -351,12 +571,47 @@ func (c *Compiler) Compile(node ast.Node) error {
 			Instructions:  instructions,
 			NumLocals:     numLocals,
 			NumParameters: len(node.Parameters),
+			Generator:     node.Generator,
 		}
 		fnIndex := c.addConstant(compiledFn)
 		c.emit(code.OpClosure, fnIndex, len(freeSymbols))
 
+		if len(unsetFree) > 0 {
+			if closure == nil {
+				hidden, err := c.symbolTable.Define(
+					fmt.Sprintf("closure %d", c.symbolTable.numDefinitions), false)
+				if err != nil {
+					return err
+				}
+				c.emit(code.OpSetLocal, hidden.Index)
+				c.emit(code.OpGetLocal, hidden.Index)
+				closure = &hidden
+			}
+			// Patched by patchFree once the declarations are set
+			unset := c.scopes[c.scopeIndex].unset
+			for _, i := range unsetFree {
+				s := freeSymbols[i]
+				unset[s.Index] = append(unset[s.Index], freeSlot{*closure, i})
+			}
+		}
+
+	case *ast.YieldExpression:
+		if !c.scopes[c.scopeIndex].generator {
+			return fmt.Errorf("yield outside of generator function; line=%d", node.Line())
+		}
+		if node.Value != nil {
+			err := c.Compile(node.Value)
+			if err != nil {
+				return err
+			}
+		} else {
+			c.emit(code.OpNull)
+		}
+		c.emit(code.OpYield)
+
 	case *ast.ReturnStatement:
 		if node.ReturnValue != nil {
+			c.tail = c.scopeIndex > 0
 			err := c.Compile(node.ReturnValue)
 			if err != nil {
 				return err
@@ -379,13 +634,166 @@ func (c *Compiler) Compile(node ast.Node) error {
 			}
 		}
 
-		c.emit(code.OpCall, len(node.Arguments))
+		if tail {
+			c.emit(code.OpTailCall, len(node.Arguments))
+		} else {
+			c.emit(code.OpCall, len(node.Arguments))
+		}
 
 	}
 
 	return nil
 }
 
+func (c *Compiler) compileMatchExpression(node *ast.MatchExpression, tail bool) error {
+	err := c.checkMatchExhaustive(node)
+	if err != nil {
+		return err
+	}
+
+	err = c.Compile(node.Subject)
+	if err != nil {
+		return err
+	}
+
+	// The subject is kept in a symbol no identifier can name
+	// so every arm can load it again
+	subject, restoreSubject := c.symbolTable.DefineScoped("$match")
+	defer restoreSubject()
+	c.storeSymbol(subject)
+
+	endJumps := []int{}
+
+	for _, arm := range node.Arms {
+		nextArmPos := -1
+		restores := []func(){}
+
+		switch {
+		case arm.Variant != nil:
+			enum, _ := c.symbolTable.ResolveVariant(arm.Variant.Value)
+			tag := &object.String{Value: enum.Name + "." + arm.Variant.Value}
+
+			c.loadSymbol(subject)
+			c.emit(code.OpMatchVariant, c.addConstant(tag))
+			// Emit an `OpJumpNotTruthy` with a bogus value
+			nextArmPos = c.emit(code.OpJumpNotTruthy, 9999)
+
+			for i, b := range arm.Bindings {
+				c.loadSymbol(subject)
+				c.emit(code.OpGetField, i)
+
+				binding, restore := c.symbolTable.DefineScoped(b.Value)
+				restores = append(restores, restore)
+				c.storeSymbol(binding)
+			}
+
+		case arm.Value != nil:
+			c.loadSymbol(subject)
+			err := c.Compile(arm.Value)
+			if err != nil {
+				return err
+			}
+			c.emit(code.OpEqual)
+			// Emit an `OpJumpNotTruthy` with a bogus value
+			nextArmPos = c.emit(code.OpJumpNotTruthy, 9999)
+		}
+
+		c.tail = tail
+		err := c.Compile(arm.Body)
+		if err != nil {
+			return err
+		}
+
+		if c.lastInstructionIs(code.OpPop) {
+			c.removeLastPop()
+		} else {
+			c.emit(code.OpNull)
+		}
+
+		for _, restore := range restores {
+			restore()
+		}
+
+		// Emit an `OpJump` with a bogus value
+		endJumps = append(endJumps, c.emit(code.OpJump, 9999))
+
+		if nextArmPos >= 0 {
+			c.changeOperand(nextArmPos, len(c.currentInstructions()))
+		}
+	}
+
+	// No arm matched the subject
+	c.emit(code.OpNull)
+
+	afterArmsPos := len(c.currentInstructions())
+	for _, pos := range endJumps {
+		c.changeOperand(pos, afterArmsPos)
+	}
+
+	return nil
+}
+
+// checkMatchExhaustive validates the variant patterns of a match
+// expression and warns when not every variant of the enum is handled
+func (c *Compiler) checkMatchExhaustive(node *ast.MatchExpression) error {
+	var enum *Enum
+	handled := make(map[string]bool)
+
+	for _, arm := range node.Arms {
+		if arm.Variant == nil {
+			if arm.Value == nil {
+				return nil
+			}
+			continue
+		}
+
+		name := arm.Variant.Value
+		armEnum, ok := c.symbolTable.ResolveVariant(name)
+		if !ok {
+			return fmt.Errorf("%s is not an enum variant; line=%d",
+				name, arm.Token.Line)
+		}
+
+		if enum != nil && armEnum != enum {
+			return fmt.Errorf("match mixes variants of %s and %s; line=%d",
+				enum.Name, armEnum.Name, arm.Token.Line)
+		}
+		enum = armEnum
+
+		if len(arm.Bindings) != enum.Fields[name] {
+			return fmt.Errorf("wrong number of bindings for %s: want=%d, got=%d; line=%d",
+				name, enum.Fields[name], len(arm.Bindings), arm.Token.Line)
+		}
+
+		handled[name] = true
+	}
+
+	if enum == nil {
+		return nil
+	}
+
+	missing := []string{}
+	for _, v := range enum.Variants {
+		if !handled[v] {
+			missing = append(missing, v)
+		}
+	}
+
+	if len(missing) > 0 {
+		c.warnings = append(c.warnings,
+			fmt.Sprintf("match on %s does not handle %s; line=%d",
+				enum.Name, strings.Join(missing, ", "), node.Token.Line))
+	}
+
+	return nil
+}
+
+// Warnings returns problems found while compiling
+// that do not stop compilation
+func (c *Compiler) Warnings() []string {
+	return c.warnings
+}
+
 // Bytecode returns compiler bytecode
 func (c *Compiler) Bytecode() *Bytecode {
 	return &Bytecode{
@@ -439,6 +847,16 @@ func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
 	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
 }
 
+// leaveBlockValue keeps the value of a block's last expression on the
+// stack, blocks that end in a statement or are empty leave null
+func (c *Compiler) leaveBlockValue() {
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+}
+
 func (c *Compiler) removeLastPop() {
 	last := c.scopes[c.scopeIndex].lastInstruction
 	previous := c.scopes[c.scopeIndex].previousInstruction
@@ -507,6 +925,80 @@ func (c *Compiler) replaceLastPopWithReturn() {
 	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
 }
 
+// emitOptionalJump emits an `OpJumpNull` with a bogus value when
+// optional is set, returns -1 when nothing was emitted
+func (c *Compiler) emitOptionalJump(optional bool) int {
+	if !optional {
+		return -1
+	}
+	return c.emit(code.OpJumpNull, 9999)
+}
+
+// patchOptionalJump points a jump made by emitOptionalJump
+// to the current position
+func (c *Compiler) patchOptionalJump(pos int) {
+	if pos < 0 {
+		return
+	}
+	c.changeOperand(pos, len(c.currentInstructions()))
+}
+
+// hoistFunctions defines the names of the function declarations in
+// statements, so functions declared in the same block can call each
+// other whatever order they are declared in
+func (c *Compiler) hoistFunctions(statements []ast.Statement) error {
+	for _, s := range statements {
+		let, ok := s.(*ast.LetStatement)
+		if !ok || let.Token.Type != token.FUNCTION {
+			continue
+		}
+
+		symbol, err := c.symbolTable.Define(let.Name.Value, let.Mut)
+		if err != nil {
+			return fmt.Errorf("%s; line=%d", err, let.Token.Line)
+		}
+		c.hoisted[let] = symbol
+
+		if symbol.Scope == LocalScope {
+			scope := &c.scopes[c.scopeIndex]
+			if scope.unset == nil {
+				scope.unset = make(map[int][]freeSlot)
+			}
+			scope.unset[symbol.Index] = nil
+		}
+	}
+	return nil
+}
+
+// isUnset reports whether s is a hoisted local function declaration
+// of the current scope that has not been set yet
+func (c *Compiler) isUnset(s Symbol) bool {
+	if s.Scope != LocalScope {
+		return false
+	}
+	_, ok := c.scopes[c.scopeIndex].unset[s.Index]
+	return ok
+}
+
+// patchFree sets the free variables of closures that captured the
+// hoisted declaration s before it was set
+func (c *Compiler) patchFree(s Symbol) {
+	unset := c.scopes[c.scopeIndex].unset
+	for _, slot := range unset[s.Index] {
+		c.loadSymbol(s)
+		c.emit(code.OpSetFree, slot.closure.Index, slot.index)
+	}
+	delete(unset, s.Index)
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
 func (c *Compiler) loadSymbol(s Symbol) {
 	switch s.Scope {
 	case GlobalScope:
@@ -515,6 +1007,8 @@ func (c *Compiler) loadSymbol(s Symbol) {
 		c.emit(code.OpGetLocal, s.Index)
 	case BuiltinScope:
 		c.emit(code.OpGetBuiltin, s.Index)
+	case ModuleScope:
+		c.emit(code.OpGetModule, s.Index)
 	case FreeScope:
 		c.emit(code.OpGetFree, s.Index)
 	case FunctionScope:
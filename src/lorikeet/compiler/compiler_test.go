@@ -493,6 +493,585 @@ func TestIndexExpressions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestRangeAndSliceExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1..3",
+			expectedConstants: []interface{}{1, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpRange, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1..=3",
+			expectedConstants: []interface{}{1, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpRange, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1, 2][1:]",
+			expectedConstants: []interface{}{1, 2, 1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpArray, 2),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpNull),
+				code.Make(code.OpSlice),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTuplesAndSets(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "(1, 2)",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpTuple, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "#{1} | #{2} & #{3}",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSet, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpSet, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSet, 1),
+				code.Make(code.OpIntersect),
+				code.Make(code.OpUnion),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "1 in []",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpIn),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "let (a, b) = (1, 2);",
+			expectedConstants: []interface{}{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpTuple, 2),
+				code.Make(code.OpUnpack, 2),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestConstAndIndexAssignment(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "const a = [1];",
+			expectedConstants: []interface{}{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpFreeze),
+				code.Make(code.OpSetGlobal, 0),
+			},
+		},
+		{
+			input:             "let a = [1]; a[0] = 2;",
+			expectedConstants: []interface{}{1, 0, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetIndex),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestNullSafeOperators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 ?? 2; 3",
+			expectedConstants: []interface{}{1, 2, 3},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpJumpNotNull, 10),
+				// 0006
+				code.Make(code.OpPop),
+				// 0007
+				code.Make(code.OpConstant, 1),
+				// 0010
+				code.Make(code.OpPop),
+				// 0011
+				code.Make(code.OpConstant, 2),
+				// 0014
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             "[1]?[0]",
+			expectedConstants: []interface{}{1, 0},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpArray, 1),
+				// 0006
+				code.Make(code.OpJumpNull, 13),
+				// 0009
+				code.Make(code.OpConstant, 1),
+				// 0012
+				code.Make(code.OpIndex),
+				// 0013
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input:             `{}?.name`,
+			expectedConstants: []interface{}{"name"},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpHash, 0),
+				// 0003
+				code.Make(code.OpJumpNull, 10),
+				// 0006
+				code.Make(code.OpConstant, 0),
+				// 0009
+				code.Make(code.OpIndex),
+				// 0010
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestEnumsAndMatch(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `enum Shape { Circle(r), Empty }
+			match (Empty) { Circle(r) => r, Empty => 0 }`,
+			expectedConstants: []interface{}{
+				&object.Constructor{Enum: "Shape", Name: "Circle", Fields: []string{"r"}},
+				&object.Variant{Enum: "Shape", Name: "Empty"},
+				"Shape.Circle",
+				"Shape.Empty",
+				0,
+			},
+			expectedInstructions: []code.Instructions{
+				// 0000
+				code.Make(code.OpConstant, 0),
+				// 0003
+				code.Make(code.OpSetGlobal, 0),
+				// 0006
+				code.Make(code.OpConstant, 1),
+				// 0009
+				code.Make(code.OpSetGlobal, 1),
+				// 0012
+				code.Make(code.OpGetGlobal, 1),
+				// 0015
+				code.Make(code.OpSetGlobal, 2),
+				// 0018
+				code.Make(code.OpGetGlobal, 2),
+				// 0021
+				code.Make(code.OpMatchVariant, 2),
+				// 0024
+				code.Make(code.OpJumpNotTruthy, 41),
+				// 0027
+				code.Make(code.OpGetGlobal, 2),
+				// 0030
+				code.Make(code.OpGetField, 0),
+				// 0032
+				code.Make(code.OpSetGlobal, 3),
+				// 0035
+				code.Make(code.OpGetGlobal, 3),
+				// 0038
+				code.Make(code.OpJump, 57),
+				// 0041
+				code.Make(code.OpGetGlobal, 2),
+				// 0044
+				code.Make(code.OpMatchVariant, 3),
+				// 0047
+				code.Make(code.OpJumpNotTruthy, 56),
+				// 0050
+				code.Make(code.OpConstant, 4),
+				// 0053
+				code.Make(code.OpJump, 57),
+				// 0056
+				code.Make(code.OpNull),
+				// 0057
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestMatchExhaustiveness(t *testing.T) {
+	tests := []struct {
+		input    string
+		warnings []string
+	}{
+		{
+			input: `enum Shape { Circle(r), Rect(w, h), Empty }
+			match (Empty) { Circle(r) => r }`,
+			warnings: []string{"match on Shape does not handle Rect, Empty; line=2"},
+		},
+		{
+			input: `enum Shape { Circle(r), Rect(w, h), Empty }
+			match (Empty) { Circle(r) => r, Rect(w, h) => w, Empty => 0 }`,
+			warnings: []string{},
+		},
+		{
+			input: `enum Shape { Circle(r), Rect(w, h), Empty }
+			match (Empty) { Circle(r) => r, _ => 0 }`,
+			warnings: []string{},
+		},
+		{
+			input: `enum Shape { Circle(r), Empty }
+			let area = fn(s) { match (s) { Empty => 0 } }`,
+			warnings: []string{"match on Shape does not handle Circle; line=2"},
+		},
+		{
+			input:    `match (1) { 1 => "one", 2 => "two" }`,
+			warnings: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		warnings := compiler.Warnings()
+		if len(warnings) != len(tt.warnings) {
+			t.Fatalf("wrong number of warnings. want=%q, got=%q",
+				tt.warnings, warnings)
+		}
+
+		for i, w := range tt.warnings {
+			if warnings[i] != w {
+				t.Errorf("wrong warning. want=%q, got=%q", w, warnings[i])
+			}
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `let a = 1; match (a) { a => 1 }`,
+			expected: "a is not an enum variant; line=1",
+		},
+		{
+			input: `enum A { X } enum B { Y }
+			match (X) { X => 1, Y => 2 }`,
+			expected: "match mixes variants of A and B; line=2",
+		},
+		{
+			input:    `enum A { X(a, b) } match (X) { X(a) => a }`,
+			expected: "wrong number of bindings for X: want=2, got=1; line=1",
+		},
+		{
+			input:    `enum A { X, X }`,
+			expected: "symbol X is already declared; line=1",
+		},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("expected compiler error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestRedeclarationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let (a, a) = (1, 2);", "symbol a is already declared; line=1"},
+		{"let a = 1;\nlet (b, a) = (1, 2);", "symbol a is already declared; line=2"},
+		{"let a = 1;\n\nlet a = 2;", "symbol a is already declared; line=3"},
+		{"let f = 1;\nlet f = fn() { 1 };", "symbol f is already declared; line=2"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parse(tt.input))
+		if err == nil {
+			t.Fatalf("expected compiler error for %q but resulted in none.", tt.input)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestDestructuredValueCannotReadItsNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let (a, b) = (1, a);", "undefined variable a; line=1"},
+		{"fn() { let (x, y) = (y, 2); }", "undefined variable y; line=1"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("expected compiler error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn*() { yield 1 }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpYield),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn*() { yield; 2 }`,
+			expectedConstants: []interface{}{
+				2,
+				[]code.Instructions{
+					code.Make(code.OpNull),
+					code.Make(code.OpYield),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	errorInputs := []string{
+		`yield 1`,
+		`fn*() { fn() { yield 1 } }`,
+	}
+
+	for _, input := range errorInputs {
+		program := parse(input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("expected compiler error but resulted in none.")
+		}
+
+		expected := "yield outside of generator function; line=1"
+		if err.Error() != expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", expected, err)
+		}
+	}
+}
+
+func TestModules(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             `strings.upper("a")`,
+			expectedConstants: []interface{}{"upper", "a"},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetModule, 0),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpIndex),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `fn() { return len([]); }`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetBuiltin, 0),
+					code.Make(code.OpArray, 0),
+					code.Make(code.OpTailCall, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn() { len([]) + 1 }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetBuiltin, 0),
+					code.Make(code.OpArray, 0),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpAdd),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `fn() { if (true) { len([]) } else { $len([]) } }`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					// 0000
+					code.Make(code.OpTrue),
+					// 0001
+					code.Make(code.OpJumpNotTruthy, 14),
+					// 0004
+					code.Make(code.OpGetBuiltin, 0),
+					// 0006
+					code.Make(code.OpArray, 0),
+					// 0009
+					code.Make(code.OpTailCall, 1),
+					// 0011
+					code.Make(code.OpJump, 21),
+					// 0014
+					code.Make(code.OpGetBuiltin, 0),
+					// 0016
+					code.Make(code.OpArray, 0),
+					// 0019
+					code.Make(code.OpTailCall, 1),
+					// 0021
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `len([]); fn() { len([]); 1 }`,
+			expectedConstants: []interface{}{
+				1,
+				[]code.Instructions{
+					code.Make(code.OpGetBuiltin, 0),
+					code.Make(code.OpArray, 0),
+					code.Make(code.OpCall, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpConstant, 0),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+				code.Make(code.OpClosure, 1, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	errorInputs := []string{
+		`$len([])`,
+		`fn() { $len([]); 1 }`,
+		`fn() { 1 + $len([]) }`,
+		`fn() { let a = $len([]); a }`,
+	}
+
+	for _, input := range errorInputs {
+		program := parse(input)
+
+		compiler := New()
+		err := compiler.Compile(program)
+		if err == nil {
+			t.Fatalf("expected compiler error but resulted in none.")
+		}
+
+		expected := "$ call is not in tail position; line=1"
+		if err.Error() != expected {
+			t.Errorf("wrong compiler error. want=%q, got=%q", expected, err)
+		}
+	}
+}
+
 func TestFunctions(t *testing.T) {
 	tests := []compilerTestCase{
 		{
@@ -821,7 +1400,7 @@ func TestBuiltins(t *testing.T) {
 				[]code.Instructions{
 					code.Make(code.OpGetBuiltin, 0),
 					code.Make(code.OpArray, 0),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -976,7 +1555,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 0),
 					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -1005,7 +1584,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 0),
 					code.Make(code.OpSub),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 				1,
@@ -1014,7 +1593,7 @@ func TestRecursiveFunctions(t *testing.T) {
 					code.Make(code.OpSetLocal, 0),
 					code.Make(code.OpGetLocal, 0),
 					code.Make(code.OpConstant, 2),
-					code.Make(code.OpCall, 1),
+					code.Make(code.OpTailCall, 1),
 					code.Make(code.OpReturnValue),
 				},
 			},
@@ -1031,6 +1610,116 @@ func TestRecursiveFunctions(t *testing.T) {
 	runCompilerTests(t, tests)
 }
 
+func TestHoistedFunctions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: `
+			fn a() { b() }
+			fn b() { 1 }
+			`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetGlobal, 1),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 0, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpSetGlobal, 1),
+			},
+		},
+		{
+			input: `
+			fn() {
+				fn a() { b() }
+				fn b() { a() }
+			}
+			`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpUnset),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpSetFree, 0, 0),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		{
+			input: `
+			fn() {
+				[fn() { b() }];
+				fn b() { 1 }
+			}
+			`,
+			expectedConstants: []interface{}{
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpTailCall, 0),
+					code.Make(code.OpReturnValue),
+				},
+				1,
+				[]code.Instructions{
+					code.Make(code.OpConstant, 1),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpUnset),
+					code.Make(code.OpClosure, 0, 1),
+					code.Make(code.OpSetLocal, 1),
+					code.Make(code.OpGetLocal, 1),
+					code.Make(code.OpArray, 1),
+					code.Make(code.OpPop),
+					code.Make(code.OpClosure, 2, 0),
+					code.Make(code.OpSetLocal, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetFree, 1, 0),
+					code.Make(code.OpReturn),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 3, 0),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+
+	program := parse("fn a() { 1 }\nfn a() { 2 }")
+	err := New().Compile(program)
+	expected := "symbol a is already declared; line=2"
+	if err == nil || err.Error() != expected {
+		t.Errorf("wrong compiler error. want=%q, got=%v", expected, err)
+	}
+}
+
 func runCompilerTests(t *testing.T, tests []compilerTestCase) {
 	t.Helper()
 
@@ -1124,6 +1813,11 @@ func testConstants(
 				return fmt.Errorf("constant %d - testStringObject failed: %s",
 					i, err)
 			}
+		case *object.Constructor, *object.Variant:
+			if actual[i].Inspect() != constant.(object.Object).Inspect() {
+				return fmt.Errorf("constant %d - wrong value. want=%s, got=%s",
+					i, constant.(object.Object).Inspect(), actual[i].Inspect())
+			}
 		case []code.Instructions:
 			fn, ok := actual[i].(*object.CompiledFunction)
 			if !ok {
@@ -10,6 +10,7 @@ const (
 	LocalScope    SymbolScope = "LOCAL"
 	GlobalScope   SymbolScope = "GLOBAL"
 	BuiltinScope  SymbolScope = "BUILTIN"
+	ModuleScope   SymbolScope = "MODULE"
 	FreeScope     SymbolScope = "FREE"
 	FunctionScope SymbolScope = "FUNCTION"
 )
@@ -23,11 +24,19 @@ type Symbol struct {
 	Mut   bool
 }
 
+// Enum holds the variants an enum was declared with
+type Enum struct {
+	Name     string
+	Variants []string
+	Fields   map[string]int // number of fields by variant name
+}
+
 // SymbolTable store for symbols
 type SymbolTable struct {
 	Outer *SymbolTable
 
 	store          map[string]Symbol
+	variants       map[string]*Enum
 	numDefinitions int
 
 	FreeSymbols []Symbol
@@ -36,8 +45,9 @@ type SymbolTable struct {
 // NewSymbolTable inits symbol tables
 func NewSymbolTable() *SymbolTable {
 	s := make(map[string]Symbol)
+	v := make(map[string]*Enum)
 	free := []Symbol{}
-	return &SymbolTable{store: s, FreeSymbols: free}
+	return &SymbolTable{store: s, variants: v, FreeSymbols: free}
 }
 
 // Define symbol in symbol table
@@ -62,6 +72,55 @@ func (s *SymbolTable) Define(name string, mut bool) (Symbol, error) {
 	return symbol, nil
 }
 
+// DefineScoped defines a symbol that shadows any symbol with the same
+// name in this table until the returned restore function is called
+func (s *SymbolTable) DefineScoped(name string) (Symbol, func()) {
+	previous, shadowed := s.store[name]
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: LocalScope}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+
+	return symbol, func() {
+		if shadowed {
+			s.store[name] = previous
+		} else {
+			delete(s.store, name)
+		}
+	}
+}
+
+// DefineVariant defines a constant symbol for an enum variant
+// and records the enum it belongs to
+func (s *SymbolTable) DefineVariant(name string, enum *Enum) (Symbol, error) {
+	symbol, err := s.Define(name, false)
+	if err != nil {
+		return symbol, err
+	}
+
+	s.variants[name] = enum
+	return symbol, nil
+}
+
+// ResolveVariant gets the enum a variant was declared in,
+// unlike Resolve this does not capture free symbols
+func (s *SymbolTable) ResolveVariant(name string) (*Enum, bool) {
+	if symbol, ok := s.store[name]; ok && symbol.Scope != FreeScope {
+		enum, ok := s.variants[name]
+		return enum, ok
+	}
+
+	if s.Outer != nil {
+		return s.Outer.ResolveVariant(name)
+	}
+
+	return nil, false
+}
+
 // Resolve get symbol from symbol table
 func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 	obj, ok := s.store[name]
@@ -71,7 +130,7 @@ func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
 			return obj, ok
 		}
 
-		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope || obj.Scope == ModuleScope {
 			return obj, ok
 		}
 
@@ -97,6 +156,14 @@ func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
 	return symbol
 }
 
+// DefineModule symbols in the ModuleScope with given name and index,
+// this function ignores symbol table scope
+func (s *SymbolTable) DefineModule(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: ModuleScope}
+	s.store[name] = symbol
+	return symbol
+}
+
 // DefineFunctionName creates a new symbol with FunctionScope
 func (s *SymbolTable) DefineFunctionName(name string) Symbol {
 	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
@@ -319,6 +319,26 @@ func TestDefineResolveBuiltins(t *testing.T) {
 	}
 }
 
+func TestDefineResolveModules(t *testing.T) {
+	global := NewSymbolTable()
+	local := NewEnclosedSymbolTable(global)
+
+	expected := Symbol{Name: "strings", Scope: ModuleScope, Index: 0}
+	global.DefineModule(0, "strings")
+
+	for _, table := range []*SymbolTable{global, local} {
+		result, ok := table.Resolve(expected.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", expected.Name)
+			continue
+		}
+		if result != expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v",
+				expected.Name, expected, result)
+		}
+	}
+}
+
 func TestDefineAndResolveFunctionName(t *testing.T) {
 	global := NewSymbolTable()
 	global.DefineFunctionName("a")
@@ -353,3 +373,66 @@ func TestShadowingFunctionName(t *testing.T) {
 			expected.Name, expected, result)
 	}
 }
+
+func TestDefineScoped(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a", false)
+
+	scoped, restore := global.DefineScoped("a")
+	expected := Symbol{Name: "a", Scope: GlobalScope, Index: 1}
+	if scoped != expected {
+		t.Errorf("expected scoped a to be %+v, got=%+v", expected, scoped)
+	}
+
+	result, _ := global.Resolve("a")
+	if result != expected {
+		t.Errorf("expected a to resolve to %+v, got=%+v", expected, result)
+	}
+
+	restore()
+
+	expected = Symbol{Name: "a", Scope: GlobalScope, Index: 0}
+	result, _ = global.Resolve("a")
+	if result != expected {
+		t.Errorf("expected a to resolve to %+v after restore, got=%+v",
+			expected, result)
+	}
+
+	_, restore = global.DefineScoped("b")
+	restore()
+
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("name b resolvable after restore")
+	}
+}
+
+func TestResolveVariant(t *testing.T) {
+	enum := &Enum{Name: "Shape", Variants: []string{"Circle"}}
+
+	global := NewSymbolTable()
+	global.DefineVariant("Circle", enum)
+	global.Define("a", false)
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("Square", false)
+
+	// Resolving captures the variant as a free symbol
+	// in nested functions
+	nested := NewEnclosedSymbolTable(local)
+	nested.Resolve("Circle")
+
+	for _, table := range []*SymbolTable{global, local, nested} {
+		result, ok := table.ResolveVariant("Circle")
+		if !ok || result != enum {
+			t.Errorf("expected Circle to resolve to enum %s", enum.Name)
+		}
+
+		if _, ok := table.ResolveVariant("a"); ok {
+			t.Errorf("expected a not to resolve as a variant")
+		}
+	}
+
+	if _, err := global.DefineVariant("Circle", enum); err == nil {
+		t.Errorf("expected redefining variant Circle to fail")
+	}
+}
@@ -5,10 +5,41 @@ import (
 )
 
 var builtins = map[string]*object.Builtin{
-	"len":  object.GetBuiltinByName("len"),
-	"say":  object.GetBuiltinByName("say"),
-	"head": object.GetBuiltinByName("head"),
-	"last": object.GetBuiltinByName("last"),
-	"tail": object.GetBuiltinByName("tail"),
-	"push": object.GetBuiltinByName("push"),
+	"len":     object.GetBuiltinByName("len"),
+	"say":     object.GetBuiltinByName("say"),
+	"head":    object.GetBuiltinByName("head"),
+	"last":    object.GetBuiltinByName("last"),
+	"tail":    object.GetBuiltinByName("tail"),
+	"push":    object.GetBuiltinByName("push"),
+	"int":     object.GetBuiltinByName("int"),
+	"float":   object.GetBuiltinByName("float"),
+	"string":  object.GetBuiltinByName("string"),
+	"char":    object.GetBuiltinByName("char"),
+	"bytes":   object.GetBuiltinByName("bytes"),
+	"freeze":  object.GetBuiltinByName("freeze"),
+	"map":     object.GetBuiltinByName("map"),
+	"filter":  object.GetBuiltinByName("filter"),
+	"reduce":  object.GetBuiltinByName("reduce"),
+	"each":    object.GetBuiltinByName("each"),
+	"find":    object.GetBuiltinByName("find"),
+	"any":     object.GetBuiltinByName("any"),
+	"all":     object.GetBuiltinByName("all"),
+	"sort_by": object.GetBuiltinByName("sort_by"),
+	"format":  object.GetBuiltinByName("format"),
+	"sayf":    object.GetBuiltinByName("sayf"),
+
+	"json_parse":     object.GetBuiltinByName("json_parse"),
+	"json_stringify": object.GetBuiltinByName("json_stringify"),
+	"args":           object.GetBuiltinByName("args"),
+	"env":            object.GetBuiltinByName("env"),
+	"exit":           object.GetBuiltinByName("exit"),
+	"re":             object.GetBuiltinByName("re"),
+	"now":            object.GetBuiltinByName("now"),
+	"sleep":          object.GetBuiltinByName("sleep"),
+	"since":          object.GetBuiltinByName("since"),
+	"random":         object.GetBuiltinByName("random"),
+	"random_int":     object.GetBuiltinByName("random_int"),
+	"choice":         object.GetBuiltinByName("choice"),
+	"shuffle":        object.GetBuiltinByName("shuffle"),
+	"seed":           object.GetBuiltinByName("seed"),
 }
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"lorikeet/ast"
 	"lorikeet/object"
+	"lorikeet/token"
+	"math/rand"
+	"time"
 )
 
 // Boolean
@@ -42,12 +45,30 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if node.Token.Type == token.CONST {
+			val = object.Freeze(val)
+		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.IndexAssignStatement:
+		return evalIndexAssignStatement(node, env)
+
+	case *ast.DestructureStatement:
+		return evalDestructureStatement(node, env)
+
+	case *ast.EnumStatement:
+		evalEnumStatement(node, env)
+
 	// Expressions
 	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInt{Value: node.Big}
+		}
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 
@@ -58,12 +79,26 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 
+	case *ast.PartialExpression:
+		return Eval(ast.LowerPartial(node), env)
+
 	case *ast.InfixExpression:
+		if node.Operator == ">>" {
+			return Eval(ast.LowerCompose(node), env)
+		}
+
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
+		if node.Operator == "??" {
+			if _, ok := left.(*object.Null); !ok {
+				return left
+			}
+			return Eval(node.Right, env)
+		}
+
 		right := Eval(node.Right, env)
 		if isError(right) {
 			return right
@@ -74,10 +109,19 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, env)
+
+	case *ast.YieldExpression:
+		return newError("yield outside of generator function; line=%d", node.Line())
+
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
 	case *ast.FunctionLiteral:
+		if node.Generator {
+			return newError("generator functions are only supported by the vm; line=%d", node.Line())
+		}
 		params := node.Parameters
 		body := node.Body
 		return &object.Function{Parameters: params, Env: env, Body: body}
@@ -98,6 +142,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 
 		return applyFunction(function, args)
 
+	case *ast.CharLiteral:
+		return &object.Char{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
@@ -108,17 +155,33 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return &object.Array{Elements: elements}
 
+	case *ast.TupleLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Tuple{Elements: elements}
+
+	case *ast.SetLiteral:
+		return evalSetLiteral(node, env)
+
 	case *ast.IndexExpression:
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
+		if node.Optional && left.Type() == object.NULL {
+			return NULL
+		}
 		index := Eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 		return evalIndexExpression(left, index)
 
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, env)
 
@@ -136,7 +199,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
-		case *object.Error:
+		case *object.Error, *object.Exit:
 			return result
 		}
 	}
@@ -155,7 +218,7 @@ func evalBlockStatement(
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN || rt == object.ERROR {
+			if rt == object.RETURN || rt == object.ERROR || rt == object.EXIT {
 				return result
 			}
 		}
@@ -187,69 +250,120 @@ func evalInfixExpression(
 	left, right object.Object,
 ) object.Object {
 	switch {
-	case left.Type() == object.INTEGER && right.Type() == object.INTEGER:
+	case operator == "in":
+		return evalInExpression(left, right)
+	case object.IsInteger(left) && object.IsInteger(right):
 		return evalIntegerInfixExpression(operator, left, right)
 	case operator == "==":
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == "!=":
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
+	case (object.IsTimeOperand(left) || object.IsTimeOperand(right)) &&
+		operator != "<" && operator != ">":
+		return evalTimeInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
 		return newError("type mismatch: %s %s %s; line=%d",
 			left.Type(), operator, right.Type(), line)
+	case operator == "<" || operator == ">":
+		return evalComparison(operator, left, right)
 	case left.Type() == object.STRING && right.Type() == object.STRING:
 		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.SET && right.Type() == object.SET:
+		return evalSetInfixExpression(operator, left, right)
 	default:
 		return newError("unknown operator: %s %s %s; line=%d",
 			left.Type(), operator, right.Type(), line)
 	}
 }
 
-func evalBangOperatorExpression(right object.Object) object.Object {
-	switch right {
-	case TRUE:
-		return FALSE
-	case FALSE:
-		return TRUE
-	case NULL:
-		return TRUE
+func evalInExpression(element, container object.Object) object.Object {
+	found, ok := object.Contains(container, element)
+	if !ok {
+		return newError("unsupported types for in: %s in %s; line=%d",
+			element.Type(), container.Type(), line)
+	}
+	return nativeBoolToBooleanObject(found)
+}
+
+func evalSetInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case "|", "&", "-":
+		return object.SetOperation(operator, left.(*object.Set), right.(*object.Set))
 	default:
-		return FALSE
+		return newError("unknown operator: %s %s %s; line=%d",
+			left.Type(), operator, right.Type(), line)
 	}
 }
 
+func evalTimeInfixExpression(operator string, left, right object.Object) object.Object {
+	result, err := object.TimeOperation(operator, left, right)
+	if err != nil {
+		return newError("%s; line=%d", err, line)
+	}
+	if result == nil {
+		return newError("unknown operator: %s %s %s; line=%d",
+			left.Type(), operator, right.Type(), line)
+	}
+	return result
+}
+
+func evalComparison(operator string, left, right object.Object) object.Object {
+	a, b := left, right
+	if operator == "<" {
+		a, b = right, left
+	}
+	greater, ok := object.GreaterThan(a, b)
+	if !ok {
+		return newError("unknown operator: %s %s %s; line=%d",
+			left.Type(), operator, right.Type(), line)
+	}
+	return nativeBoolToBooleanObject(greater)
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!isTruthy(right))
+}
+
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER {
+	if right.Type() == object.DURATION {
+		return evalTimeInfixExpression("-", &object.Duration{}, right)
+	}
+	if !object.IsInteger(right) {
 		return newError("unknown operator: -%s; line=%d", right.Type(), line)
 	}
 
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	return object.NegateInteger(right)
 }
 
 func evalIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
 ) object.Object {
-	leftVal := left.(*object.Integer).Value
-	rightVal := right.(*object.Integer).Value
-
 	switch operator {
-	case "+":
-		return &object.Integer{Value: leftVal + rightVal}
-	case "-":
-		return &object.Integer{Value: leftVal - rightVal}
-	case "*":
-		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
-		return &object.Integer{Value: leftVal / rightVal}
+	case "+", "-", "*", "/":
+		if operator == "/" && object.BigValue(right).Sign() == 0 {
+			return newError("division by zero; line=%d", line)
+		}
+		return object.IntegerOperation(operator, left, right)
 	case "<":
-		return nativeBoolToBooleanObject(leftVal < rightVal)
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) < 0)
 	case ">":
-		return nativeBoolToBooleanObject(leftVal > rightVal)
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) > 0)
 	case "==":
-		return nativeBoolToBooleanObject(leftVal == rightVal)
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) == 0)
 	case "!=":
-		return nativeBoolToBooleanObject(leftVal != rightVal)
+		return nativeBoolToBooleanObject(object.CompareIntegers(left, right) != 0)
+	case "..", "..=":
+		if left.Type() != object.INTEGER || right.Type() != object.INTEGER {
+			return newError("range bounds must be INTEGER, got %s..%s; line=%d",
+				left.Type(), right.Type(), line)
+		}
+		r, ok := object.NewRange(left.(*object.Integer).Value,
+			right.(*object.Integer).Value, operator == "..=")
+		if !ok {
+			return newError("range too large: %s; line=%d", r.Inspect(), line)
+		}
+		return r
 	default:
 		return newError("unknown operator: %s %s %s; line=%d",
 			left.Type(), operator, right.Type(), line)
@@ -274,6 +388,95 @@ func evalIfExpression(
 	}
 }
 
+func evalEnumStatement(node *ast.EnumStatement, env *object.Environment) {
+	for _, v := range node.Variants {
+		if len(v.Fields) == 0 {
+			env.Set(v.Name.Value, &object.Variant{Enum: node.Name.Value, Name: v.Name.Value})
+			continue
+		}
+
+		fields := []string{}
+		for _, f := range v.Fields {
+			fields = append(fields, f.Value)
+		}
+		env.Set(v.Name.Value, &object.Constructor{
+			Enum:   node.Name.Value,
+			Name:   v.Name.Value,
+			Fields: fields,
+		})
+	}
+}
+
+func evalMatchExpression(
+	me *ast.MatchExpression,
+	env *object.Environment,
+) object.Object {
+	subject := Eval(me.Subject, env)
+	if isError(subject) {
+		return subject
+	}
+
+	for _, arm := range me.Arms {
+		armEnv := object.NewEnclosedEnvironment(env)
+
+		switch {
+		case arm.Variant != nil:
+			pattern := evalIdentifier(arm.Variant, env)
+			if isError(pattern) {
+				return pattern
+			}
+
+			variant, ok := subject.(*object.Variant)
+			if !ok || !matchesVariant(variant, pattern) {
+				continue
+			}
+
+			if len(arm.Bindings) != len(variant.Values) {
+				return newError("wrong number of bindings for %s: want=%d, got=%d; line=%d",
+					variant.Name, len(variant.Values), len(arm.Bindings), line)
+			}
+
+			for i, b := range arm.Bindings {
+				armEnv.Set(b.Value, variant.Values[i])
+			}
+
+		case arm.Value != nil:
+			value := Eval(arm.Value, env)
+			if isError(value) {
+				return value
+			}
+
+			matched := evalInfixExpression("==", subject, value)
+			if isError(matched) {
+				return matched
+			}
+
+			if !isTruthy(matched) {
+				continue
+			}
+		}
+
+		result := Eval(arm.Body, armEnv)
+		if result == nil {
+			return NULL
+		}
+		return result
+	}
+
+	return NULL
+}
+
+func matchesVariant(variant *object.Variant, pattern object.Object) bool {
+	switch pattern := pattern.(type) {
+	case *object.Constructor:
+		return variant.Tag() == pattern.Tag()
+	case *object.Variant:
+		return variant.Tag() == pattern.Tag()
+	default:
+		return false
+	}
+}
+
 func evalIdentifier(
 	node *ast.Identifier,
 	env *object.Environment,
@@ -286,16 +489,18 @@ func evalIdentifier(
 		return builtin
 	}
 
+	if module := object.GetModuleByName(node.Value); module != nil {
+		return module
+	}
+
 	return newError("identifier not found: "+node.Value+"; line=%d", line)
 }
 
 func isTruthy(obj object.Object) bool {
-	switch obj {
-	case NULL:
-		return false
-	case TRUE:
-		return true
-	case FALSE:
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
 		return false
 	default:
 		return true
@@ -306,9 +511,11 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// isError reports whether obj stops the evaluation, an exit stops it
+// like an error does
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR
+		return obj.Type() == object.ERROR || obj.Type() == object.EXIT
 	}
 	return false
 }
@@ -334,21 +541,67 @@ func applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d; line=%d",
+				len(fn.Parameters), len(args), line)
+		}
 		extendedEnv := extendFunctionEnv(fn, args)
 		evaluated := Eval(fn.Body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 
 	case *object.Builtin:
-		if result := fn.Fn(args...); result != nil {
+		var result object.Object
+		if fn.CallbackFn != nil {
+			var err error
+			result, err = fn.CallbackFn(caller{}, args...)
+			if errObj, ok := err.(*object.Error); ok {
+				return errObj
+			}
+			if exit, ok := err.(*object.Exit); ok {
+				return exit
+			}
+			if err != nil {
+				return newError("%s", err)
+			}
+		} else {
+			result = fn.Fn(args...)
+		}
+		if result != nil {
 			return result
 		}
 		return NULL
 
+	case *object.Constructor:
+		if len(args) != len(fn.Fields) {
+			return newError("wrong number of arguments: want=%d, got=%d; line=%d",
+				len(fn.Fields), len(args), line)
+		}
+		return &object.Variant{Enum: fn.Enum, Name: fn.Name, Values: args}
+
 	default:
 		return newError("not a function: %s; line=%d", fn.Type(), line)
 	}
 }
 
+// random is the random number generator of evaluated programs
+var random = object.NewRand(time.Now().UnixNano())
+
+// caller calls functions for builtins that take callbacks
+type caller struct{}
+
+func (caller) Rand() *rand.Rand { return random }
+
+func (caller) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	result := applyFunction(fn, args)
+	if err, ok := result.(*object.Error); ok {
+		return nil, err
+	}
+	if exit, ok := result.(*object.Exit); ok {
+		return nil, exit
+	}
+	return result, nil
+}
+
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
@@ -385,35 +638,100 @@ func evalStringInfixExpression(
 }
 
 func evalIndexExpression(left, index object.Object) object.Object {
+	iterable, isIterable := left.(object.Iterable)
+
 	switch {
-	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
-		return evalArrayIndexExpression(left, index)
+	case isIterable && index.Type() == object.INTEGER:
+		return evalIterableIndexExpression(iterable, index)
 	case left.Type() == object.HASH:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE:
+		return evalModuleMember(left.(*object.Module), index)
+	case left.Type() == object.REGEX:
+		return evalRegexMethod(left.(*object.Regex), index)
+	case isIterable:
+		return newError("index must be INTEGER, got %s; line=%d", index.Type(), line)
 	default:
 		return newError("index operator not supported: %s; line=%d", left.Type(), line)
 	}
 }
 
-func evalArrayIndexExpression(array, index object.Object) object.Object {
-	arrayObject := array.(*object.Array)
-	idx := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+func evalIterableIndexExpression(iterable object.Iterable, index object.Object) object.Object {
+	idx, ok := object.ResolveIndex(iterable, index.(*object.Integer).Value)
+	if !ok {
+		return NULL
+	}
 
-	if idx < 0 || idx > max {
+	return iterable.At(idx)
+}
+
+func evalSliceExpression(
+	node *ast.SliceExpression,
+	env *object.Environment,
+) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	if node.Optional && left == NULL {
 		return NULL
 	}
 
-	return arrayObject.Elements[idx]
+	iterable, ok := left.(object.Iterable)
+	if !ok {
+		return newError("slice operator not supported: %s; line=%d", left.Type(), line)
+	}
+
+	start := evalSliceBound(node.Start, 0, env)
+	if isError(start) {
+		return start
+	}
+
+	end := evalSliceBound(node.End, iterable.Len(), env)
+	if isError(end) {
+		return end
+	}
+
+	lo, hi := object.SliceBounds(iterable,
+		start.(*object.Integer).Value, end.(*object.Integer).Value)
+
+	return iterable.Slice(lo, hi)
+}
+
+func evalSliceBound(
+	bound ast.Expression,
+	fallback int64,
+	env *object.Environment,
+) object.Object {
+	if bound == nil {
+		return &object.Integer{Value: fallback}
+	}
+
+	evaluated := Eval(bound, env)
+	if isError(evaluated) {
+		return evaluated
+	}
+
+	if evaluated == NULL {
+		return &object.Integer{Value: fallback}
+	}
+
+	if evaluated.Type() != object.INTEGER {
+		return newError("slice bound must be INTEGER, got %s; line=%d",
+			evaluated.Type(), line)
+	}
+
+	return evaluated
 }
 
 func evalHashLiteral(
 	node *ast.HashLiteral,
 	env *object.Environment,
 ) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
-	for keyNode, valueNode := range node.Pairs {
+	for _, keyNode := range node.OrderedKeys() {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -424,16 +742,154 @@ func evalHashLiteral(
 			return newError("unusable as hash key: %s; line=%d", key.Type(), line)
 		}
 
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+func evalSetLiteral(
+	node *ast.SetLiteral,
+	env *object.Environment,
+) object.Object {
+	set := object.NewSet()
+
+	for _, elNode := range node.Elements {
+		el := Eval(elNode, env)
+		if isError(el) {
+			return el
+		}
+
+		hashable, ok := el.(object.Hashable)
+		if !ok {
+			return newError("unusable as set element: %s; line=%d", el.Type(), line)
+		}
+
+		set.Add(hashable)
+	}
+
+	return set
+}
+
+func evalDestructureStatement(
+	node *ast.DestructureStatement,
+	env *object.Environment,
+) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	var elements []object.Object
+	switch val := val.(type) {
+	case *object.Tuple:
+		elements = val.Elements
+	case *object.Array:
+		elements = val.Elements
+	default:
+		return newError("cannot destructure %s; line=%d", val.Type(), node.Line())
+	}
+
+	if len(elements) != len(node.Names) {
+		return newError("cannot destructure %s of %d elements into %d names; line=%d",
+			val.Type(), len(elements), len(node.Names), node.Line())
+	}
+
+	for i, name := range node.Names {
+		env.Set(name.Value, elements[i])
+	}
+
+	return nil
+}
+
+func evalIndexAssignStatement(
+	node *ast.IndexAssignStatement,
+	env *object.Environment,
+) object.Object {
+	left := Eval(node.Target.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	index := Eval(node.Target.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("index must be INTEGER, got %s; line=%d", index.Type(), node.Line())
+		}
+		if left.Frozen {
+			return newError("cannot change frozen ARRAY; line=%d", node.Line())
+		}
+		offset, ok := object.ResolveIndex(left, i.Value)
+		if !ok {
+			return newError("index out of range: %d; line=%d", i.Value, node.Line())
+		}
+		if object.References(val, left) {
+			return newError("cannot store ARRAY inside itself; line=%d", node.Line())
+		}
+		left.Elements[offset] = val
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s; line=%d", index.Type(), node.Line())
+		}
+		if left.Frozen {
+			return newError("cannot change frozen HASH; line=%d", node.Line())
+		}
+		if object.References(val, left) {
+			return newError("cannot store HASH inside itself; line=%d", node.Line())
+		}
+		left.Set(key, val)
+
+	default:
+		return newError("index assignment not supported: %s; line=%d", left.Type(), node.Line())
+	}
+
+	return nil
+}
+
+func evalModuleMember(module *object.Module, index object.Object) object.Object {
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("module member must be STRING, got %s; line=%d", index.Type(), line)
+	}
+
+	member, ok := module.Members[name.Value]
+	if !ok {
+		return newError("module %s has no member %s; line=%d", module.Name, name.Value, line)
+	}
+
+	return member
+}
+
+func evalRegexMethod(regex *object.Regex, index object.Object) object.Object {
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError("regex method must be STRING, got %s; line=%d", index.Type(), line)
+	}
+
+	method, ok := regex.Method(name.Value)
+	if !ok {
+		return newError("regex has no method %s; line=%d", name.Value, line)
 	}
 
-	return &object.Hash{Pairs: pairs}
+	return method
 }
 
 func evalHashIndexExpression(hash, index object.Object) object.Object {
@@ -444,10 +900,10 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 		return newError("unusable as hash key: %s; line=%d", index.Type(), line)
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return NULL
 	}
 
-	return pair.Value
+	return value
 }
@@ -5,6 +5,7 @@ import (
 	"lorikeet/object"
 	"lorikeet/parser"
 	"testing"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -59,6 +60,10 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{"1.5 < 2.5", true},
+		{"math.nan < 1.0", false},
+		{"1.0 > math.nan", false},
+		{"math.nan != math.nan", true},
 	}
 
 	for _, tt := range tests {
@@ -78,6 +83,7 @@ func TestBangOperator(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		{`!strings.contains("a", "b")`, true},
 	}
 
 	for _, tt := range tests {
@@ -221,6 +227,18 @@ if (10 > 1) {
 			`999[1]`,
 			"index operator not supported: INTEGER; line=1",
 		},
+		{
+			"[1, 2][9223372036854775807 + 1]",
+			"index must be INTEGER, got BIGINT; line=1",
+		},
+		{
+			`"ab"["a"]`,
+			"index must be INTEGER, got STRING; line=1",
+		},
+		{
+			"let g = fn*() { yield 1 }; g()",
+			"generator functions are only supported by the vm; line=1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -328,6 +346,18 @@ addTwo(2);`
 	testIntegerObject(t, testEval(input), 4)
 }
 
+func TestMutualRecursion(t *testing.T) {
+	input := `
+fn outer(n) {
+  fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+  fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+  even(n)
+}
+outer(10);`
+
+	testBooleanObject(t, testEval(input), true)
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"Hello World!"`
 
@@ -342,6 +372,37 @@ func TestStringLiteral(t *testing.T) {
 	}
 }
 
+func TestUnicodeStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`len("héllo")`, "5"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[1:3]`, "él"},
+		{"'é'", "é"},
+		{"int('é')", "233"},
+		{"'a' < 'b'", "true"},
+		{`'é' in "héllo"`, "true"},
+		{`len("a\tb")`, "3"},
+		{`"\u00e9" == "é"`, "true"},
+		{`'\t' in "a\tb"`, "true"},
+		{`len('\t')`, "1"},
+		{`bytes("hé")`, `b'h\xc3\xa9'`},
+		{`bytes("hé")[1:]`, `b'\xc3\xa9'`},
+		{`string(bytes("hé"))`, "hé"},
+		{"string(bytes([255]))", "Error: argument to `string` is not valid UTF-8"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -482,8 +543,92 @@ func TestArrayIndexExpressions(t *testing.T) {
 		},
 		{
 			"[1, 2, 3][-1]",
+			3,
+		},
+		{
+			"[1, 2, 3][-4]",
 			nil,
 		},
+		{
+			"(5..10)[1]",
+			6,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []int
+	}{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		array, ok := evaluated.(*object.Array)
+		if !ok {
+			t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if len(array.Elements) != len(tt.expected) {
+			t.Errorf("wrong num of elements. want=%d, got=%d",
+				len(tt.expected), len(array.Elements))
+			continue
+		}
+
+		for i, expectedElem := range tt.expected {
+			testIntegerObject(t, array.Elements[i], int64(expectedElem))
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1..4", "1..4"},
+		{"1..=4", "1..=4"},
+		{"(0..10)[2:5]", "2..5"},
+		{`"hello world"[:5]`, "hello"},
+		{"(5..9223372036854775807)[-1]", "9223372036854775806"},
+		{"len(0..=9223372036854775807)", "Error: range too large: 0..=9223372036854775807; line=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong value. want=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestNullSafeOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 ?? 2", 1},
+		{"[][0] ?? 2", 2},
+		{`let h = {"a": {"b": 5}}; h?.a?.b`, 5},
+		{`let h = {"a": {"b": 5}}; h?.c?.b`, nil},
+		{`let h = {"a": [1, 2]}; h?.c?[0] ?? 3`, 3},
+		{"[][0]?[1:]", nil},
 	}
 
 	for _, tt := range tests {
@@ -497,6 +642,126 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestEnumsAndMatch(t *testing.T) {
+	shapes := `
+	enum Shape { Circle(r), Rect(w, h), Empty }
+	let area = fn(s) {
+		match (s) {
+			Circle(r) => 3 * r * r,
+			Rect(w, h) => { return w * h; },
+			Empty => 0,
+		}
+	};
+	`
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{shapes + "area(Circle(2))", 12},
+		{shapes + "area(Rect(2, 5))", 10},
+		{shapes + "area(Empty)", 0},
+		{shapes + "area(1)", nil},
+		{shapes + "Rect(2, 5)", "Rect(2, 5)"},
+		{shapes + "Rect(2)", "wrong number of arguments: want=2, got=1; line=10"},
+		{shapes + "match (Circle(1)) { Rect(w, h) => w, _ => 7 }", 7},
+		{`match (2) { 1 => 10, 2 => 20 }`, 20},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected && evaluated.Inspect() != "Error: "+expected {
+				t.Errorf("wrong value. want=%q, got=%q", expected, evaluated.Inspect())
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestPipelinesAndPartials(t *testing.T) {
+	funcs := `
+	let add = fn(a, b) { a + b };
+	let double = fn(x) { x * 2 };
+	`
+
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{funcs + "[1, 2] |> push(_, 4) |> len", 3},
+		{funcs + "5 |> double", 10},
+		{funcs + "let inc = add(1, _); inc(10)", 11},
+		{funcs + "3 |> add(10, _) |> double", 26},
+		{funcs + "let f = add(1, _) >> double; f(2)", 6},
+		{funcs + "let _0 = 5; let _2 = fn(x) { x + _0 }; let f = add(_0, _) >> _2; f(1)", 11},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"[1, 2] == [1, 2]", true},
+		{"[1, [2, 3]] != [1, [2, 4]]", true},
+		{"[1, 2] < [1, 3]", true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{"[][0] == {}[0]", true},
+		{`"b" > "a"`, true},
+		{"1..3 == 1..=2", true},
+		{"enum E { A(x), B } A([1]) == A([1])", true},
+		{`match ([1, 2]) { [1, 2] => true, _ => false }`, true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4611686018427387904 * 4", "18446744073709551616"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"(9223372036854775807 + 1) - 1", "9223372036854775807"},
+		{"99999999999999999999 + 1", "100000000000000000000"},
+		{"-9223372036854775808 == -9223372036854775807 - 1", "true"},
+		{"9223372036854775807 + 1 > 9223372036854775807", "true"},
+		{"9223372036854775807 + 1 == 9223372036854775807 + 1", "true"},
+		{`int("100000000000000000000") / 10`, "10000000000000000000"},
+		{`string(9223372036854775807 * 2)`, "18446744073709551614"},
+		{`{9223372036854775807 + 1: "big"}[9223372036854775807 + 1]`, "big"},
+		{"(9223372036854775807 + 1)..1", "Error: range bounds must be INTEGER, got BIGINT..INTEGER; line=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	if _, ok := testEval("(9223372036854775807 + 1) - 1").(*object.Integer); !ok {
+		t.Errorf("result that fits an int64 is not an Integer")
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -514,26 +779,328 @@ func TestHashLiterals(t *testing.T) {
 		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
 	}
 
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		TRUE.HashKey():                             5,
-		FALSE.HashKey():                            6,
+	expected := map[object.Hashable]int64{
+		&object.String{Value: "one"}:   1,
+		&object.String{Value: "two"}:   2,
+		&object.String{Value: "three"}: 3,
+		&object.Integer{Value: 4}:      4,
+		TRUE:                           5,
+		FALSE:                          6,
 	}
 
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
 	}
 
 	for expectedKey, expectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
+		value, ok := result.Get(expectedKey)
 		if !ok {
 			t.Errorf("no pair for given key in Pairs")
 		}
 
-		testIntegerObject(t, pair.Value, expectedValue)
+		testIntegerObject(t, value, expectedValue)
+	}
+}
+
+func TestHashKeysAndOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{1.5: "a"}[1.5]`, "a"},
+		{`{[1, 2]: "pair"}[[1, 2]]`, "pair"},
+		{`{[][0]: "null"}[{}["missing"]]`, "null"},
+		{`{"b": 1, "a": 2, 3: 4}`, "{b: 1, a: 2, 3: 4}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+		{`{{}: 1}`, "Error: unusable as hash key: HASH; line=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTuplesAndSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(1, "a")`, "(1, a)"},
+		{"(1,)", "(1,)"},
+		{"(1, 2, 3)[1:]", "(2, 3)"},
+		{"let (a, b) = (1, 2); [b, a]", "[2, 1]"},
+		{"{(1, 2): 3}[(1, 2)]", "3"},
+		{"#{1, 2, 2, 3}", "#{1, 2, 3}"},
+		{"#{1, 2} | #{2, 3}", "#{1, 2, 3}"},
+		{"#{1, 2} & #{2, 3}", "#{2}"},
+		{"#{1, 2} - #{2, 3}", "#{1}"},
+		{"2 in #{1, 2}", "true"},
+		{"3 in [1, 2]", "false"},
+		{`"ell" in "hello"`, "true"},
+		{"#{{}}", "Error: unusable as set element: HASH; line=1"},
+		{"let (a, b) = [1];", "Error: cannot destructure ARRAY of 1 elements into 2 names; line=1"},
+		{"1 in 2", "Error: unsupported types for in: INTEGER in INTEGER; line=1"},
+		{"#{1} + #{2}", "Error: unknown operator: SET + SET; line=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestIndexAssignmentAndFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1, 2, 3]; a[0] = 4; a[-1] = 5; a", "[4, 2, 5]"},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] = 3; h`, "{a: 3, b: 2}"},
+		{"let a = [[1]]; let b = a[0]; b[0] = 2; a", "[[2]]"},
+		{"const a = [1, [2]]; a == [1, [2]]", "true"},
+		{"const a = [1]; a[0] = 2;", "Error: cannot change frozen ARRAY; line=1"},
+		{"const h = {1: [2]}; let a = h[1]; a[0] = 3;", "Error: cannot change frozen ARRAY; line=1"},
+		{`let h = freeze({"a": 1}); h["b"] = 2;`, "Error: cannot change frozen HASH; line=1"},
+		{"let k = [1]; let h = {k: 1}; k[0] = 2;", "Error: cannot change frozen ARRAY; line=1"},
+		{"let a = [1]; a[1] = 2;", "Error: index out of range: 1; line=1"},
+		{"let a = [1]; a[0] = [a];", "Error: cannot store ARRAY inside itself; line=1"},
+		{`let s = "a"; s[0] = "b";`, "Error: index assignment not supported: STRING; line=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{`map(["a", "bb"], len)`, "[1, 2]"},
+		{"filter(1..5, fn(x) { x > 2 })", "[3, 4]"},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)", "16"},
+		{"each([1], fn(x) { x })", "null"},
+		{"find([1, 2, 3], fn(x) { x > 1 })", "2"},
+		{"if (any([1, 2], fn(x) { x > 5 })) { 1 } else { 2 }", "2"},
+		{"all([1, 2], fn(x) { x > 0 })", "true"},
+		{`sort_by(["ccc", "a", "bb"], len)`, "[a, bb, ccc]"},
+		{"map([1, 0], fn(x) { 1 / x })", "Error: division by zero; line=1"},
+		{"map([1], fn(a, b) { a })", "Error: wrong number of arguments: want=2, got=1; line=1"},
+		{"filter([1], 1)", "Error: argument to `filter` must be a function, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringsModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`strings.split("a,b", ",")`, "[a, b]"},
+		{`strings.join(strings.chars("hé"), "-")`, "h-é"},
+		{`strings.index_of("héllo", "l")`, "2"},
+		{`strings.pad_left("7", 3, '0')`, "007"},
+		{`map(["a"], strings.upper)`, "[A]"},
+		{`fn() { strings.lower("A") }()`, "a"},
+		{`strings.upper("a\nb")`, "A\nB"},
+		{`len(strings.chars("a\nb"))`, "3"},
+		{`strings.lower("\U0001F600")`, "😀"},
+		{"strings.nope", "Error: module strings has no member nope; line=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format("%-10s|%6.2f", "apple", 1.5)`, "apple     |  1.50"},
+		{`format("%05d %x %v", 42, 255, [1, "a"])`, "00042 ff [1, a]"},
+		{`format("%d", "a")`, "Error: %d in `format` needs INTEGER, got STRING for argument 2"},
+		{`format("%d %d", 1)`, "Error: missing argument for %d in `format`"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"let f = fn() { exit(3); 1 }; f() + 1", 3},
+		{"say(exit(4)); 1", 4},
+		{"map([1, 2], fn(x) { exit(5) }); 1", 5},
+	}
+
+	for _, tt := range tests {
+		exit, ok := testEval(tt.input).(*object.Exit)
+		if !ok {
+			t.Errorf("result of %q is not Exit", tt.input)
+			continue
+		}
+		if exit.Code != tt.expected {
+			t.Errorf("wrong exit code for %q. want=%d, got=%d", tt.input, tt.expected, exit.Code)
+		}
+	}
+}
+
+func TestFileSystemNeedsAccess(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fs.read_file("a.txt")`, "Error: permission denied: `fs.read_file` needs file system access, run with --allow-fs=DIR"},
+		{`path.join("a", "b.txt") |> path.ext`, ".txt"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRegex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`re("\\d+").find_all("a 12 b 34")`, "[12, 34]"},
+		{`re("(\\d+)-(\\d+)").replace("1-2", fn(m) { m[2] + "-" + m[1] })`, "2-1"},
+		{`re("(?P<word>\\w+)").named("hi there")`, "{word: hi}"},
+		{`re("a").nope`, "Error: regex has no method nope; line=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	object.CurrentClock = object.NewFakeClock(time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC))
+	defer func() { object.CurrentClock = object.SystemClock{} }()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let t = now(); sleep(1500); since(t)", "1.5s"},
+		{"now() - time.hours(1)", "2024-01-02T02:04:06.5Z"},
+		{"-(time.seconds(3) * 2 + time.ms(1))", "-6.001s"},
+		{"time.seconds(3) / time.ms(1)", "3000"},
+		{"time.ms(1) < time.seconds(1)", "true"},
+		{"time.seconds(1) / 0", "Error: division by zero; line=1"},
+		{"now() * 2", "Error: unknown operator: TIME * INTEGER; line=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"seed(3); let a = [random(), random_int(1, 6), choice(1..=6)]; seed(3); a == [random(), random_int(1, 6), choice(1..=6)]", "true"},
+		{"sort_by(shuffle([2, 3, 1]), fn(x) { x })", "[1, 2, 3]"},
+		{"random_int(2, 1)", "Error: arguments to `random_int` must not be in reverse order, got 2 and 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_parse("{\"b\": 1, \"a\": [true, null, 1.5]}")`, "{b: 1, a: [true, null, 1.5]}"},
+		{`json_stringify({"a": [1, 2.0, "x"]})`, `{"a":[1,2.0,"x"]}`},
+		{`json_parse("[1,\n x]")`, "Error: invalid JSON at line 2, column 2: unexpected character 'x'"},
+		{`json_stringify({1: 2})`, "Error: hash keys must be STRING to encode as JSON, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"math.pow(2, 10)", "1024"},
+		{"math.floor(2.7) + math.ceil(2.1)", "5"},
+		{"math.max([1, 5, 3])", "5"},
+		{"math.gcd(12, 18)", "6"},
+		{"math.is_nan(math.nan)", "true"},
+		{`math.sqrt("a")`, "Error: argument 1 to `math.sqrt` must be INTEGER or FLOAT, got STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
 	}
 }
 
@@ -35,6 +35,11 @@ func (l *Lexer) NextToken() token.Token {
 			l.readRune()
 			literal := string(ch) + string(l.ru)
 			tok = token.Token{Type: token.EQ, Literal: literal, Line: l.linePosition}
+		} else if l.peekRune() == '>' {
+			ch := l.ru
+			l.readRune()
+			literal := string(ch) + string(l.ru)
+			tok = token.Token{Type: token.ARROW, Literal: literal, Line: l.linePosition}
 		} else {
 			tok = newToken(token.ASSIGN, l.ru, l.linePosition)
 		}
@@ -51,7 +56,12 @@ func (l *Lexer) NextToken() token.Token {
 	case '+':
 		tok = newToken(token.PLUS, l.ru, l.linePosition)
 	case '-':
-		tok = newToken(token.MINUS, l.ru, l.linePosition)
+		if l.peekRune() == '>' {
+			l.readRune()
+			tok = token.Token{Type: token.RARROW, Literal: "->", Line: l.linePosition}
+		} else {
+			tok = newToken(token.MINUS, l.ru, l.linePosition)
+		}
 	case '{':
 		tok = newToken(token.LBRACE, l.ru, l.linePosition)
 	case '}':
@@ -66,6 +76,15 @@ func (l *Lexer) NextToken() token.Token {
 			l.readRune()
 			literal := string(ch) + string(l.ru)
 			tok = token.Token{Type: token.PIPE, Literal: literal, Line: l.linePosition}
+		} else {
+			tok = newToken(token.BAR, l.ru, l.linePosition)
+		}
+	case '&':
+		tok = newToken(token.AMPERSAND, l.ru, l.linePosition)
+	case '#':
+		if l.peekRune() == '{' {
+			l.readRune()
+			tok = token.Token{Type: token.SETOPEN, Literal: "#{", Line: l.linePosition}
 		} else {
 			tok = newToken(token.ILLEGAL, l.ru, l.linePosition)
 		}
@@ -85,13 +104,48 @@ func (l *Lexer) NextToken() token.Token {
 	case '<':
 		tok = newToken(token.LT, l.ru, l.linePosition)
 	case '>':
-		tok = newToken(token.GT, l.ru, l.linePosition)
+		if l.peekRune() == '>' {
+			l.readRune()
+			tok = token.Token{Type: token.COMPOSE, Literal: ">>", Line: l.linePosition}
+		} else {
+			tok = newToken(token.GT, l.ru, l.linePosition)
+		}
 	case '$':
 		tok = newToken(token.MONEY, l.ru, l.linePosition)
+	case '?':
+		switch l.peekRune() {
+		case '?':
+			l.readRune()
+			tok = token.Token{Type: token.COALESCE, Literal: "??", Line: l.linePosition}
+		case '.':
+			l.readRune()
+			tok = token.Token{Type: token.OPTDOT, Literal: "?.", Line: l.linePosition}
+		case '[':
+			l.readRune()
+			tok = token.Token{Type: token.OPTINDEX, Literal: "?[", Line: l.linePosition}
+		default:
+			tok = newToken(token.ILLEGAL, l.ru, l.linePosition)
+		}
+	case '.':
+		if l.peekRune() == '.' {
+			l.readRune()
+			if l.peekRune() == '=' {
+				l.readRune()
+				tok = token.Token{Type: token.RANGEINCL, Literal: "..=", Line: l.linePosition}
+			} else {
+				tok = token.Token{Type: token.RANGE, Literal: "..", Line: l.linePosition}
+			}
+		} else {
+			tok = newToken(token.DOT, l.ru, l.linePosition)
+		}
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
 		tok.Line = l.linePosition
+	case '\'':
+		tok.Type = token.CHAR
+		tok.Literal = l.readChar()
+		tok.Line = l.linePosition
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -101,6 +155,11 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			tok.Line = l.linePosition
+			// fn* starts a generator function
+			if tok.Type == token.FUNCTION && l.ru == '*' {
+				tok.Literal += string(l.ru)
+				l.readRune()
+			}
 			return tok
 		} else if isDigit(l.ru) {
 			tok.Literal, tok.Type = l.readNumber()
@@ -116,7 +175,8 @@ func (l *Lexer) NextToken() token.Token {
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ru) {
+	// Digits can follow the first letter, like log10
+	for isLetter(l.ru) || isDigit(l.ru) {
 		l.readRune()
 	}
 	return string(l.input[position:l.position])
@@ -149,7 +209,7 @@ func (l *Lexer) skipWhitespace() {
 
 func (l *Lexer) skipComments() {
 	for l.ru == '/' && l.peekRune() == '/' {
-		for l.ru != '\n' {
+		for l.ru != '\n' && l.ru != 0 {
 			l.readRune()
 		}
 		l.skipWhitespace()
@@ -161,7 +221,7 @@ func (l *Lexer) readNumber() (string, token.Type) {
 	for isDigit(l.ru) {
 		l.readRune()
 	}
-	if isDecimal(l.ru) {
+	if isDecimal(l.ru) && isDigit(l.peekRune()) {
 		l.readRune()
 		for isDigit(l.ru) {
 			l.readRune()
@@ -179,10 +239,16 @@ func (l *Lexer) peekRune() rune {
 	return l.input[l.readPosition]
 }
 
+// readString reads up to the closing quote, a backslash
+// escapes the rune after it
 func (l *Lexer) readString() string {
 	position := l.position + 1
 	for {
 		l.readRune()
+		if l.ru == '\\' && l.peekRune() != 0 {
+			l.readRune()
+			continue
+		}
 		if l.ru == '"' || l.ru == 0 {
 			break
 		}
@@ -190,6 +256,23 @@ func (l *Lexer) readString() string {
 	return string(l.input[position:l.position])
 }
 
+// readChar reads up to the closing quote, a backslash
+// escapes the rune after it
+func (l *Lexer) readChar() string {
+	position := l.position + 1
+	for {
+		l.readRune()
+		if l.ru == '\\' && l.peekRune() != 0 {
+			l.readRune()
+			continue
+		}
+		if l.ru == '\'' || l.ru == 0 {
+			break
+		}
+	}
+	return string(l.input[position:l.position])
+}
+
 // Allowed identifier chars
 func isLetter(ru rune) bool {
 	return ru == '_' || unicode.IsLetter(ru)
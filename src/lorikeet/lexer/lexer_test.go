@@ -39,6 +39,17 @@ func TestNextToken(t *testing.T) {
 		 1.5;
 		 fn test() {}
 		 let mut ten = 10;
+		 1..10 1..=x a[1:]
+		 a ?? b?.c?[0]
+		 enum E { A(x) } match (e) { A(x) => x }
+		 fn* g() { yield 1 }
+		 f >> g
+		 let x: [int] -> int
+		 #{1} | a & b in c
+		 'a' '\''
+		 const c = 1; a[0] = 2;
+		 strings.upper math.log10 a1b2
+		 "a\"b" "c\\"
 		`
 
 	tests := []struct {
@@ -179,7 +190,97 @@ func TestNextToken(t *testing.T) {
 		{token.ASSIGN, "=", 31},
 		{token.INT, "10", 31},
 		{token.SEMICOLON, ";", 31},
-		{token.EOF, "", 32},
+		{token.INT, "1", 32},
+		{token.RANGE, "..", 32},
+		{token.INT, "10", 32},
+		{token.INT, "1", 32},
+		{token.RANGEINCL, "..=", 32},
+		{token.IDENT, "x", 32},
+		{token.IDENT, "a", 32},
+		{token.LBRACKET, "[", 32},
+		{token.INT, "1", 32},
+		{token.COLON, ":", 32},
+		{token.RBRACKET, "]", 32},
+		{token.IDENT, "a", 33},
+		{token.COALESCE, "??", 33},
+		{token.IDENT, "b", 33},
+		{token.OPTDOT, "?.", 33},
+		{token.IDENT, "c", 33},
+		{token.OPTINDEX, "?[", 33},
+		{token.INT, "0", 33},
+		{token.RBRACKET, "]", 33},
+		{token.ENUM, "enum", 34},
+		{token.IDENT, "E", 34},
+		{token.LBRACE, "{", 34},
+		{token.IDENT, "A", 34},
+		{token.LPAREN, "(", 34},
+		{token.IDENT, "x", 34},
+		{token.RPAREN, ")", 34},
+		{token.RBRACE, "}", 34},
+		{token.MATCH, "match", 34},
+		{token.LPAREN, "(", 34},
+		{token.IDENT, "e", 34},
+		{token.RPAREN, ")", 34},
+		{token.LBRACE, "{", 34},
+		{token.IDENT, "A", 34},
+		{token.LPAREN, "(", 34},
+		{token.IDENT, "x", 34},
+		{token.RPAREN, ")", 34},
+		{token.ARROW, "=>", 34},
+		{token.IDENT, "x", 34},
+		{token.RBRACE, "}", 34},
+		{token.FUNCTION, "fn*", 35},
+		{token.IDENT, "g", 35},
+		{token.LPAREN, "(", 35},
+		{token.RPAREN, ")", 35},
+		{token.LBRACE, "{", 35},
+		{token.YIELD, "yield", 35},
+		{token.INT, "1", 35},
+		{token.RBRACE, "}", 35},
+		{token.IDENT, "f", 36},
+		{token.COMPOSE, ">>", 36},
+		{token.IDENT, "g", 36},
+		{token.LET, "let", 37},
+		{token.IDENT, "x", 37},
+		{token.COLON, ":", 37},
+		{token.LBRACKET, "[", 37},
+		{token.IDENT, "int", 37},
+		{token.RBRACKET, "]", 37},
+		{token.RARROW, "->", 37},
+		{token.IDENT, "int", 37},
+		{token.SETOPEN, "#{", 38},
+		{token.INT, "1", 38},
+		{token.RBRACE, "}", 38},
+		{token.BAR, "|", 38},
+		{token.IDENT, "a", 38},
+		{token.AMPERSAND, "&", 38},
+		{token.IDENT, "b", 38},
+		{token.IN, "in", 38},
+		{token.IDENT, "c", 38},
+		{token.CHAR, "a", 39},
+		{token.CHAR, `\'`, 39},
+		{token.CONST, "const", 40},
+		{token.IDENT, "c", 40},
+		{token.ASSIGN, "=", 40},
+		{token.INT, "1", 40},
+		{token.SEMICOLON, ";", 40},
+		{token.IDENT, "a", 40},
+		{token.LBRACKET, "[", 40},
+		{token.INT, "0", 40},
+		{token.RBRACKET, "]", 40},
+		{token.ASSIGN, "=", 40},
+		{token.INT, "2", 40},
+		{token.SEMICOLON, ";", 40},
+		{token.IDENT, "strings", 41},
+		{token.DOT, ".", 41},
+		{token.IDENT, "upper", 41},
+		{token.IDENT, "math", 41},
+		{token.DOT, ".", 41},
+		{token.IDENT, "log10", 41},
+		{token.IDENT, "a1b2", 41},
+		{token.STRING, `a\"b`, 42},
+		{token.STRING, `c\\`, 42},
+		{token.EOF, "", 43},
 	}
 
 	l := New(input)
@@ -203,3 +304,25 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestCommentAtEndOfInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Type
+	}{
+		{"1 // x", []token.Type{token.INT, token.EOF}},
+		{"//", []token.Type{token.EOF}},
+		{"// a\n// b", []token.Type{token.EOF}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, want := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != want {
+				t.Fatalf("%q tokens[%d] - tokentype wrong. expected=%q, got=%q",
+					tt.input, i, want, tok.Type)
+			}
+		}
+	}
+}
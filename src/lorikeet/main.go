@@ -10,50 +10,152 @@ import (
 	"lorikeet/object"
 	"lorikeet/parser"
 	"lorikeet/repl"
+	"lorikeet/types"
 	"lorikeet/vm"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var file string
+var allowFS string
+var fakeClock string
+var seed *int64
+var maxCalls int
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(check(os.Args[2:]))
+	}
+
 	flag.StringVar(&file, "file", "", "file path to execute")
+	flag.StringVar(&allowFS, "allow-fs", "", "comma separated directories the fs module may use")
+	flag.StringVar(&fakeClock, "fake-clock", "", "RFC 3339 time to stop the clock at, sleep advances it without waiting")
+	flag.IntVar(&maxCalls, "max-calls", 0, "stop the program after this many function calls, 0 for no limit")
+	flag.Func("seed", "seed for the random builtins, the same seed gives the same numbers", func(s string) error {
+		n, err := strconv.ParseInt(s, 10, 64)
+		seed = &n
+		return err
+	})
 	flag.Parse()
 
+	if allowFS != "" {
+		for _, root := range strings.Split(allowFS, ",") {
+			if err := object.AllowFileSystem(root); err != nil {
+				fmt.Fprintf(os.Stderr, "could not allow file system access: %s\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	if fakeClock != "" {
+		start, err := time.Parse(time.RFC3339, fakeClock)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not set fake clock: %s\n", err)
+			os.Exit(1)
+		}
+		object.CurrentClock = object.NewFakeClock(start)
+	}
+
 	if file == "" {
 		fmt.Printf("The Lorikeet programming language!\n")
 		repl.Start(os.Stdin, os.Stdout)
 		return
 	}
 
+	object.Args = flag.Args()
+	os.Exit(run(file))
+}
+
+// run runs a file and returns the exit status, errors are written to
+// stderr and give status 1
+func run(file string) int {
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		fmt.Printf("could not read file!\n")
+		fmt.Fprintf(os.Stderr, "could not read file %s!\n", file)
+		return 1
 	}
 
 	l := lexer.New(string(data))
 	p := parser.New(l)
 	program := p.ParseProgram()
 	if len(p.Errors()) != 0 {
-		repl.PrintParserErrors(os.Stdout, p.Errors())
-		return
+		repl.PrintParserErrors(os.Stderr, p.Errors())
+		return 1
+	}
+
+	checker := types.New()
+	if errors := checker.Check(program); len(errors) != 0 {
+		repl.PrintTypeErrors(os.Stderr, errors)
+		return 1
+	}
+
+	for _, warning := range checker.Warnings() {
+		fmt.Fprintf(os.Stderr, "type warning: %s\n", warning)
 	}
 
 	comp := compiler.New()
 	err = comp.Compile(program)
 	if err != nil {
-		fmt.Printf("compiler error: %s", err)
-		return
+		fmt.Fprintf(os.Stderr, "compiler error: %s\n", err)
+		return 1
+	}
+
+	for _, warning := range comp.Warnings() {
+		fmt.Fprintf(os.Stderr, "compiler warning: %s\n", warning)
 	}
 
 	object.Scanner = bufio.NewScanner(os.Stdin)
 
 	machine := vm.New(comp.Bytecode())
+	if seed != nil {
+		machine.SetRand(object.NewRand(*seed))
+	}
+	machine.SetMaxCalls(maxCalls)
 
 	err = machine.Run()
+	if exit, ok := err.(*object.Exit); ok {
+		return exit.Code
+	}
 	if err != nil {
-		fmt.Printf("vm error: %s", err)
-		return
+		fmt.Fprintf(os.Stderr, "vm error: %s\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// check type checks the files passed to `lorikeet check` without running
+// them, warnings fail the check like errors
+func check(files []string) int {
+	status := 0
+
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not read file %s!\n", file)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(data)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			fmt.Fprintf(os.Stderr, "%s:\n", file)
+			repl.PrintParserErrors(os.Stderr, p.Errors())
+			status = 1
+			continue
+		}
+
+		checker := types.New()
+		errors := append(checker.Check(program), checker.Warnings()...)
+		if len(errors) != 0 {
+			fmt.Fprintf(os.Stderr, "%s:\n", file)
+			repl.PrintTypeErrors(os.Stderr, errors)
+			status = 1
+		}
 	}
 
+	return status
 }
@@ -0,0 +1,138 @@
+package object
+
+import (
+	"hash/fnv"
+	"math"
+	"math/big"
+)
+
+// BigInt object holds integers that do not fit in an Integer,
+// results that fit again are turned back into an Integer
+type BigInt struct {
+	Value *big.Int
+}
+
+// Type will return the big integer type "BIGINT"
+func (b *BigInt) Type() Type { return BIGINT }
+
+// Inspect will return the BigInt value
+func (b *BigInt) Inspect() string { return b.Value.String() }
+
+// HashKey BigInt
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	if b.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+	h.Write(b.Value.Bytes())
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+// NewInteger returns an Integer when value fits in an int64,
+// otherwise a BigInt
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+	return &BigInt{Value: value}
+}
+
+// IsInteger reports whether obj is an INTEGER or a BIGINT
+func IsInteger(obj Object) bool {
+	switch obj.(type) {
+	case *Integer, *BigInt:
+		return true
+	}
+	return false
+}
+
+// BigValue returns the value of an INTEGER or BIGINT as a big.Int
+func BigValue(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInt:
+		return obj.Value
+	}
+	return nil
+}
+
+// IntegerOperation applies the arithmetic operator (+, -, * or /) to
+// two INTEGER or BIGINT objects, results that overflow an int64 are
+// promoted to a BigInt
+func IntegerOperation(operator string, left, right Object) Object {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if lok && rok {
+		if result, ok := int64Operation(operator, l.Value, r.Value); ok {
+			return &Integer{Value: result}
+		}
+	}
+
+	a, b := BigValue(left), BigValue(right)
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		result.Add(a, b)
+	case "-":
+		result.Sub(a, b)
+	case "*":
+		result.Mul(a, b)
+	case "/":
+		result.Quo(a, b)
+	}
+	return NewInteger(result)
+}
+
+// int64Operation returns false when the result overflows
+func int64Operation(operator string, a, b int64) (int64, bool) {
+	switch operator {
+	case "+":
+		r := a + b
+		return r, (a^r)&(b^r) >= 0
+	case "-":
+		r := a - b
+		return r, (a^b)&(a^r) >= 0
+	case "*":
+		if a == 0 || b == 0 {
+			return 0, true
+		}
+		r := a * b
+		overflow := r/b != a ||
+			(a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64)
+		return r, !overflow
+	case "/":
+		if a == math.MinInt64 && b == -1 {
+			return 0, false
+		}
+		return a / b, true
+	}
+	return 0, false
+}
+
+// NegateInteger returns -obj of an INTEGER or BIGINT
+func NegateInteger(obj Object) Object {
+	if i, ok := obj.(*Integer); ok && i.Value != math.MinInt64 {
+		return &Integer{Value: -i.Value}
+	}
+	return NewInteger(new(big.Int).Neg(BigValue(obj)))
+}
+
+// CompareIntegers returns -1, 0 or +1 depending on whether the INTEGER
+// or BIGINT left is less than, equal to or greater than right
+func CompareIntegers(left, right Object) int {
+	l, lok := left.(*Integer)
+	r, rok := right.(*Integer)
+	if lok && rok {
+		switch {
+		case l.Value < r.Value:
+			return -1
+		case l.Value > r.Value:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return BigValue(left).Cmp(BigValue(right))
+}
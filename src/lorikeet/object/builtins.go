@@ -3,7 +3,14 @@ package object
 import (
 	"bufio"
 	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 // Scanner for get()
@@ -23,10 +30,12 @@ var Builtins = []struct {
 			}
 
 			switch arg := args[0].(type) {
-			case *Array:
-				return &Integer{Value: int64(len(arg.Elements))}
-			case *String:
-				return &Integer{Value: int64(len(arg.Value))}
+			case Iterable:
+				return &Integer{Value: arg.Len()}
+			case *Set:
+				return &Integer{Value: int64(arg.Len())}
+			case *Char:
+				return &Integer{Value: 1}
 			default:
 				return newError("argument to `len` not supported, got %s",
 					args[0].Type())
@@ -37,13 +46,11 @@ var Builtins = []struct {
 	{
 		"say",
 		&Builtin{Fn: func(args ...Object) Object {
-			for _, arg := range args {
-				s, err := strconv.Unquote(`"` + arg.Inspect() + `"`)
-				if err != nil {
-					panic(err)
-				}
-				fmt.Print(s)
+			out := make([]string, len(args))
+			for i, arg := range args {
+				out[i] = arg.Inspect()
 			}
+			fmt.Print(strings.Join(out, ""))
 			fmt.Print("\n")
 			return nil
 		},
@@ -67,8 +74,7 @@ var Builtins = []struct {
 				fmt.Print(arr.Inspect())
 			}
 
-			scanned := Scanner.Scan()
-			if !scanned {
+			if Scanner == nil || !Scanner.Scan() {
 				return nil
 			}
 
@@ -176,19 +182,24 @@ var Builtins = []struct {
 
 			switch arg := args[0].(type) {
 			case *Float:
-				return &Integer{Value: int64(arg.Value)}
+				return floatToInteger(arg.Value)
 			case *String:
 				val, err := strconv.ParseInt(arg.Value, 10, 64)
 				if err != nil {
+					if value, ok := new(big.Int).SetString(arg.Value, 10); ok {
+						return NewInteger(value)
+					}
 					flval, err := strconv.ParseFloat(arg.Value, 64)
 					if err != nil {
 						return nil
 					}
-					return &Integer{Value: int64(flval)}
+					return floatToInteger(flval)
 				}
 				return &Integer{Value: val}
-			case *Integer:
+			case *Integer, *BigInt:
 				return arg
+			case *Char:
+				return &Integer{Value: int64(arg.Value)}
 			default:
 				return newError("argument to `int` not supported, got %s",
 					args[0].Type())
@@ -207,6 +218,9 @@ var Builtins = []struct {
 			switch arg := args[0].(type) {
 			case *Integer:
 				return &Float{Value: float64(arg.Value)}
+			case *BigInt:
+				val, _ := new(big.Float).SetInt(arg.Value).Float64()
+				return &Float{Value: val}
 			case *String:
 				val, err := strconv.ParseFloat(arg.Value, 64)
 				if err != nil {
@@ -233,8 +247,13 @@ var Builtins = []struct {
 			switch arg := args[0].(type) {
 			case *Float:
 				return &String{Value: fmt.Sprintf("%g", arg.Value)}
-			case *Integer:
-				return &String{Value: fmt.Sprintf("%d", arg.Value)}
+			case *Integer, *BigInt, *Char, *Time, *Duration:
+				return &String{Value: arg.Inspect()}
+			case *Bytes:
+				if !utf8.Valid(arg.Value) {
+					return newError("argument to `string` is not valid UTF-8")
+				}
+				return &String{Value: string(arg.Value)}
 			case *String:
 				return arg
 			default:
@@ -244,6 +263,585 @@ var Builtins = []struct {
 		},
 		},
 	},
+	{
+		"done",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			if args[0].Type() != GENERATOR {
+				return newError("argument to `done` must be GENERATOR, got %s",
+					args[0].Type())
+			}
+
+			return &Boolean{Value: args[0].(*Generator).Done}
+		},
+		},
+	},
+	{
+		"char",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer:
+				if arg.Value > utf8.MaxRune || !utf8.ValidRune(rune(arg.Value)) {
+					return newError("argument to `char` is not a code point, got %d", arg.Value)
+				}
+				return &Char{Value: rune(arg.Value)}
+			case *String:
+				if utf8.RuneCountInString(arg.Value) != 1 {
+					return newError("argument to `char` must be a single character, got %q", arg.Value)
+				}
+				r, _ := utf8.DecodeRuneInString(arg.Value)
+				return &Char{Value: r}
+			case *Char:
+				return arg
+			default:
+				return newError("argument to `char` not supported, got %s",
+					args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"bytes",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *String:
+				return &Bytes{Value: []byte(arg.Value)}
+			case *Array:
+				value := make([]byte, len(arg.Elements))
+				for i, el := range arg.Elements {
+					b, ok := el.(*Integer)
+					if !ok || b.Value < 0 || b.Value > 255 {
+						return newError("argument to `bytes` must be an ARRAY of integers 0 to 255, got %s at %d",
+							el.Inspect(), i)
+					}
+					value[i] = byte(b.Value)
+				}
+				return &Bytes{Value: value}
+			case *Bytes:
+				return arg
+			default:
+				return newError("argument to `bytes` not supported, got %s",
+					args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"freeze",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+
+			return Freeze(args[0])
+		},
+		},
+	},
+	{
+		"map",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			gen, errObj := generatorArg("map", args)
+			if errObj != nil {
+				return errObj, nil
+			}
+			if gen != nil {
+				return mapGenerator(gen, args[1]), nil
+			}
+
+			iterable, fn, errObj := callbackArgs("map", args, 2)
+			if errObj != nil {
+				return errObj, nil
+			}
+
+			elements := make([]Object, 0, iterable.Len())
+			for i := int64(0); i < iterable.Len(); i++ {
+				result, err := caller.Call(fn, iterable.At(i))
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, result)
+			}
+
+			return &Array{Elements: elements}, nil
+		},
+		},
+	},
+	{
+		"filter",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			gen, errObj := generatorArg("filter", args)
+			if errObj != nil {
+				return errObj, nil
+			}
+			if gen != nil {
+				return filterGenerator(gen, args[1]), nil
+			}
+
+			iterable, fn, errObj := callbackArgs("filter", args, 2)
+			if errObj != nil {
+				return errObj, nil
+			}
+
+			elements := []Object{}
+			for i := int64(0); i < iterable.Len(); i++ {
+				el := iterable.At(i)
+				result, err := caller.Call(fn, el)
+				if err != nil {
+					return nil, err
+				}
+				if isTruthy(result) {
+					elements = append(elements, el)
+				}
+			}
+
+			return &Array{Elements: elements}, nil
+		},
+		},
+	},
+	{
+		"reduce",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			if len(args) != 3 {
+				return newError("wrong number of arguments. got=%d, want=3",
+					len(args)), nil
+			}
+			iterable, fn, errObj := callbackArgs("reduce", args[:2], 2)
+			if errObj != nil {
+				return errObj, nil
+			}
+
+			acc := args[2]
+			for i := int64(0); i < iterable.Len(); i++ {
+				var err error
+				acc, err = caller.Call(fn, acc, iterable.At(i))
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return acc, nil
+		},
+		},
+	},
+	{
+		"each",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			iterable, fn, errObj := callbackArgs("each", args, 2)
+			if errObj != nil {
+				return errObj, nil
+			}
+
+			for i := int64(0); i < iterable.Len(); i++ {
+				_, err := caller.Call(fn, iterable.At(i))
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			return nil, nil
+		},
+		},
+	},
+	{
+		"find",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			iterable, fn, errObj := callbackArgs("find", args, 2)
+			if errObj != nil {
+				return errObj, nil
+			}
+
+			for i := int64(0); i < iterable.Len(); i++ {
+				el := iterable.At(i)
+				result, err := caller.Call(fn, el)
+				if err != nil {
+					return nil, err
+				}
+				if isTruthy(result) {
+					return el, nil
+				}
+			}
+
+			return nil, nil
+		},
+		},
+	},
+	{
+		"any",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			return matchAll("any", caller, args, true)
+		},
+		},
+	},
+	{
+		"all",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			return matchAll("all", caller, args, false)
+		},
+		},
+	},
+	{
+		"sort_by",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			iterable, fn, errObj := callbackArgs("sort_by", args, 2)
+			if errObj != nil {
+				return errObj, nil
+			}
+
+			type keyed struct {
+				key, el Object
+			}
+			pairs := make([]keyed, iterable.Len())
+			for i := range pairs {
+				el := iterable.At(int64(i))
+				key, err := caller.Call(fn, el)
+				if err != nil {
+					return nil, err
+				}
+				pairs[i] = keyed{key: key, el: el}
+			}
+
+			var failed *Error
+			sort.SliceStable(pairs, func(i, j int) bool {
+				cmp, ok := Compare(pairs[i].key, pairs[j].key)
+				if !ok && failed == nil {
+					failed = newError("keys of `sort_by` cannot be compared, got %s and %s",
+						pairs[i].key.Type(), pairs[j].key.Type())
+				}
+				return cmp < 0
+			})
+			if failed != nil {
+				return failed, nil
+			}
+
+			elements := make([]Object, len(pairs))
+			for i, pair := range pairs {
+				elements[i] = pair.el
+			}
+
+			return &Array{Elements: elements}, nil
+		},
+		},
+	},
+	{
+		"format",
+		&Builtin{Fn: func(args ...Object) Object {
+			s, err := Format("format", args)
+			if err != nil {
+				return err
+			}
+			return &String{Value: s}
+		},
+		},
+	},
+	{
+		"sayf",
+		&Builtin{Fn: func(args ...Object) Object {
+			s, err := Format("sayf", args)
+			if err != nil {
+				return err
+			}
+			fmt.Println(s)
+			return nil
+		},
+		},
+	},
+	{
+		"json_parse",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			s, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `json_parse` must be STRING, got %s",
+					args[0].Type())
+			}
+			value, errObj := ParseJSON(s.Value)
+			if errObj != nil {
+				return errObj
+			}
+			return value
+		},
+		},
+	},
+	{
+		"json_stringify",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+
+			indent := ""
+			if len(args) == 2 {
+				switch arg := args[1].(type) {
+				case *Integer:
+					// like JavaScript, indents are at most 10 spaces
+					if arg.Value < 0 {
+						return newError("argument 2 to `json_stringify` must not be negative, got %d",
+							arg.Value)
+					}
+					indent = strings.Repeat(" ", int(math.Min(float64(arg.Value), 10)))
+				case *String:
+					indent = arg.Value
+				default:
+					return newError("argument 2 to `json_stringify` must be INTEGER or STRING, got %s",
+						args[1].Type())
+				}
+			}
+
+			text, err := StringifyJSON(args[0], indent)
+			if err != nil {
+				return err
+			}
+			return &String{Value: text}
+		},
+		},
+	},
+	{
+		"args",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+
+			return stringArray(Args)
+		},
+		},
+	},
+	{
+		"env",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			name, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `env` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			value, ok := os.LookupEnv(name.Value)
+			if !ok {
+				return nil
+			}
+			return &String{Value: value}
+		},
+		},
+	},
+	{
+		"exit",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) > 1 {
+				return newError("wrong number of arguments, no more than 1. got=%d",
+					len(args))
+			}
+			if len(args) == 0 {
+				return &Exit{Code: 0}
+			}
+
+			code, ok := args[0].(*Integer)
+			if !ok || code.Value < 0 || code.Value > 255 {
+				return newError("argument to `exit` must be an INTEGER from 0 to 255, got %s",
+					args[0].Inspect())
+			}
+			return &Exit{Code: int(code.Value)}
+		},
+		},
+	},
+	{
+		"re",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			pattern, ok := args[0].(*String)
+			if !ok {
+				return newError("argument to `re` must be STRING, got %s",
+					args[0].Type())
+			}
+
+			r, err := CompileRegex(pattern.Value)
+			if err != nil {
+				return newError("invalid regex %q: %s", pattern.Value,
+					strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+			}
+			return r
+		},
+		},
+	},
+	{
+		"now",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args))
+			}
+
+			return &Time{Value: CurrentClock.Now()}
+		},
+		},
+	},
+	{
+		"sleep",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			d, err := durationArg("sleep", args, 0, time.Millisecond)
+			if err != nil {
+				return err
+			}
+			if d < 0 {
+				return newError("argument to `sleep` must not be negative, got %s",
+					args[0].Inspect())
+			}
+
+			CurrentClock.Sleep(d)
+			return nil
+		},
+		},
+	},
+	{
+		"since",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args))
+			}
+			t, err := timeArg("since", args, 0)
+			if err != nil {
+				return err
+			}
+
+			return &Duration{Value: CurrentClock.Now().Sub(t)}
+		},
+		},
+	},
+	{
+		"random",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			if len(args) != 0 {
+				return newError("wrong number of arguments. got=%d, want=0",
+					len(args)), nil
+			}
+
+			return &Float{Value: caller.Rand().Float64()}, nil
+		},
+		},
+	},
+	{
+		"random_int",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args)), nil
+			}
+			lo, ok := args[0].(*Integer)
+			if !ok {
+				return newError("argument 1 to `random_int` must be INTEGER, got %s",
+					args[0].Type()), nil
+			}
+			hi, ok := args[1].(*Integer)
+			if !ok {
+				return newError("argument 2 to `random_int` must be INTEGER, got %s",
+					args[1].Type()), nil
+			}
+			if lo.Value > hi.Value {
+				return newError("arguments to `random_int` must not be in reverse order, got %d and %d",
+					lo.Value, hi.Value), nil
+			}
+
+			return &Integer{Value: randomBetween(caller.Rand(), lo.Value, hi.Value)}, nil
+		},
+		},
+	},
+	{
+		"choice",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args)), nil
+			}
+			iterable, ok := args[0].(Iterable)
+			if !ok {
+				return newError("argument to `choice` must be iterable, got %s",
+					args[0].Type()), nil
+			}
+			if iterable.Len() == 0 {
+				return newError("argument to `choice` must not be empty"), nil
+			}
+
+			return iterable.At(caller.Rand().Int63n(iterable.Len())), nil
+		},
+		},
+	},
+	{
+		"shuffle",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args)), nil
+			}
+			iterable, ok := args[0].(Iterable)
+			if !ok {
+				return newError("argument to `shuffle` must be iterable, got %s",
+					args[0].Type()), nil
+			}
+
+			if iterable.Len() > maxShuffle {
+				return newError("argument to `shuffle` is too long, got %d elements, want at most %d",
+					iterable.Len(), maxShuffle), nil
+			}
+
+			elements := make([]Object, iterable.Len())
+			for i := range elements {
+				elements[i] = iterable.At(int64(i))
+			}
+			caller.Rand().Shuffle(len(elements), func(i, j int) {
+				elements[i], elements[j] = elements[j], elements[i]
+			})
+			return &Array{Elements: elements}, nil
+		},
+		},
+	},
+	{
+		"seed",
+		&Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1",
+					len(args)), nil
+			}
+			n, ok := args[0].(*Integer)
+			if !ok {
+				return newError("argument to `seed` must be INTEGER, got %s",
+					args[0].Type()), nil
+			}
+
+			caller.Rand().Seed(n.Value)
+			return nil, nil
+		},
+		},
+	},
 }
 
 // GetBuiltinByName gets builtin function by name
@@ -256,6 +854,148 @@ func GetBuiltinByName(name string) *Builtin {
 	return nil
 }
 
+// escape writes the characters of s as a string literal holds them
+func escape(s string) string {
+	quoted := strconv.Quote(s)
+	return quoted[1 : len(quoted)-1]
+}
+
 func newError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
+
+// floatToInteger truncates value, promoting it to a BigInt
+// when it does not fit in an Integer
+func floatToInteger(value float64) Object {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return newError("argument to `int` must be finite, got %g", value)
+	}
+	if value >= -(1<<63) && value < 1<<63 {
+		return &Integer{Value: int64(value)}
+	}
+	i, _ := big.NewFloat(value).Int(nil)
+	return NewInteger(i)
+}
+
+// callbackArgs checks the arguments of a builtin that calls a function
+// with each element of an iterable
+func callbackArgs(name string, args []Object, want int) (Iterable, Object, *Error) {
+	if len(args) != want {
+		return nil, nil, newError("wrong number of arguments. got=%d, want=%d",
+			len(args), want)
+	}
+
+	iterable, ok := args[0].(Iterable)
+	if !ok {
+		return nil, nil, newError("argument to `%s` must be iterable, got %s",
+			name, args[0].Type())
+	}
+
+	if !isFunction(args[1]) {
+		return nil, nil, newError("argument to `%s` must be a function, got %s",
+			name, args[1].Type())
+	}
+	return iterable, args[1], nil
+}
+
+// generatorArg returns the generator passed to map or filter, whose
+// results are made as they are asked for, or nil for any other argument
+func generatorArg(name string, args []Object) (*Generator, *Error) {
+	if len(args) != 2 {
+		return nil, nil
+	}
+	gen, ok := args[0].(*Generator)
+	if !ok {
+		return nil, nil
+	}
+	if !isFunction(args[1]) {
+		return nil, newError("argument to `%s` must be a function, got %s",
+			name, args[1].Type())
+	}
+	return gen, nil
+}
+
+// mapGenerator returns a generator of fn called with each value of gen,
+// the value gen returns when it finishes is passed on as it is
+func mapGenerator(gen *Generator, fn Object) *Generator {
+	mapped := &Generator{}
+	mapped.Next = func(caller Caller) (Object, error) {
+		value, err := caller.Call(gen)
+		if err != nil {
+			return nil, err
+		}
+		if gen.Done {
+			mapped.Done = true
+			return value, nil
+		}
+		return caller.Call(fn, value)
+	}
+	return mapped
+}
+
+// filterGenerator returns a generator of the values of gen where fn is
+// truthy, the value gen returns when it finishes is passed on as it is
+func filterGenerator(gen *Generator, fn Object) *Generator {
+	filtered := &Generator{}
+	filtered.Next = func(caller Caller) (Object, error) {
+		for {
+			value, err := caller.Call(gen)
+			if err != nil {
+				return nil, err
+			}
+			if gen.Done {
+				filtered.Done = true
+				return value, nil
+			}
+			keep, err := caller.Call(fn, value)
+			if err != nil {
+				return nil, err
+			}
+			if isTruthy(keep) {
+				return value, nil
+			}
+		}
+	}
+	return filtered
+}
+
+// isFunction reports whether obj can be called by a Caller
+func isFunction(obj Object) bool {
+	switch obj.(type) {
+	case *Closure, *Function, *Builtin, *Constructor:
+		return true
+	}
+	return false
+}
+
+// matchAll reports whether the function returns found for any element,
+// for any found is true and for all it is false
+func matchAll(name string, caller Caller, args []Object, found bool) (Object, error) {
+	iterable, fn, errObj := callbackArgs(name, args, 2)
+	if errObj != nil {
+		return errObj, nil
+	}
+
+	for i := int64(0); i < iterable.Len(); i++ {
+		result, err := caller.Call(fn, iterable.At(i))
+		if err != nil {
+			return nil, err
+		}
+		if isTruthy(result) == found {
+			return &Boolean{Value: found}, nil
+		}
+	}
+
+	return &Boolean{Value: !found}, nil
+}
+
+func isTruthy(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Boolean:
+		return obj.Value
+	case *Null:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,195 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Equal reports whether a and b hold the same value. Arrays, tuples,
+// hashes, sets, ranges and variants are compared by their contents, functions and
+// generators by identity. Values of different types are never equal.
+func Equal(a, b Object) bool {
+	if IsInteger(a) && IsInteger(b) {
+		return CompareIntegers(a, b) == 0
+	}
+
+	switch a := a.(type) {
+	case *Float:
+		b, ok := b.(*Float)
+		return ok && a.Value == b.Value
+	case *String:
+		b, ok := b.(*String)
+		return ok && a.Value == b.Value
+	case *Char:
+		b, ok := b.(*Char)
+		return ok && a.Value == b.Value
+	case *Bytes:
+		b, ok := b.(*Bytes)
+		return ok && bytes.Equal(a.Value, b.Value)
+	case *Boolean:
+		b, ok := b.(*Boolean)
+		return ok && a.Value == b.Value
+	case *Null:
+		_, ok := b.(*Null)
+		return ok
+	case *Array:
+		b, ok := b.(*Array)
+		return ok && equalElements(a.Elements, b.Elements)
+	case *Tuple:
+		b, ok := b.(*Tuple)
+		return ok && equalElements(a.Elements, b.Elements)
+	case *Set:
+		b, ok := b.(*Set)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, e := range a.Elements() {
+			if !b.Has(e) {
+				return false
+			}
+		}
+		return true
+	case *Hash:
+		b, ok := b.(*Hash)
+		if !ok || a.Len() != b.Len() {
+			return false
+		}
+		for _, pair := range a.Pairs() {
+			other, ok := b.Get(pair.Key.(Hashable))
+			if !ok || !Equal(pair.Value, other) {
+				return false
+			}
+		}
+		return true
+	case *Range:
+		b, ok := b.(*Range)
+		return ok && a.Len() == b.Len() && (a.Len() == 0 || a.Start == b.Start)
+	case *Variant:
+		b, ok := b.(*Variant)
+		return ok && a.Tag() == b.Tag() && equalElements(a.Values, b.Values)
+	case *Time:
+		b, ok := b.(*Time)
+		return ok && a.Value.Equal(b.Value)
+	case *Duration:
+		b, ok := b.(*Duration)
+		return ok && a.Value == b.Value
+	}
+
+	return a == b
+}
+
+func equalElements(a, b []Object) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Compare orders a and b, returning -1, 0 or +1 when a is less than,
+// equal to or greater than b. Numbers, chars, bytes, times and
+// durations are ordered by value, strings by code point, arrays and
+// tuples element by element; ok is false for values without an order.
+func Compare(a, b Object) (cmp int, ok bool) {
+	if IsInteger(a) && IsInteger(b) {
+		return CompareIntegers(a, b), true
+	}
+
+	switch a := a.(type) {
+	case *Float:
+		b, ok := b.(*Float)
+		if !ok || a.Value != a.Value || b.Value != b.Value {
+			return 0, false
+		}
+		switch {
+		case a.Value < b.Value:
+			return -1, true
+		case a.Value > b.Value:
+			return 1, true
+		default:
+			return 0, true
+		}
+	case *String:
+		b, ok := b.(*String)
+		if !ok {
+			return 0, false
+		}
+		// UTF-8 byte order is the same as code point order
+		return strings.Compare(a.Value, b.Value), true
+	case *Char:
+		b, ok := b.(*Char)
+		if !ok {
+			return 0, false
+		}
+		return compareInts(int(a.Value), int(b.Value)), true
+	case *Bytes:
+		b, ok := b.(*Bytes)
+		if !ok {
+			return 0, false
+		}
+		return bytes.Compare(a.Value, b.Value), true
+	case *Array:
+		b, ok := b.(*Array)
+		if !ok {
+			return 0, false
+		}
+		return compareElements(a.Elements, b.Elements)
+	case *Tuple:
+		b, ok := b.(*Tuple)
+		if !ok {
+			return 0, false
+		}
+		return compareElements(a.Elements, b.Elements)
+	case *Time:
+		b, ok := b.(*Time)
+		if !ok {
+			return 0, false
+		}
+		return a.Value.Compare(b.Value), true
+	case *Duration:
+		b, ok := b.(*Duration)
+		if !ok {
+			return 0, false
+		}
+		return compareInts(int(a.Value), int(b.Value)), true
+	}
+
+	return 0, false
+}
+
+// GreaterThan reports whether a is greater than b, ok is false for
+// values without an order. Like in Go, a comparison with NaN is false.
+func GreaterThan(a, b Object) (result, ok bool) {
+	if a, isFloat := a.(*Float); isFloat {
+		if b, isFloat := b.(*Float); isFloat {
+			return a.Value > b.Value, true
+		}
+	}
+	cmp, ok := Compare(a, b)
+	return cmp > 0, ok
+}
+
+func compareElements(a, b []Object) (int, bool) {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		cmp, ok := Compare(a[i], b[i])
+		if !ok || cmp != 0 {
+			return cmp, ok
+		}
+	}
+	return compareInts(len(a), len(b)), true
+}
+
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
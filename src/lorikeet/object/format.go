@@ -0,0 +1,173 @@
+package object
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFormatWidth limits the width and precision of a format verb
+const maxFormatWidth = 1000000
+
+// Format formats args by the verbs in a format string for the builtin
+// name. The verbs are:
+//
+//	%v  any value as say prints it
+//	%s  a STRING or CHAR
+//	%q  a STRING or CHAR quoted and escaped
+//	%d  an INTEGER
+//	%b  %o  %x  %X  an INTEGER in base 2, 8 or 16, %x also takes STRING and BYTES
+//	%f  %e  %g  a FLOAT or INTEGER
+//	%c  a CHAR or INTEGER code point
+//	%t  a BOOLEAN
+//	%%  a literal %
+//
+// Verbs take the flags "-+# 0", a width and a precision like in Go.
+func Format(name string, args []Object) (string, *Error) {
+	if len(args) == 0 {
+		return "", newError("wrong number of arguments to `%s`. got=0, want=1 or more", name)
+	}
+	format, ok := args[0].(*String)
+	if !ok {
+		return "", newError("argument 1 to `%s` must be STRING, got %s", name, args[0].Type())
+	}
+
+	var out strings.Builder
+	next := 1
+	s := format.Value
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			out.WriteByte(s[i])
+			continue
+		}
+
+		start := i
+		i++
+		for i < len(s) && strings.IndexByte("-+# 0", s[i]) >= 0 {
+			i++
+		}
+		if width := digits(s, &i); width > maxFormatWidth {
+			return "", newError("width in `%s` is too large: %s", name, s[start:i])
+		}
+		if i < len(s) && s[i] == '.' {
+			i++
+			if precision := digits(s, &i); precision > maxFormatWidth {
+				return "", newError("precision in `%s` is too large: %s", name, s[start:i])
+			}
+		}
+		if i >= len(s) {
+			return "", newError("format of `%s` ends in an incomplete verb: %s", name, s[start:])
+		}
+
+		verb, size := utf8.DecodeRuneInString(s[i:])
+		i += size - 1
+		spec := s[start : i+1]
+		if verb == '%' {
+			if spec != "%%" {
+				return "", newError("verb %s in `%s` takes no flags, width or precision", spec, name)
+			}
+			out.WriteByte('%')
+			continue
+		}
+
+		if !strings.ContainsRune("vsqdboxXfegct", verb) {
+			return "", newError("unknown verb %s in `%s`", spec, name)
+		}
+		if next >= len(args) {
+			return "", newError("missing argument for %s in `%s`", spec, name)
+		}
+		arg := args[next]
+		next++
+
+		value, want := formatValue(verb, arg)
+		if want != "" {
+			return "", newError("%s in `%s` needs %s, got %s for argument %d",
+				spec, name, want, arg.Type(), next)
+		}
+		out.WriteString(fmt.Sprintf(spec, value))
+	}
+
+	if next < len(args) {
+		return "", newError("too many arguments to `%s`, format uses %d, got %d",
+			name, next-1, len(args)-1)
+	}
+	return out.String(), nil
+}
+
+// digits reads a decimal number from s at i, saturating at one more
+// than maxFormatWidth
+func digits(s string, i *int) int {
+	n := 0
+	for *i < len(s) && '0' <= s[*i] && s[*i] <= '9' {
+		if n <= maxFormatWidth {
+			n = n*10 + int(s[*i]-'0')
+		}
+		*i++
+	}
+	return n
+}
+
+// formatValue returns the Go value that verb formats for arg, or the
+// types the verb takes when arg is not one of them
+func formatValue(verb rune, arg Object) (interface{}, string) {
+	switch verb {
+	case 'v':
+		return arg.Inspect(), ""
+	case 's', 'q':
+		switch arg := arg.(type) {
+		case *String:
+			return arg.Value, ""
+		case *Char:
+			if verb == 'q' {
+				return arg.Value, ""
+			}
+			return string(arg.Value), ""
+		}
+		return nil, "STRING or CHAR"
+	case 'd', 'b', 'o', 'x', 'X':
+		switch arg := arg.(type) {
+		case *Integer:
+			return arg.Value, ""
+		case *BigInt:
+			return arg.Value, ""
+		}
+		if verb != 'x' && verb != 'X' {
+			return nil, "INTEGER"
+		}
+		switch arg := arg.(type) {
+		case *String:
+			return arg.Value, ""
+		case *Bytes:
+			return arg.Value, ""
+		}
+		return nil, "INTEGER, STRING or BYTES"
+	case 'f', 'e', 'g':
+		switch arg := arg.(type) {
+		case *Float:
+			return arg.Value, ""
+		case *Integer:
+			return float64(arg.Value), ""
+		case *BigInt:
+			f, _ := new(big.Float).SetInt(arg.Value).Float64()
+			return f, ""
+		}
+		return nil, "FLOAT or INTEGER"
+	case 'c':
+		switch arg := arg.(type) {
+		case *Char:
+			return arg.Value, ""
+		case *Integer:
+			if arg.Value >= 0 && arg.Value <= utf8.MaxRune {
+				return rune(arg.Value), ""
+			}
+		}
+		return nil, "CHAR or an INTEGER code point"
+	case 't':
+		if arg, ok := arg.(*Boolean); ok {
+			return arg.Value, ""
+		}
+		return nil, "BOOLEAN"
+	}
+	return nil, ""
+}
@@ -0,0 +1,92 @@
+package object
+
+// Freeze marks obj and every array and hash reachable from it as
+// frozen, frozen values cannot be changed. Returns obj.
+func Freeze(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return obj
+		}
+		obj.Frozen = true
+		for _, el := range obj.Elements {
+			Freeze(el)
+		}
+	case *Hash:
+		if obj.Frozen {
+			return obj
+		}
+		obj.Frozen = true
+		for _, pair := range obj.pairs {
+			Freeze(pair.Value)
+		}
+	case *Tuple:
+		for _, el := range obj.Elements {
+			Freeze(el)
+		}
+	case *Variant:
+		for _, v := range obj.Values {
+			Freeze(v)
+		}
+	}
+	return obj
+}
+
+// IsFrozen reports whether obj cannot be changed, values other than
+// arrays and hashes are always frozen
+func IsFrozen(obj Object) bool {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.Frozen
+	case *Hash:
+		return obj.Frozen
+	}
+	return true
+}
+
+// References reports whether target can be reached from obj, storing obj
+// inside target would then make target contain itself. Frozen values
+// are skipped, nothing reachable from them can be changed.
+func References(obj, target Object) bool {
+	return references(obj, target, make(map[Object]bool))
+}
+
+func references(obj, target Object, seen map[Object]bool) bool {
+	if obj == target {
+		return true
+	}
+
+	var elements []Object
+	switch obj := obj.(type) {
+	case *Array:
+		if obj.Frozen {
+			return false
+		}
+		elements = obj.Elements
+	case *Hash:
+		if obj.Frozen {
+			return false
+		}
+		for _, pair := range obj.pairs {
+			elements = append(elements, pair.Value)
+		}
+	case *Tuple:
+		elements = obj.Elements
+	case *Variant:
+		elements = obj.Values
+	default:
+		return false
+	}
+
+	if seen[obj] {
+		return false
+	}
+	seen[obj] = true
+
+	for _, el := range elements {
+		if references(el, target, seen) {
+			return true
+		}
+	}
+	return false
+}
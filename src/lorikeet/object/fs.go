@@ -0,0 +1,253 @@
+package object
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileSystemRoots are the directories the fs module may use, set with
+// AllowFileSystem. The fs module is disabled while there are none.
+var FileSystemRoots []string
+
+// AllowFileSystem grants the fs module access to root and everything
+// under it, root must be an existing directory
+func AllowFileSystem(root string) error {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New(root + " is not a directory")
+	}
+
+	FileSystemRoots = append(FileSystemRoots, real)
+	return nil
+}
+
+// FS module of file system functions, paths are checked against the
+// FileSystemRoots and file contents are strings
+var FS = &Module{
+	Name: "fs",
+	Members: map[string]Object{
+		"read_file": &Builtin{Fn: func(args ...Object) Object {
+			p, err := pathArg("fs.read_file", args, 1)
+			if err != nil {
+				return err
+			}
+
+			data, osErr := os.ReadFile(p)
+			if osErr != nil {
+				return fsError("fs.read_file", args[0], osErr)
+			}
+			return &String{Value: string(data)}
+		},
+		},
+		"write_file":  writeBuiltin("fs.write_file", os.O_TRUNC),
+		"append_file": writeBuiltin("fs.append_file", os.O_APPEND),
+		"list_dir": &Builtin{Fn: func(args ...Object) Object {
+			p, err := pathArg("fs.list_dir", args, 1)
+			if err != nil {
+				return err
+			}
+
+			entries, osErr := os.ReadDir(p)
+			if osErr != nil {
+				return fsError("fs.list_dir", args[0], osErr)
+			}
+			names := make([]string, len(entries))
+			for i, entry := range entries {
+				names[i] = entry.Name()
+			}
+			return stringArray(names)
+		},
+		},
+		"exists": &Builtin{Fn: func(args ...Object) Object {
+			p, err := pathArg("fs.exists", args, 1)
+			if err != nil {
+				return err
+			}
+
+			_, osErr := os.Stat(p)
+			if errors.Is(osErr, os.ErrNotExist) {
+				return &Boolean{Value: false}
+			}
+			if osErr != nil {
+				return fsError("fs.exists", args[0], osErr)
+			}
+			return &Boolean{Value: true}
+		},
+		},
+		"remove": &Builtin{Fn: func(args ...Object) Object {
+			p, err := pathArg("fs.remove", args, 1)
+			if err != nil {
+				return err
+			}
+
+			if osErr := os.Remove(p); osErr != nil {
+				return fsError("fs.remove", args[0], osErr)
+			}
+			return nil
+		},
+		},
+		"mkdir": &Builtin{Fn: func(args ...Object) Object {
+			p, err := pathArg("fs.mkdir", args, 1)
+			if err != nil {
+				return err
+			}
+
+			if osErr := os.MkdirAll(p, 0755); osErr != nil {
+				return fsError("fs.mkdir", args[0], osErr)
+			}
+			return nil
+		},
+		},
+	},
+}
+
+// Path module of functions on file paths, they do not use the file
+// system so need no access to it
+var Path = &Module{
+	Name: "path",
+	Members: map[string]Object{
+		"join": &Builtin{Fn: func(args ...Object) Object {
+			if len(args) == 0 {
+				return newError("wrong number of arguments. got=0, want=1 or more")
+			}
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				s, ok := arg.(*String)
+				if !ok {
+					return newError("argument %d to `path.join` must be STRING, got %s",
+						i+1, arg.Type())
+				}
+				parts[i] = s.Value
+			}
+
+			return &String{Value: filepath.Join(parts...)}
+		},
+		},
+		"base": pathFunction("path.base", filepath.Base),
+		"dir":  pathFunction("path.dir", filepath.Dir),
+		"ext":  pathFunction("path.ext", filepath.Ext),
+	},
+}
+
+// pathArg checks that args are want strings and returns the first as
+// a path under the FileSystemRoots
+func pathArg(name string, args []Object, want int) (string, *Error) {
+	if len(args) != want {
+		return "", newError("wrong number of arguments. got=%d, want=%d",
+			len(args), want)
+	}
+	for i, arg := range args {
+		if _, ok := arg.(*String); !ok {
+			return "", newError("argument %d to `%s` must be STRING, got %s",
+				i+1, name, arg.Type())
+		}
+	}
+
+	if len(FileSystemRoots) == 0 {
+		return "", newError("permission denied: `%s` needs file system access, run with --allow-fs=DIR",
+			name)
+	}
+	path := args[0].(*String).Value
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", newError("invalid path %s: %s", path, err)
+	}
+
+	real := realPath(abs)
+	for _, root := range FileSystemRoots {
+		if real != "" && within(root, real) {
+			return real, nil
+		}
+	}
+	return "", newError("permission denied: %s is outside the allowed directories", path)
+}
+
+// realPath resolves the symbolic links in the part of an absolute path
+// that exists, it is "" when a link cannot be resolved
+func realPath(p string) string {
+	if real, err := filepath.EvalSymlinks(p); err == nil {
+		return real
+	}
+	if _, err := os.Lstat(p); err == nil {
+		return ""
+	}
+
+	parent := filepath.Dir(p)
+	if parent == p {
+		return p
+	}
+	real := realPath(parent)
+	if real == "" {
+		return ""
+	}
+	return filepath.Join(real, filepath.Base(p))
+}
+
+// within reports whether path is root or under it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// fsError reports a failed file system call without the full path
+func fsError(name string, path Object, err error) *Error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return newError("`%s` failed for %s: %s", name, path.Inspect(), err)
+}
+
+// writeBuiltin writes a string to a file, creating it when it does not
+// exist, flag is os.O_TRUNC to replace the contents or os.O_APPEND to
+// add to them
+func writeBuiltin(name string, flag int) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		p, err := pathArg(name, args, 2)
+		if err != nil {
+			return err
+		}
+		contents := args[1].(*String).Value
+
+		f, osErr := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|flag, 0644)
+		if osErr != nil {
+			return fsError(name, args[0], osErr)
+		}
+		_, osErr = f.WriteString(contents)
+		if closeErr := f.Close(); osErr == nil {
+			osErr = closeErr
+		}
+		if osErr != nil {
+			return fsError(name, args[0], osErr)
+		}
+		return nil
+	},
+	}
+}
+
+// pathFunction returns a builtin applying fn to one path
+func pathFunction(name string, fn func(string) string) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		s, err := stringArgs(name, args, 1)
+		if err != nil {
+			return err
+		}
+
+		return &String{Value: fn(s[0])}
+	},
+	}
+}
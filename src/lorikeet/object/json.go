@@ -0,0 +1,444 @@
+package object
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxJSONDepth limits the nesting of arrays and objects in JSON
+const maxJSONDepth = 1000
+
+// ParseJSON decodes JSON text into Lorikeet values. Objects become
+// hashes keeping the order of their keys, numbers without a fraction or
+// exponent become INTEGER and other numbers FLOAT.
+func ParseJSON(text string) (Object, *Error) {
+	p := &jsonParser{text: text, line: 1, column: 1}
+	p.skipSpace()
+	value, err := p.parseValue(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.text) {
+		return nil, p.errorf("unexpected %s after JSON value", p.describe())
+	}
+	return value, nil
+}
+
+type jsonParser struct {
+	text   string
+	pos    int
+	line   int
+	column int
+}
+
+func (p *jsonParser) errorf(format string, a ...interface{}) *Error {
+	err := newError(format, a...)
+	err.Message = "invalid JSON at line " + strconv.Itoa(p.line) +
+		", column " + strconv.Itoa(p.column) + ": " + err.Message
+	return err
+}
+
+// describe names the character at the current position for errors
+func (p *jsonParser) describe() string {
+	if p.pos >= len(p.text) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRuneInString(p.text[p.pos:])
+	return "character " + strconv.QuoteRune(r)
+}
+
+// advance moves past n bytes, which hold no new line
+func (p *jsonParser) advance(n int) {
+	p.column += utf8.RuneCountInString(p.text[p.pos : p.pos+n])
+	p.pos += n
+}
+
+func (p *jsonParser) skipSpace() {
+	for p.pos < len(p.text) {
+		switch p.text[p.pos] {
+		case '\n':
+			p.pos++
+			p.line++
+			p.column = 1
+		case ' ', '\t', '\r':
+			p.advance(1)
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) parseValue(depth int) (Object, *Error) {
+	if depth > maxJSONDepth {
+		return nil, p.errorf("nesting is deeper than %d", maxJSONDepth)
+	}
+	if p.pos >= len(p.text) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	switch c := p.text[p.pos]; {
+	case c == '{':
+		return p.parseObject(depth)
+	case c == '[':
+		return p.parseArray(depth)
+	case c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return &String{Value: s}, nil
+	case c == '-' || '0' <= c && c <= '9':
+		return p.parseNumber()
+	}
+
+	switch {
+	case strings.HasPrefix(p.text[p.pos:], "true"):
+		p.advance(4)
+		return &Boolean{Value: true}, nil
+	case strings.HasPrefix(p.text[p.pos:], "false"):
+		p.advance(5)
+		return &Boolean{Value: false}, nil
+	case strings.HasPrefix(p.text[p.pos:], "null"):
+		p.advance(4)
+		return &Null{}, nil
+	}
+	return nil, p.errorf("unexpected %s", p.describe())
+}
+
+func (p *jsonParser) parseObject(depth int) (Object, *Error) {
+	hash := NewHash()
+	p.advance(1)
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == '}' {
+		p.advance(1)
+		return hash, nil
+	}
+
+	for {
+		if p.pos >= len(p.text) || p.text[p.pos] != '"' {
+			return nil, p.errorf("expected string key, got %s", p.describe())
+		}
+		key, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if p.pos >= len(p.text) || p.text[p.pos] != ':' {
+			return nil, p.errorf("expected ':' after object key, got %s", p.describe())
+		}
+		p.advance(1)
+		p.skipSpace()
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(&String{Value: key}, value)
+
+		p.skipSpace()
+		if p.pos < len(p.text) && p.text[p.pos] == ',' {
+			p.advance(1)
+			p.skipSpace()
+			continue
+		}
+		if p.pos < len(p.text) && p.text[p.pos] == '}' {
+			p.advance(1)
+			return hash, nil
+		}
+		return nil, p.errorf("expected ',' or '}' in object, got %s", p.describe())
+	}
+}
+
+func (p *jsonParser) parseArray(depth int) (Object, *Error) {
+	elements := []Object{}
+	p.advance(1)
+	p.skipSpace()
+	if p.pos < len(p.text) && p.text[p.pos] == ']' {
+		p.advance(1)
+		return &Array{Elements: elements}, nil
+	}
+
+	for {
+		value, err := p.parseValue(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, value)
+
+		p.skipSpace()
+		if p.pos < len(p.text) && p.text[p.pos] == ',' {
+			p.advance(1)
+			p.skipSpace()
+			continue
+		}
+		if p.pos < len(p.text) && p.text[p.pos] == ']' {
+			p.advance(1)
+			return &Array{Elements: elements}, nil
+		}
+		return nil, p.errorf("expected ',' or ']' in array, got %s", p.describe())
+	}
+}
+
+// parseString reads a string and returns the characters it stands for
+func (p *jsonParser) parseString() (string, *Error) {
+	var out strings.Builder
+	p.advance(1)
+	for {
+		if p.pos >= len(p.text) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.text[p.pos]
+		switch {
+		case c == '"':
+			p.advance(1)
+			return out.String(), nil
+		case c < 0x20:
+			return "", p.errorf("%s must be escaped in string", p.describe())
+		case c != '\\':
+			r, size := utf8.DecodeRuneInString(p.text[p.pos:])
+			out.WriteRune(r)
+			p.advance(size)
+			continue
+		}
+
+		if p.pos+1 >= len(p.text) {
+			return "", p.errorf("unterminated string")
+		}
+		switch e := p.text[p.pos+1]; e {
+		case '"', '\\', '/':
+			out.WriteByte(e)
+		case 'b':
+			out.WriteByte('\b')
+		case 'f':
+			out.WriteByte('\f')
+		case 'n':
+			out.WriteByte('\n')
+		case 'r':
+			out.WriteByte('\r')
+		case 't':
+			out.WriteByte('\t')
+		case 'u':
+			r, ok := p.hex4(p.pos + 2)
+			if !ok {
+				return "", p.errorf("invalid unicode escape")
+			}
+			size := 6
+			if 0xd800 <= r && r < 0xdc00 {
+				// a surrogate pair encodes a code point above U+FFFF
+				if low, ok := p.hex4(p.pos + 8); ok && p.text[p.pos+6:p.pos+8] == `\u` &&
+					0xdc00 <= low && low < 0xe000 {
+					r = (r-0xd800)<<10 + (low - 0xdc00) + 0x10000
+					size = 12
+				}
+			}
+			out.WriteRune(r)
+			p.advance(size)
+			continue
+		default:
+			p.advance(1)
+			return "", p.errorf("invalid escape %s", p.describe())
+		}
+		p.advance(2)
+	}
+}
+
+// hex4 reads the four hex digits of a \u escape at i
+func (p *jsonParser) hex4(i int) (rune, bool) {
+	if i+4 > len(p.text) {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(p.text[i:i+4], 16, 32)
+	return rune(n), err == nil
+}
+
+func (p *jsonParser) parseNumber() (Object, *Error) {
+	start := p.pos
+	end := p.pos
+	digits := func() int {
+		n := 0
+		for end < len(p.text) && '0' <= p.text[end] && p.text[end] <= '9' {
+			end++
+			n++
+		}
+		return n
+	}
+
+	if p.text[end] == '-' {
+		end++
+	}
+	if end < len(p.text) && p.text[end] == '0' {
+		end++
+	} else if digits() == 0 {
+		p.advance(end - start)
+		return nil, p.errorf("expected digit, got %s", p.describe())
+	}
+	isFloat := false
+	if end < len(p.text) && p.text[end] == '.' {
+		end++
+		isFloat = true
+		if digits() == 0 {
+			p.advance(end - start)
+			return nil, p.errorf("expected digit after '.', got %s", p.describe())
+		}
+	}
+	if end < len(p.text) && (p.text[end] == 'e' || p.text[end] == 'E') {
+		end++
+		isFloat = true
+		if end < len(p.text) && (p.text[end] == '+' || p.text[end] == '-') {
+			end++
+		}
+		if digits() == 0 {
+			p.advance(end - start)
+			return nil, p.errorf("expected digit in exponent, got %s", p.describe())
+		}
+	}
+
+	literal := p.text[start:end]
+	if !isFloat {
+		p.advance(end - start)
+		n, _ := new(big.Int).SetString(literal, 10)
+		return NewInteger(n), nil
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return nil, p.errorf("number %s is out of range", literal)
+	}
+	p.advance(end - start)
+	return &Float{Value: f}, nil
+}
+
+// StringifyJSON encodes value as JSON, with each nested value on its
+// own line and indented by indent when it is not empty
+func StringifyJSON(value Object, indent string) (string, *Error) {
+	var out strings.Builder
+	if err := writeJSON(&out, value, indent, 0); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func writeJSON(out *strings.Builder, value Object, indent string, depth int) *Error {
+	if depth > maxJSONDepth {
+		return newError("value is nested deeper than %d to encode as JSON", maxJSONDepth)
+	}
+
+	switch value := value.(type) {
+	case *Null:
+		out.WriteString("null")
+	case *Boolean:
+		out.WriteString(strconv.FormatBool(value.Value))
+	case *Integer, *BigInt:
+		out.WriteString(value.Inspect())
+	case *Float:
+		if math.IsNaN(value.Value) || math.IsInf(value.Value, 0) {
+			return newError("cannot encode %g as JSON", value.Value)
+		}
+		s := strconv.FormatFloat(value.Value, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		out.WriteString(s)
+	case *String:
+		writeJSONString(out, value.Value)
+	case *Char:
+		writeJSONString(out, string(value.Value))
+	case *Array:
+		return writeJSONArray(out, value.Elements, indent, depth)
+	case *Tuple:
+		return writeJSONArray(out, value.Elements, indent, depth)
+	case *Hash:
+		pairs := value.Pairs()
+		if len(pairs) == 0 {
+			out.WriteString("{}")
+			return nil
+		}
+		out.WriteByte('{')
+		for i, pair := range pairs {
+			key, ok := pair.Key.(*String)
+			if !ok {
+				return newError("hash keys must be STRING to encode as JSON, got %s",
+					pair.Key.Type())
+			}
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			newline(out, indent, depth+1)
+			if err := writeJSON(out, key, indent, depth+1); err != nil {
+				return err
+			}
+			out.WriteByte(':')
+			if indent != "" {
+				out.WriteByte(' ')
+			}
+			if err := writeJSON(out, pair.Value, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		newline(out, indent, depth)
+		out.WriteByte('}')
+	default:
+		return newError("cannot encode %s as JSON", value.Type())
+	}
+	return nil
+}
+
+func writeJSONArray(out *strings.Builder, elements []Object, indent string, depth int) *Error {
+	if len(elements) == 0 {
+		out.WriteString("[]")
+		return nil
+	}
+	out.WriteByte('[')
+	for i, el := range elements {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		newline(out, indent, depth+1)
+		if err := writeJSON(out, el, indent, depth+1); err != nil {
+			return err
+		}
+	}
+	newline(out, indent, depth)
+	out.WriteByte(']')
+	return nil
+}
+
+// newline starts a line indented depth times, when indenting
+func newline(out *strings.Builder, indent string, depth int) {
+	if indent == "" {
+		return
+	}
+	out.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		out.WriteString(indent)
+	}
+}
+
+func writeJSONString(out *strings.Builder, s string) {
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				out.WriteString(`\u00`)
+				out.WriteString(strconv.FormatInt(int64(r)>>4, 16))
+				out.WriteString(strconv.FormatInt(int64(r)&0xf, 16))
+				continue
+			}
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+}
@@ -0,0 +1,298 @@
+package object
+
+import (
+	"math"
+	"math/big"
+)
+
+// Math module of number functions and constants, functions take
+// INTEGER and FLOAT arguments alike
+var Math = &Module{
+	Name: "math",
+	Members: map[string]Object{
+		"pi":  &Float{Value: math.Pi},
+		"e":   &Float{Value: math.E},
+		"inf": &Float{Value: math.Inf(1)},
+		"nan": &Float{Value: math.NaN()},
+
+		"sqrt":  floatFunction("math.sqrt", math.Sqrt),
+		"sin":   floatFunction("math.sin", math.Sin),
+		"cos":   floatFunction("math.cos", math.Cos),
+		"tan":   floatFunction("math.tan", math.Tan),
+		"log":   floatFunction("math.log", math.Log),
+		"log10": floatFunction("math.log10", math.Log10),
+		"exp":   floatFunction("math.exp", math.Exp),
+		"atan2": &Builtin{Fn: func(args ...Object) Object {
+			if err := arity(args, 2); err != nil {
+				return err
+			}
+			y, err := floatArg("math.atan2", args, 0)
+			if err != nil {
+				return err
+			}
+			x, err := floatArg("math.atan2", args, 1)
+			if err != nil {
+				return err
+			}
+
+			return &Float{Value: math.Atan2(y, x)}
+		},
+		},
+		"pow": &Builtin{Fn: func(args ...Object) Object {
+			if err := arity(args, 2); err != nil {
+				return err
+			}
+			x, err := floatArg("math.pow", args, 0)
+			if err != nil {
+				return err
+			}
+			y, err := floatArg("math.pow", args, 1)
+			if err != nil {
+				return err
+			}
+
+			if IsInteger(args[0]) && IsInteger(args[1]) && BigValue(args[1]).Sign() >= 0 {
+				base, exp := BigValue(args[0]), BigValue(args[1])
+				if base.BitLen() > 1 &&
+					(!exp.IsInt64() || exp.Int64() > maxPowBits/int64(base.BitLen()-1)) {
+					return newError("result of `math.pow` is too large")
+				}
+				return NewInteger(new(big.Int).Exp(base, exp, nil))
+			}
+
+			return &Float{Value: math.Pow(x, y)}
+		},
+		},
+		"abs": &Builtin{Fn: func(args ...Object) Object {
+			if err := arity(args, 1); err != nil {
+				return err
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer, *BigInt:
+				if BigValue(arg).Sign() < 0 {
+					return NegateInteger(arg)
+				}
+				return arg
+			case *Float:
+				return &Float{Value: math.Abs(arg.Value)}
+			}
+			return numberError("math.abs", args, 0)
+		},
+		},
+		"floor": roundFunction("math.floor", math.Floor),
+		"ceil":  roundFunction("math.ceil", math.Ceil),
+		"round": roundFunction("math.round", math.Round),
+		"min": &Builtin{Fn: func(args ...Object) Object {
+			return extreme("math.min", args, -1)
+		},
+		},
+		"max": &Builtin{Fn: func(args ...Object) Object {
+			return extreme("math.max", args, 1)
+		},
+		},
+		"clamp": &Builtin{Fn: func(args ...Object) Object {
+			if err := arity(args, 3); err != nil {
+				return err
+			}
+			for i := range args {
+				if _, err := floatArg("math.clamp", args, i); err != nil {
+					return err
+				}
+			}
+
+			x, lo, hi := args[0], args[1], args[2]
+			if compareNumbers(lo, hi) > 0 {
+				return newError("bounds of `math.clamp` are reversed, got %s and %s",
+					lo.Inspect(), hi.Inspect())
+			}
+			switch {
+			case compareNumbers(x, lo) < 0:
+				return lo
+			case compareNumbers(x, hi) > 0:
+				return hi
+			}
+			return x
+		},
+		},
+		"gcd": &Builtin{Fn: func(args ...Object) Object {
+			a, b, err := integerArgs("math.gcd", args)
+			if err != nil {
+				return err
+			}
+
+			return NewInteger(new(big.Int).GCD(nil, nil, a, b))
+		},
+		},
+		"lcm": &Builtin{Fn: func(args ...Object) Object {
+			a, b, err := integerArgs("math.lcm", args)
+			if err != nil {
+				return err
+			}
+
+			gcd := new(big.Int).GCD(nil, nil, a, b)
+			if gcd.Sign() == 0 {
+				return &Integer{Value: 0}
+			}
+			lcm := new(big.Int).Mul(a, b)
+			lcm.Abs(lcm).Quo(lcm, gcd)
+			return NewInteger(lcm)
+		},
+		},
+		"is_nan": &Builtin{Fn: func(args ...Object) Object {
+			if err := arity(args, 1); err != nil {
+				return err
+			}
+			x, err := floatArg("math.is_nan", args, 0)
+			if err != nil {
+				return err
+			}
+
+			return &Boolean{Value: math.IsNaN(x)}
+		},
+		},
+		"is_inf": &Builtin{Fn: func(args ...Object) Object {
+			if err := arity(args, 1); err != nil {
+				return err
+			}
+			x, err := floatArg("math.is_inf", args, 0)
+			if err != nil {
+				return err
+			}
+
+			return &Boolean{Value: math.IsInf(x, 0) && args[0].Type() == FLOAT}
+		},
+		},
+	},
+}
+
+// maxPowBits limits the size of integer powers
+const maxPowBits = 1 << 20
+
+func arity(args []Object, want int) *Error {
+	if len(args) != want {
+		return newError("wrong number of arguments. got=%d, want=%d",
+			len(args), want)
+	}
+	return nil
+}
+
+// floatArg returns argument i of a math builtin as a float64
+func floatArg(name string, args []Object, i int) (float64, *Error) {
+	switch arg := args[i].(type) {
+	case *Integer:
+		return float64(arg.Value), nil
+	case *BigInt:
+		f, _ := new(big.Float).SetInt(arg.Value).Float64()
+		return f, nil
+	case *Float:
+		return arg.Value, nil
+	}
+	return 0, numberError(name, args, i)
+}
+
+func numberError(name string, args []Object, i int) *Error {
+	return newError("argument %d to `%s` must be INTEGER or FLOAT, got %s",
+		i+1, name, args[i].Type())
+}
+
+// integerArgs returns the two INTEGER arguments of a math builtin
+func integerArgs(name string, args []Object) (*big.Int, *big.Int, *Error) {
+	if err := arity(args, 2); err != nil {
+		return nil, nil, err
+	}
+	for i, arg := range args {
+		if !IsInteger(arg) {
+			return nil, nil, newError("argument %d to `%s` must be INTEGER, got %s",
+				i+1, name, arg.Type())
+		}
+	}
+	return BigValue(args[0]), BigValue(args[1]), nil
+}
+
+// floatFunction returns a builtin applying fn to one number
+func floatFunction(name string, fn func(float64) float64) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if err := arity(args, 1); err != nil {
+			return err
+		}
+		x, err := floatArg(name, args, 0)
+		if err != nil {
+			return err
+		}
+
+		return &Float{Value: fn(x)}
+	},
+	}
+}
+
+// roundFunction returns a builtin rounding a number to an INTEGER
+func roundFunction(name string, round func(float64) float64) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if err := arity(args, 1); err != nil {
+			return err
+		}
+
+		switch arg := args[0].(type) {
+		case *Integer, *BigInt:
+			return arg
+		case *Float:
+			if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+				return newError("argument 1 to `%s` must be finite, got %g", name, arg.Value)
+			}
+			return floatToInteger(round(arg.Value))
+		}
+		return numberError(name, args, 0)
+	},
+	}
+}
+
+// extreme returns the smallest (sign -1) or largest (sign 1) of its
+// numbers, given as arguments or as one array, NaN wins over any number
+func extreme(name string, args []Object, sign int) Object {
+	values := args
+	isArray := false
+	if len(args) == 1 {
+		if arr, ok := args[0].(*Array); ok {
+			values, isArray = arr.Elements, true
+		}
+	}
+	if len(values) == 0 {
+		return newError("`%s` needs at least one number", name)
+	}
+
+	result := values[0]
+	for i := range values {
+		x, err := floatArg(name, values, i)
+		if err != nil && isArray {
+			return newError("argument 1 to `%s` must be an ARRAY of INTEGER or FLOAT, got %s at %d",
+				name, values[i].Type(), i)
+		}
+		if err != nil {
+			return err
+		}
+		if math.IsNaN(x) {
+			return values[i]
+		}
+		if compareNumbers(values[i], result) == sign {
+			result = values[i]
+		}
+	}
+	return result
+}
+
+// compareNumbers orders two INTEGER, BIGINT or FLOAT numbers
+func compareNumbers(a, b Object) int {
+	if IsInteger(a) && IsInteger(b) {
+		return CompareIntegers(a, b)
+	}
+	x, _ := floatArg("", []Object{a}, 0)
+	y, _ := floatArg("", []Object{b}, 0)
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,33 @@
+package object
+
+// Module object groups builtins under a name, members are read with
+// module.name so they do not take up builtin indexes
+type Module struct {
+	Name    string
+	Members map[string]Object
+}
+
+// Type will return the module type "MODULE"
+func (m *Module) Type() Type { return MODULE }
+
+// Inspect will return the module name
+func (m *Module) Inspect() string { return "module " + m.Name }
+
+// Modules of builtins, OpGetModule uses the index
+var Modules = []*Module{
+	Strings,
+	Math,
+	FS,
+	Path,
+	TimeModule,
+}
+
+// GetModuleByName gets module by name
+func GetModuleByName(name string) *Module {
+	for _, m := range Modules {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
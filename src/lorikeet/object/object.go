@@ -2,11 +2,15 @@ package object
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
 	"lorikeet/ast"
 	"lorikeet/code"
+	"math"
+	"math/rand"
 	"strings"
+	"unicode/utf8"
 )
 
 // Type of object
@@ -15,6 +19,7 @@ type Type string
 // Types
 const (
 	INTEGER   = "INTEGER"
+	BIGINT    = "BIGINT"
 	FLOAT     = "FLOAT"
 	BOOLEAN   = "BOOLEAN"
 	NULL      = "NULL"
@@ -29,6 +34,19 @@ const (
 	MACRO     = "MACRO"
 	CFUNCTION = "COMPILED_FUNCTION"
 	CLOSURE   = "CLOSURE"
+	RANGE     = "RANGE"
+	VARIANT   = "VARIANT"
+	CONSTRUCT = "CONSTRUCTOR"
+	GENERATOR = "GENERATOR"
+	TUPLE     = "TUPLE"
+	SET       = "SET"
+	CHAR      = "CHAR"
+	BYTES     = "BYTES"
+	MODULE    = "MODULE"
+	REGEX     = "REGEX"
+	TIME      = "TIME"
+	DURATION  = "DURATION"
+	EXIT      = "EXIT"
 )
 
 // Object methods
@@ -102,6 +120,12 @@ func (b *Boolean) Inspect() string { return fmt.Sprintf("%t", b.Value) }
 // String object
 type String struct {
 	Value string
+
+	// The first index, slice or len works out whether the string is
+	// ASCII, and keeps the code points of one that is not
+	counted bool
+	ascii   bool
+	runes   []rune
 }
 
 // Type will return the string type "STRING"
@@ -110,6 +134,94 @@ func (s *String) Type() Type { return STRING }
 // Inspect will return the string value
 func (s *String) Inspect() string { return s.Value }
 
+// Len will return the number of code points in the string
+func (s *String) Len() int64 {
+	if s.isASCII() {
+		return int64(len(s.Value))
+	}
+	return int64(len(s.runes))
+}
+
+// At will return the code point at index i as a string
+func (s *String) At(i int64) Object {
+	if s.isASCII() {
+		return &String{Value: s.Value[i : i+1]}
+	}
+	return &String{Value: string(s.runes[i])}
+}
+
+// Slice will return the substring between the code points start and end
+func (s *String) Slice(start, end int64) Object {
+	if s.isASCII() {
+		return &String{Value: s.Value[start:end]}
+	}
+	return &String{Value: string(s.runes[start:end])}
+}
+
+// isASCII reports whether every code point is a single byte,
+// so indexes can be used on the bytes directly, the answer is
+// worked out once
+func (s *String) isASCII() bool {
+	if !s.counted {
+		s.counted = true
+		s.ascii = len(s.Value) == utf8.RuneCountInString(s.Value)
+		if !s.ascii {
+			s.runes = []rune(s.Value)
+		}
+	}
+	return s.ascii
+}
+
+// Char object, a single Unicode code point
+type Char struct {
+	Value rune
+}
+
+// Type will return the char type "CHAR"
+func (c *Char) Type() Type { return CHAR }
+
+// Inspect will return the char value
+func (c *Char) Inspect() string { return string(c.Value) }
+
+// Bytes object, a sequence of bytes that need not be valid UTF-8
+type Bytes struct {
+	Value []byte
+}
+
+// Type will return the bytes type "BYTES"
+func (b *Bytes) Type() Type { return BYTES }
+
+// Inspect will return the bytes as b'...', bytes that are not printable
+// ASCII, quotes and backslashes are written as \xNN escapes
+func (b *Bytes) Inspect() string {
+	var out strings.Builder
+
+	out.WriteString("b'")
+	for _, c := range b.Value {
+		if c >= ' ' && c <= '~' && c != '\'' && c != '"' && c != '\\' {
+			out.WriteByte(c)
+		} else {
+			fmt.Fprintf(&out, `\x%02x`, c)
+		}
+	}
+	out.WriteString("'")
+
+	return out.String()
+}
+
+// Len will return the number of bytes
+func (b *Bytes) Len() int64 { return int64(len(b.Value)) }
+
+// At will return the byte at index i as an integer
+func (b *Bytes) At(i int64) Object { return &Integer{Value: int64(b.Value[i])} }
+
+// Slice will return a copy of the bytes between start and end
+func (b *Bytes) Slice(start, end int64) Object {
+	value := make([]byte, end-start)
+	copy(value, b.Value[start:end])
+	return &Bytes{Value: value}
+}
+
 //Null struct
 type Null struct{}
 
@@ -141,12 +253,29 @@ func (e *Error) Type() Type { return ERROR }
 // Inspect will return the error message
 func (e *Error) Inspect() string { return "Error: " + e.Message }
 
+// Error lets an Error be returned as a Go error
+func (e *Error) Error() string { return e.Message }
+
 // BuiltinFunction type
 type BuiltinFunction func(args ...Object) Object
 
-// Builtin object
+// Caller calls Lorikeet functions for builtins, errors raised by the
+// called function are returned as they are. Rand is the random number
+// generator of the running program.
+type Caller interface {
+	Call(fn Object, args ...Object) (Object, error)
+	Rand() *rand.Rand
+}
+
+// CallbackFunction type of builtins that call the functions passed to
+// them, a returned error stops the program
+type CallbackFunction func(caller Caller, args ...Object) (Object, error)
+
+// Builtin object, builtins that take functions set CallbackFn
+// instead of Fn
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn         BuiltinFunction
+	CallbackFn CallbackFunction
 }
 
 // Type will return the inbuilt function type "BUILTIN"
@@ -158,6 +287,7 @@ func (b *Builtin) Inspect() string { return "builtin function" }
 // Array object
 type Array struct {
 	Elements []Object
+	Frozen   bool // set by Freeze, frozen arrays cannot be changed
 }
 
 // Type will return the array type "ARRAY"
@@ -179,6 +309,152 @@ func (ao *Array) Inspect() string {
 	return out.String()
 }
 
+// Len will return the number of elements
+func (ao *Array) Len() int64 { return int64(len(ao.Elements)) }
+
+// At will return the element at index i
+func (ao *Array) At(i int64) Object { return ao.Elements[i] }
+
+// Slice will return a new array of the elements between start and end
+func (ao *Array) Slice(start, end int64) Object {
+	elements := make([]Object, end-start)
+	copy(elements, ao.Elements[start:end])
+	return &Array{Elements: elements}
+}
+
+// Tuple object, an immutable fixed size sequence
+type Tuple struct {
+	Elements []Object
+}
+
+// Type will return the tuple type "TUPLE"
+func (t *Tuple) Type() Type { return TUPLE }
+
+// Inspect will return tuple value, a 1-tuple keeps its trailing comma
+func (t *Tuple) Inspect() string {
+	elements := []string{}
+	for _, e := range t.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	if len(elements) == 1 {
+		return "(" + elements[0] + ",)"
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
+// Len will return the number of elements
+func (t *Tuple) Len() int64 { return int64(len(t.Elements)) }
+
+// At will return the element at index i
+func (t *Tuple) At(i int64) Object { return t.Elements[i] }
+
+// Slice will return a new tuple of the elements between start and end
+func (t *Tuple) Slice(start, end int64) Object {
+	elements := make([]Object, end-start)
+	copy(elements, t.Elements[start:end])
+	return &Tuple{Elements: elements}
+}
+
+// Range object
+type Range struct {
+	Start     int64
+	End       int64
+	Inclusive bool
+}
+
+// Type will return the range type "RANGE"
+func (r *Range) Type() Type { return RANGE }
+
+// Inspect will return range value
+func (r *Range) Inspect() string {
+	if r.Inclusive {
+		return fmt.Sprintf("%d..=%d", r.Start, r.End)
+	}
+	return fmt.Sprintf("%d..%d", r.Start, r.End)
+}
+
+// NewRange makes the range from start to end, ok is false when it
+// holds more integers than its length can count
+func NewRange(start, end int64, inclusive bool) (r *Range, ok bool) {
+	r = &Range{Start: start, End: end, Inclusive: inclusive}
+	return r, r.length() <= math.MaxInt64
+}
+
+// Len will return the number of integers in the range
+func (r *Range) Len() int64 {
+	if n := r.length(); n <= math.MaxInt64 {
+		return int64(n)
+	}
+	return math.MaxInt64
+}
+
+// length counts the integers in the range without overflowing
+func (r *Range) length() uint64 {
+	if r.End < r.Start || (r.End == r.Start && !r.Inclusive) {
+		return 0
+	}
+	n := uint64(r.End) - uint64(r.Start)
+	if r.Inclusive && n < math.MaxUint64 {
+		n++
+	}
+	return n
+}
+
+// At will return the integer at index i
+func (r *Range) At(i int64) Object { return &Integer{Value: r.Start + i} }
+
+// Slice will return the sub range between start and end
+func (r *Range) Slice(start, end int64) Object {
+	return &Range{Start: r.Start + start, End: r.Start + end}
+}
+
+// Iterable is implemented by objects with ordered elements,
+// it provides len, indexing and slicing
+type Iterable interface {
+	Object
+	Len() int64
+	At(i int64) Object
+	Slice(start, end int64) Object
+}
+
+// ResolveIndex converts an index into an offset from the start of
+// the iterable, negative indexes count back from the end.
+// Returns false when the index is out of range.
+func ResolveIndex(it Iterable, i int64) (int64, bool) {
+	length := it.Len()
+	if i < 0 {
+		i += length
+	}
+	if i < 0 || i >= length {
+		return 0, false
+	}
+	return i, true
+}
+
+// SliceBounds resolves slice bounds against the iterable, negative
+// bounds count back from the end and are clamped to its length
+func SliceBounds(it Iterable, start, end int64) (int64, int64) {
+	length := it.Len()
+	clamp := func(i int64) int64 {
+		if i < 0 {
+			i += length
+		}
+		if i < 0 {
+			return 0
+		}
+		if i > length {
+			return length
+		}
+		return i
+	}
+
+	start, end = clamp(start), clamp(end)
+	if end < start {
+		end = start
+	}
+	return start, end
+}
+
 // HashKey object
 type HashKey struct {
 	Type  Type
@@ -211,15 +487,78 @@ func (s *String) HashKey() HashKey {
 	return HashKey{Type: s.Type(), Value: h.Sum64()}
 }
 
+// HashKey Char
+func (c *Char) HashKey() HashKey {
+	return HashKey{Type: c.Type(), Value: uint64(c.Value)}
+}
+
+// HashKey Bytes
+func (b *Bytes) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(b.Value)
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+// HashKey Float, 0.0 and -0.0 are equal so they share a key
+func (f *Float) HashKey() HashKey {
+	value := f.Value
+	if value == 0 {
+		value = 0
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+
+// HashKey Null
+func (n *Null) HashKey() HashKey {
+	return HashKey{Type: n.Type()}
+}
+
+// HashKey Array
+func (a *Array) HashKey() HashKey {
+	return HashKey{Type: a.Type(), Value: hashElements(a.Elements)}
+}
+
+// HashKey Tuple
+func (t *Tuple) HashKey() HashKey {
+	return HashKey{Type: t.Type(), Value: hashElements(t.Elements)}
+}
+
+// hashElements combines the keys of elements. Elements that are not
+// Hashable only add their type, Hash compares keys with Equal so this
+// only makes collisions more likely.
+func hashElements(elements []Object) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 8)
+	for _, el := range elements {
+		h.Write([]byte(el.Type()))
+		if hashable, ok := el.(Hashable); ok {
+			binary.LittleEndian.PutUint64(buf, hashable.HashKey().Value)
+			h.Write(buf)
+		}
+	}
+	return h.Sum64()
+}
+
 // HashPair object
 type HashPair struct {
 	Key   Object
 	Value Object
 }
 
-// Hash object
+// Hash object, pairs are bucketed by HashKey and keys within a bucket
+// are compared with Equal, so keys whose hashes collide are kept apart.
+// Pairs are kept in insertion order.
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	buckets map[HashKey][]int // indexes into pairs
+	pairs   []HashPair
+
+	Frozen bool // set by Freeze, frozen hashes cannot be changed
+}
+
+// NewHash creates an empty hash
+func NewHash() *Hash {
+	return &Hash{buckets: make(map[HashKey][]int)}
 }
 
 // Type will return the hash type "HASH"
@@ -230,7 +569,7 @@ func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
+	for _, pair := range h.pairs {
 		pairs = append(pairs, fmt.Sprintf("%s: %s",
 			pair.Key.Inspect(), pair.Value.Inspect()))
 	}
@@ -242,8 +581,52 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
-// Hashable provides HashKey function to HashKey object
+// Set adds a pair, or replaces the value of an equal key in place.
+// New keys are frozen so their hash cannot change while in the hash.
+func (h *Hash) Set(key Hashable, value Object) {
+	if h.buckets == nil {
+		h.buckets = make(map[HashKey][]int)
+	}
+
+	hashKey := key.HashKey()
+	if i, ok := h.find(hashKey, key); ok {
+		h.pairs[i].Value = value
+		return
+	}
+
+	Freeze(key)
+	h.buckets[hashKey] = append(h.buckets[hashKey], len(h.pairs))
+	h.pairs = append(h.pairs, HashPair{Key: key, Value: value})
+}
+
+// Get returns the value stored under a key equal to key
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	i, ok := h.find(key.HashKey(), key)
+	if !ok {
+		return nil, false
+	}
+	return h.pairs[i].Value, true
+}
+
+func (h *Hash) find(hashKey HashKey, key Hashable) (int, bool) {
+	for _, i := range h.buckets[hashKey] {
+		if Equal(h.pairs[i].Key, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// Len returns the number of pairs
+func (h *Hash) Len() int { return len(h.pairs) }
+
+// Pairs returns the pairs in insertion order
+func (h *Hash) Pairs() []HashPair { return h.pairs }
+
+// Hashable provides HashKey function to HashKey object,
+// values with equal contents must have the same HashKey
 type Hashable interface {
+	Object
 	HashKey() HashKey
 }
 
@@ -294,6 +677,7 @@ type CompiledFunction struct {
 	Instructions  code.Instructions
 	NumLocals     int
 	NumParameters int
+	Generator     bool
 }
 
 // Type will return compiled function type "COMPILED_FUNCTION"
@@ -317,3 +701,70 @@ func (c *Closure) Type() Type { return CLOSURE }
 func (c *Closure) Inspect() string {
 	return fmt.Sprintf("Closure[%p]", c)
 }
+
+// Generator object holds the suspended frame of a generator function,
+// calling it resumes the frame until the next yield
+type Generator struct {
+	Closure *Closure
+	IP      int
+	Stack   []Object // locals and operands of the suspended frame
+	Done    bool
+	Running bool
+
+	// Next makes the values of a generator made by a builtin instead of
+	// a frame, it sets Done when the values run out
+	Next func(caller Caller) (Object, error)
+}
+
+// Type will return generator type "GENERATOR"
+func (g *Generator) Type() Type { return GENERATOR }
+
+// Inspect will return generator value
+func (g *Generator) Inspect() string {
+	return fmt.Sprintf("Generator[%p]", g)
+}
+
+// Variant object is a value tagged with an enum variant
+type Variant struct {
+	Enum   string
+	Name   string
+	Values []Object
+}
+
+// Type will return variant type "VARIANT"
+func (v *Variant) Type() Type { return VARIANT }
+
+// Inspect will return variant value
+func (v *Variant) Inspect() string {
+	if len(v.Values) == 0 {
+		return v.Name
+	}
+
+	values := []string{}
+	for _, val := range v.Values {
+		values = append(values, val.Inspect())
+	}
+
+	return v.Name + "(" + strings.Join(values, ", ") + ")"
+}
+
+// Tag will return the enum and variant name, e.g. "Shape.Circle"
+func (v *Variant) Tag() string { return v.Enum + "." + v.Name }
+
+// Constructor object creates variants of an enum
+type Constructor struct {
+	Enum   string
+	Name   string
+	Fields []string
+}
+
+// Type will return constructor type "CONSTRUCTOR"
+func (c *Constructor) Type() Type { return CONSTRUCT }
+
+// Inspect will return constructor value
+func (c *Constructor) Inspect() string {
+	return fmt.Sprintf("constructor %s(%s)", c.Name, strings.Join(c.Fields, ", "))
+}
+
+// Tag will return the enum and variant name, e.g. "Shape.Circle"
+func (c *Constructor) Tag() string { return c.Enum + "." + c.Name }
@@ -1,6 +1,9 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -21,6 +24,35 @@ func TestStringHashKey(t *testing.T) {
 	}
 }
 
+func TestStringIndexes(t *testing.T) {
+	tests := []struct {
+		value string
+		len   int64
+		at    string
+		slice string
+	}{
+		{"hello", 5, "e", "el"},
+		{"héllo", 5, "é", "él"},
+		{"a😀bc", 4, "😀", "😀b"},
+		{"\xffab", 3, "a", "ab"},
+	}
+
+	for _, tt := range tests {
+		s := &String{Value: tt.value}
+		for i := 0; i < 2; i++ {
+			if s.Len() != tt.len {
+				t.Errorf("wrong len of %q. want=%d, got=%d", tt.value, tt.len, s.Len())
+			}
+			if at := s.At(1).Inspect(); at != tt.at {
+				t.Errorf("wrong code point 1 of %q. want=%q, got=%q", tt.value, tt.at, at)
+			}
+			if slice := s.Slice(1, 3).Inspect(); slice != tt.slice {
+				t.Errorf("wrong slice 1:3 of %q. want=%q, got=%q", tt.value, tt.slice, slice)
+			}
+		}
+	}
+}
+
 func TestBooleanHashKey(t *testing.T) {
 	true1 := &Boolean{Value: true}
 	true2 := &Boolean{Value: true}
@@ -58,3 +90,398 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestBigIntHashKey(t *testing.T) {
+	big1 := IntegerOperation("*", &Integer{Value: math.MaxInt64}, &Integer{Value: 2})
+	big2 := IntegerOperation("*", &Integer{Value: math.MaxInt64}, &Integer{Value: 2})
+	neg := NegateInteger(big1)
+
+	if big1.(*BigInt).HashKey() != big2.(*BigInt).HashKey() {
+		t.Errorf("big integers with same content have different hash keys")
+	}
+
+	if big1.(*BigInt).HashKey() == neg.(*BigInt).HashKey() {
+		t.Errorf("big integers with different signs have same hash keys")
+	}
+}
+
+func TestIntegerOperationOverflow(t *testing.T) {
+	tests := []struct {
+		operator    string
+		left, right int64
+		expected    string
+	}{
+		{"+", math.MaxInt64, 1, "9223372036854775808"},
+		{"+", math.MinInt64, -1, "-9223372036854775809"},
+		{"-", math.MinInt64, 1, "-9223372036854775809"},
+		{"-", 0, math.MinInt64, "9223372036854775808"},
+		{"*", math.MaxInt64, 2, "18446744073709551614"},
+		{"*", -1, math.MinInt64, "9223372036854775808"},
+		{"/", math.MinInt64, -1, "9223372036854775808"},
+		{"+", 1, 2, "3"},
+		{"*", -3, 4, "-12"},
+	}
+
+	for _, tt := range tests {
+		result := IntegerOperation(tt.operator, &Integer{Value: tt.left}, &Integer{Value: tt.right})
+		if result.Inspect() != tt.expected {
+			t.Errorf("%d %s %d wrong. want=%s, got=%s",
+				tt.left, tt.operator, tt.right, tt.expected, result.Inspect())
+		}
+
+		_, isInteger := result.(*Integer)
+		if isInteger != BigValue(result).IsInt64() {
+			t.Errorf("%d %s %d has wrong type %s", tt.left, tt.operator, tt.right, result.Type())
+		}
+	}
+}
+
+func TestEqual(t *testing.T) {
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+	nan := &Float{Value: math.NaN()}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{&Float{Value: 1.5}, &Float{Value: 1.5}, true},
+		{nan, nan, false},
+		{&Integer{Value: 1}, &Float{Value: 1}, false},
+		{&Boolean{Value: true}, &Boolean{Value: true}, true},
+		{&Null{}, &Null{}, true},
+		{&Null{}, &Boolean{Value: false}, false},
+		{array(&Integer{Value: 1}, &String{Value: "a"}), array(&Integer{Value: 1}, &String{Value: "a"}), true},
+		{array(&Integer{Value: 1}), array(&Integer{Value: 1}, &Integer{Value: 2}), false},
+		{&Range{Start: 0, End: 0}, &Range{Start: 5, End: 5}, true},
+		{&Range{Start: 0, End: 2}, &Range{Start: 0, End: 1, Inclusive: true}, true},
+	}
+
+	for i, tt := range tests {
+		if Equal(tt.a, tt.b) != tt.expected {
+			t.Errorf("tests[%d] Equal(%s, %s) wrong. want=%t", i, tt.a.Inspect(), tt.b.Inspect(), tt.expected)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+
+	tests := []struct {
+		a, b     Object
+		expected int
+		ok       bool
+	}{
+		{&Float{Value: 1.5}, &Float{Value: 2.5}, -1, true},
+		{&Float{Value: math.NaN()}, &Float{Value: 2.5}, 0, false},
+		{&String{Value: "b"}, &String{Value: "a"}, 1, true},
+		{array(&Integer{Value: 1}), array(&Integer{Value: 1}), 0, true},
+		{array(&Integer{Value: 1}), array(&Integer{Value: 1}, &Integer{Value: 0}), -1, true},
+		{array(&Integer{Value: 2}), array(&Integer{Value: 1}, &Integer{Value: 9}), 1, true},
+		{array(&Boolean{Value: true}), array(&Boolean{Value: false}), 0, false},
+		{&Boolean{Value: true}, &Boolean{Value: false}, 0, false},
+		{&Integer{Value: 1}, &String{Value: "1"}, 0, false},
+	}
+
+	for i, tt := range tests {
+		cmp, ok := Compare(tt.a, tt.b)
+		if cmp != tt.expected || ok != tt.ok {
+			t.Errorf("tests[%d] Compare(%s, %s) wrong. want=(%d, %t), got=(%d, %t)",
+				i, tt.a.Inspect(), tt.b.Inspect(), tt.expected, tt.ok, cmp, ok)
+		}
+	}
+}
+
+// collidingKey has the same HashKey as every other collidingKey
+type collidingKey struct {
+	*String
+}
+
+func (k collidingKey) HashKey() HashKey { return HashKey{Type: STRING, Value: 1} }
+
+func TestHashCollisions(t *testing.T) {
+	a := collidingKey{&String{Value: "a"}}
+	b := collidingKey{&String{Value: "b"}}
+
+	hash := NewHash()
+	hash.Set(a, &Integer{Value: 1})
+	hash.Set(b, &Integer{Value: 2})
+
+	if hash.Len() != 2 {
+		t.Fatalf("colliding keys overwrote each other. got=%d pairs", hash.Len())
+	}
+	for key, expected := range map[Hashable]int64{a: 1, b: 2} {
+		value, ok := hash.Get(key)
+		if !ok {
+			t.Fatalf("no pair for %s", key.Inspect())
+		}
+		if value.(*Integer).Value != expected {
+			t.Errorf("wrong value for %s. want=%d, got=%s", key.Inspect(), expected, value.Inspect())
+		}
+	}
+
+	hash.Set(b, &Integer{Value: 3})
+	if hash.Len() != 2 || hash.Pairs()[1].Value.(*Integer).Value != 3 {
+		t.Errorf("setting an existing key did not replace its value in place")
+	}
+}
+
+func TestFloatNullArrayHashKeys(t *testing.T) {
+	array := func(elements ...Object) *Array { return &Array{Elements: elements} }
+
+	equal := [][2]Hashable{
+		{&Float{Value: 1.5}, &Float{Value: 1.5}},
+		{&Float{Value: 0}, &Float{Value: math.Copysign(0, -1)}},
+		{&Null{}, &Null{}},
+		{array(&Integer{Value: 1}, &String{Value: "a"}), array(&Integer{Value: 1}, &String{Value: "a"})},
+	}
+	for _, pair := range equal {
+		if pair[0].HashKey() != pair[1].HashKey() {
+			t.Errorf("%s and %s have different hash keys", pair[0].Inspect(), pair[1].Inspect())
+		}
+	}
+
+	different := [][2]Hashable{
+		{&Float{Value: 1.5}, &Float{Value: 2.5}},
+		{array(&Integer{Value: 1}, &Integer{Value: 2}), array(&Integer{Value: 2}, &Integer{Value: 1})},
+		{array(&Integer{Value: 1}), array(&String{Value: "1"})},
+	}
+	for _, pair := range different {
+		if pair[0].HashKey() == pair[1].HashKey() {
+			t.Errorf("%s and %s have the same hash key", pair[0].Inspect(), pair[1].Inspect())
+		}
+	}
+}
+
+func TestSets(t *testing.T) {
+	set := func(elements ...int64) *Set {
+		s := NewSet()
+		for _, e := range elements {
+			s.Add(&Integer{Value: e})
+		}
+		return s
+	}
+
+	tests := []struct {
+		operator string
+		expected string
+	}{
+		{"|", "#{1, 2, 3}"},
+		{"&", "#{2}"},
+		{"-", "#{1}"},
+	}
+
+	for _, tt := range tests {
+		result := SetOperation(tt.operator, set(1, 2, 2), set(2, 3))
+		if result.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. want=%q, got=%q", tt.operator, tt.expected, result.Inspect())
+		}
+	}
+
+	tuple := &Tuple{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	same := &Tuple{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+	array := &Array{Elements: []Object{&Integer{Value: 1}, &String{Value: "a"}}}
+
+	s := NewSet()
+	s.Add(tuple)
+	s.Add(same)
+	if s.Len() != 1 || !s.Has(same) {
+		t.Errorf("equal tuples were not merged. got=%s", s.Inspect())
+	}
+	if s.Has(array) {
+		t.Errorf("set of tuples has an array with the same elements")
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		container, element Object
+		found, ok          bool
+	}{
+		{&Array{Elements: []Object{&Float{Value: 1.5}}}, &Float{Value: 1.5}, true, true},
+		{&Tuple{Elements: []Object{&Integer{Value: 1}}}, &Integer{Value: 2}, false, true},
+		{&String{Value: "hello"}, &String{Value: "ell"}, true, true},
+		{&String{Value: "hello"}, &Integer{Value: 1}, false, false},
+		{&Range{Start: 1, End: 3}, &Integer{Value: 2}, true, true},
+		{&Range{Start: 1, End: 3}, &Integer{Value: 3}, false, true},
+		{&Range{Start: 1, End: 3}, &String{Value: "a"}, false, false},
+		{NewHash(), &Array{}, false, true},
+		{&Integer{Value: 1}, &Integer{Value: 1}, false, false},
+	}
+
+	for i, tt := range tests {
+		found, ok := Contains(tt.container, tt.element)
+		if found != tt.found || ok != tt.ok {
+			t.Errorf("tests[%d] Contains(%s, %s) wrong. want=(%t, %t), got=(%t, %t)",
+				i, tt.container.Inspect(), tt.element.Inspect(), tt.found, tt.ok, found, ok)
+		}
+	}
+}
+
+func TestUnicodeStringsAndBytes(t *testing.T) {
+	s := &String{Value: "héllo"}
+	if s.Len() != 5 {
+		t.Errorf("wrong length. want=5, got=%d", s.Len())
+	}
+	if at := s.At(1).Inspect(); at != "é" {
+		t.Errorf("wrong code point at 1. want=%q, got=%q", "é", at)
+	}
+	if slice := s.Slice(1, 4).Inspect(); slice != "éll" {
+		t.Errorf("wrong slice. want=%q, got=%q", "éll", slice)
+	}
+
+	b := &Bytes{Value: []byte("a'\\\x00\xff")}
+	expected := `b'a\x27\x5c\x00\xff'`
+	if b.Inspect() != expected {
+		t.Errorf("wrong bytes inspect. want=%q, got=%q", expected, b.Inspect())
+	}
+
+	if (&Char{Value: 'a'}).HashKey() == (&Integer{Value: 'a'}).HashKey() {
+		t.Errorf("char and integer have the same hash key")
+	}
+	if (&Bytes{Value: []byte("a")}).HashKey() != (&Bytes{Value: []byte("a")}).HashKey() {
+		t.Errorf("equal bytes have different hash keys")
+	}
+	if (&Bytes{Value: []byte("a")}).HashKey() == (&String{Value: "a"}).HashKey() {
+		t.Errorf("bytes and string have the same hash key")
+	}
+}
+
+func TestFreeze(t *testing.T) {
+	inner := &Array{Elements: []Object{&Integer{Value: 1}}}
+	hash := NewHash()
+	hash.Set(&String{Value: "a"}, inner)
+	outer := &Array{Elements: []Object{hash, &Tuple{Elements: []Object{inner}}}}
+
+	if IsFrozen(outer) {
+		t.Fatalf("array is frozen before Freeze")
+	}
+	if Freeze(outer) != outer {
+		t.Errorf("Freeze did not return its argument")
+	}
+	if !outer.Frozen || !hash.Frozen || !inner.Frozen {
+		t.Errorf("Freeze did not freeze nested values")
+	}
+
+	key := &Array{Elements: []Object{&Integer{Value: 1}}}
+	NewHash().Set(key, &Integer{Value: 1})
+	if !key.Frozen {
+		t.Errorf("hash key was not frozen")
+	}
+}
+
+func TestReferences(t *testing.T) {
+	target := &Array{}
+	hash := NewHash()
+	hash.Set(&Integer{Value: 1}, &Tuple{Elements: []Object{target}})
+
+	tests := []struct {
+		obj      Object
+		expected bool
+	}{
+		{target, true},
+		{&Array{Elements: []Object{hash}}, true},
+		{&Array{Elements: []Object{&Array{}}}, false},
+		{&Integer{Value: 1}, false},
+		{Freeze(&Array{Elements: []Object{&Array{}}}), false},
+	}
+
+	for i, tt := range tests {
+		if actual := References(tt.obj, target); actual != tt.expected {
+			t.Errorf("tests[%d] References(%s) wrong. want=%t, got=%t",
+				i, tt.obj.Inspect(), tt.expected, actual)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []string{
+		`null`,
+		`[true,false,0,-12,1.5,-0.25,1e+21]`,
+		`123456789012345678901234567890`,
+		`{"b":1,"a":[],"c":{}}`,
+		`"a\"b\\c\nd\u0001é😀"`,
+		`{"x":{"y":[1,[2,[3]]]}}`,
+	}
+
+	for _, input := range tests {
+		value, err := ParseJSON(input)
+		if err != nil {
+			t.Fatalf("ParseJSON(%q) error: %s", input, err.Message)
+		}
+		output, err := StringifyJSON(value, "")
+		if err != nil {
+			t.Fatalf("StringifyJSON(%q) error: %s", input, err.Message)
+		}
+		if output != input {
+			t.Errorf("wrong round trip. want=%q, got=%q", input, output)
+		}
+	}
+
+	value, _ := ParseJSON(`"😀 é\t"`)
+	if actual := value.(*String).Value; actual != "😀 é\t" {
+		t.Errorf("wrong escapes. want=%q, got=%q", "😀 é\t", actual)
+	}
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{``, "invalid JSON at line 1, column 1: unexpected end of input"},
+		{`[1, 2`, "invalid JSON at line 1, column 6: expected ',' or ']' in array, got end of input"},
+		{"{\n  \"a\": x}", "invalid JSON at line 2, column 8: unexpected character 'x'"},
+		{`{"é": 1 2}`, "invalid JSON at line 1, column 9: expected ',' or '}' in object, got character '2'"},
+		{`{1: 2}`, "invalid JSON at line 1, column 2: expected string key, got character '1'"},
+		{`{"a" 1}`, "invalid JSON at line 1, column 6: expected ':' after object key, got character '1'"},
+		{`"a\qb"`, "invalid JSON at line 1, column 4: invalid escape character 'q'"},
+		{`"a`, "invalid JSON at line 1, column 3: unterminated string"},
+		{"\"a\nb\"", "invalid JSON at line 1, column 3: character '\\n' must be escaped in string"},
+		{`01`, "invalid JSON at line 1, column 2: unexpected character '1' after JSON value"},
+		{`-`, "invalid JSON at line 1, column 2: expected digit, got end of input"},
+		{`1.`, "invalid JSON at line 1, column 3: expected digit after '.', got end of input"},
+		{`1e400`, "invalid JSON at line 1, column 1: number 1e400 is out of range"},
+		{`[1] x`, "invalid JSON at line 1, column 5: unexpected character 'x' after JSON value"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseJSON(tt.input)
+		if err == nil {
+			t.Errorf("no error for %q", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("wrong error for %q. want=%q, got=%q", tt.input, tt.expected, err.Message)
+		}
+	}
+
+	stringifyTests := []struct {
+		input    Object
+		expected string
+	}{
+		{&Float{Value: math.NaN()}, "cannot encode NaN as JSON"},
+		{&Set{}, "cannot encode SET as JSON"},
+		{&Array{Elements: []Object{&Builtin{}}}, "cannot encode BUILTIN as JSON"},
+	}
+
+	for _, tt := range stringifyTests {
+		_, err := StringifyJSON(tt.input, "")
+		if err == nil || err.Message != tt.expected {
+			t.Errorf("wrong error for %s. want=%q, got=%v", tt.input.Type(), tt.expected, err)
+		}
+	}
+}
+
+func TestCompileRegexCache(t *testing.T) {
+	a, err := CompileRegex(`\d+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := CompileRegex(`\d+`)
+	if a != b {
+		t.Errorf("pattern was compiled twice")
+	}
+	if _, err := CompileRegex("("); err == nil {
+		t.Errorf("no error for invalid pattern")
+	}
+}
@@ -0,0 +1,21 @@
+package object
+
+import "fmt"
+
+// Args are the command line arguments after the script, for args()
+var Args []string
+
+// Exit object is returned by exit, it stops the program and the host
+// exits the process with Code
+type Exit struct {
+	Code int
+}
+
+// Type will return the exit type "EXIT"
+func (e *Exit) Type() Type { return EXIT }
+
+// Inspect will return the call that made the exit
+func (e *Exit) Inspect() string { return fmt.Sprintf("exit(%d)", e.Code) }
+
+// Error lets the VM return an Exit from Run
+func (e *Exit) Error() string { return fmt.Sprintf("exit status %d", e.Code) }
@@ -0,0 +1,37 @@
+package object
+
+import (
+	"math"
+	"math/rand"
+)
+
+// maxShuffle limits the elements shuffle copies, so shuffling a long
+// range fails rather than running out of memory
+const maxShuffle = 1 << 24
+
+// NewRand returns a random number generator for a program, the same
+// seed always gives the same numbers
+func NewRand(seed int64) *rand.Rand {
+	return rand.New(rand.NewSource(seed))
+}
+
+// randomBetween returns a random integer from lo to hi, both included
+func randomBetween(r *rand.Rand, lo, hi int64) int64 {
+	span := uint64(hi) - uint64(lo)
+	if span == math.MaxUint64 {
+		return int64(r.Uint64())
+	}
+	if span < math.MaxInt64 {
+		return lo + r.Int63n(int64(span)+1)
+	}
+
+	// Reject the values past the largest multiple of span+1 so every
+	// result is as likely
+	n := span + 1
+	limit := math.MaxUint64 - math.MaxUint64%n
+	v := r.Uint64()
+	for v >= limit {
+		v = r.Uint64()
+	}
+	return int64(uint64(lo) + v%n)
+}
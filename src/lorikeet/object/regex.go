@@ -0,0 +1,176 @@
+package object
+
+import (
+	"regexp"
+	"sync"
+)
+
+// Regex object holds a compiled regular expression, its methods are
+// read with regex.name
+type Regex struct {
+	Value *regexp.Regexp
+}
+
+// Type will return the regex type "REGEX"
+func (r *Regex) Type() Type { return REGEX }
+
+// Inspect will return the regex as the call that makes it, with the
+// pattern quoted like a string literal
+func (r *Regex) Inspect() string { return `re("` + escape(r.Value.String()) + `")` }
+
+// maxCachedRegexes limits the patterns kept by CompileRegex
+const maxCachedRegexes = 1000
+
+var regexCache = struct {
+	sync.Mutex
+	patterns map[string]*Regex
+}{patterns: make(map[string]*Regex)}
+
+// CompileRegex compiles a pattern, patterns are cached so a regex made
+// inside a loop is only compiled once
+func CompileRegex(pattern string) (*Regex, error) {
+	regexCache.Lock()
+	defer regexCache.Unlock()
+
+	if r, ok := regexCache.patterns[pattern]; ok {
+		return r, nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	r := &Regex{Value: compiled}
+	if len(regexCache.patterns) < maxCachedRegexes {
+		regexCache.patterns[pattern] = r
+	}
+	return r, nil
+}
+
+// Method returns the method name bound to r
+func (r *Regex) Method(name string) (*Builtin, bool) {
+	switch name {
+	case "match":
+		return r.subjectMethod("regex.match", func(s string) Object {
+			return &Boolean{Value: r.Value.MatchString(s)}
+		}), true
+	case "find":
+		return r.subjectMethod("regex.find", func(s string) Object {
+			match := r.Value.FindStringIndex(s)
+			if match == nil {
+				return nil
+			}
+			return &String{Value: s[match[0]:match[1]]}
+		}), true
+	case "find_all":
+		return r.subjectMethod("regex.find_all", func(s string) Object {
+			return stringArray(r.Value.FindAllString(s, -1))
+		}), true
+	case "captures":
+		return r.subjectMethod("regex.captures", func(s string) Object {
+			match := r.Value.FindStringSubmatchIndex(s)
+			if match == nil {
+				return nil
+			}
+			return &Array{Elements: groups(s, match)}
+		}), true
+	case "named":
+		return r.subjectMethod("regex.named", func(s string) Object {
+			match := r.Value.FindStringSubmatchIndex(s)
+			if match == nil {
+				return nil
+			}
+			hash := NewHash()
+			for i, group := range groups(s, match) {
+				if name := r.Value.SubexpNames()[i]; name != "" {
+					hash.Set(&String{Value: name}, group)
+				}
+			}
+			return hash
+		}), true
+	case "split":
+		return r.subjectMethod("regex.split", func(s string) Object {
+			return stringArray(r.Value.Split(s, -1))
+		}), true
+	case "replace":
+		return r.replaceMethod(), true
+	}
+	return nil, false
+}
+
+// subjectMethod returns a method taking one string to match against
+func (r *Regex) subjectMethod(name string, fn func(s string) Object) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		s, err := stringArgs(name, args, 1)
+		if err != nil {
+			return err
+		}
+
+		return fn(s[0])
+	},
+	}
+}
+
+// replaceMethod returns the replace method, which replaces every match
+// with a string, where $1 or ${name} stand for groups, or with the
+// result of calling a function with the array of the match and its groups
+func (r *Regex) replaceMethod() *Builtin {
+	return &Builtin{CallbackFn: func(caller Caller, args ...Object) (Object, error) {
+		if len(args) != 2 {
+			return newError("wrong number of arguments. got=%d, want=2",
+				len(args)), nil
+		}
+		s, ok := args[0].(*String)
+		if !ok {
+			return newError("argument 1 to `regex.replace` must be STRING, got %s",
+				args[0].Type()), nil
+		}
+		subject := s.Value
+
+		if repl, ok := args[1].(*String); ok {
+			return &String{Value: r.Value.ReplaceAllString(subject, repl.Value)}, nil
+		}
+		if !isFunction(args[1]) {
+			return newError("argument 2 to `regex.replace` must be STRING or a function, got %s",
+				args[1].Type()), nil
+		}
+
+		var out []byte
+		last := 0
+		for _, match := range r.Value.FindAllStringSubmatchIndex(subject, -1) {
+			result, err := caller.Call(args[1], &Array{Elements: groups(subject, match)})
+			if err != nil {
+				return nil, err
+			}
+			if errObj, ok := result.(*Error); ok {
+				return errObj, nil
+			}
+			repl, ok := result.(*String)
+			if !ok {
+				return newError("function passed to `regex.replace` must return STRING, got %s",
+					result.Type()), nil
+			}
+			out = append(out, subject[last:match[0]]...)
+			out = append(out, repl.Value...)
+			last = match[1]
+		}
+		out = append(out, subject[last:]...)
+
+		return &String{Value: string(out)}, nil
+	},
+	}
+}
+
+// groups returns the match and each group from the indexes of a
+// submatch, a group that did not take part is null
+func groups(s string, match []int) []Object {
+	elements := make([]Object, len(match)/2)
+	for i := range elements {
+		start, end := match[2*i], match[2*i+1]
+		if start < 0 {
+			elements[i] = &Null{}
+			continue
+		}
+		elements[i] = &String{Value: s[start:end]}
+	}
+	return elements
+}
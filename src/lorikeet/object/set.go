@@ -0,0 +1,135 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Set object of unique Hashable elements, kept in insertion order
+type Set struct {
+	elements *Hash
+}
+
+// NewSet creates an empty set
+func NewSet() *Set {
+	return &Set{elements: NewHash()}
+}
+
+// Type will return the set type "SET"
+func (s *Set) Type() Type { return SET }
+
+// Inspect will return set value
+func (s *Set) Inspect() string {
+	elements := []string{}
+	for _, e := range s.Elements() {
+		elements = append(elements, e.Inspect())
+	}
+	return "#{" + strings.Join(elements, ", ") + "}"
+}
+
+// Add adds el unless an equal element is already in the set
+func (s *Set) Add(el Hashable) {
+	if !s.Has(el) {
+		s.elements.Set(el, nil)
+	}
+}
+
+// Has reports whether an element equal to el is in the set
+func (s *Set) Has(el Object) bool {
+	hashable, ok := el.(Hashable)
+	if !ok {
+		return false
+	}
+	_, ok = s.elements.Get(hashable)
+	return ok
+}
+
+// Len returns the number of elements
+func (s *Set) Len() int { return s.elements.Len() }
+
+// Elements returns the elements in insertion order
+func (s *Set) Elements() []Object {
+	elements := make([]Object, s.Len())
+	for i, pair := range s.elements.Pairs() {
+		elements[i] = pair.Key
+	}
+	return elements
+}
+
+// SetOperation returns the union (|), intersection (&) or
+// difference (-) of two sets
+func SetOperation(operator string, left, right *Set) *Set {
+	result := NewSet()
+
+	switch operator {
+	case "|":
+		for _, e := range append(left.Elements(), right.Elements()...) {
+			result.Add(e.(Hashable))
+		}
+	case "&":
+		for _, e := range left.Elements() {
+			if right.Has(e) {
+				result.Add(e.(Hashable))
+			}
+		}
+	case "-":
+		for _, e := range left.Elements() {
+			if !right.Has(e) {
+				result.Add(e.(Hashable))
+			}
+		}
+	}
+
+	return result
+}
+
+// Contains reports whether element is in container, ok is false when
+// container does not support the in operator for element
+func Contains(container, element Object) (found bool, ok bool) {
+	switch container := container.(type) {
+	case *Set:
+		return container.Has(element), true
+	case *Hash:
+		hashable, isHashable := element.(Hashable)
+		if !isHashable {
+			return false, true
+		}
+		_, found = container.Get(hashable)
+		return found, true
+	case *Array:
+		return containsEqual(container.Elements, element), true
+	case *Tuple:
+		return containsEqual(container.Elements, element), true
+	case *String:
+		switch element := element.(type) {
+		case *String:
+			return strings.Contains(container.Value, element.Value), true
+		case *Char:
+			return strings.ContainsRune(container.Value, element.Value), true
+		}
+		return false, false
+	case *Bytes:
+		b, isBytes := element.(*Bytes)
+		if !isBytes {
+			return false, false
+		}
+		return bytes.Contains(container.Value, b.Value), true
+	case *Range:
+		i, isInteger := element.(*Integer)
+		if !isInteger {
+			return false, IsInteger(element)
+		}
+		return i.Value >= container.Start && i.Value-container.Start < container.Len(), true
+	}
+
+	return false, false
+}
+
+func containsEqual(elements []Object, element Object) bool {
+	for _, e := range elements {
+		if Equal(e, element) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,289 @@
+package object
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Strings module of string functions, lengths and indexes count code
+// points like len and indexing do
+var Strings = &Module{
+	Name: "strings",
+	Members: map[string]Object{
+		"split": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.split", args, 2)
+			if err != nil {
+				return err
+			}
+
+			return stringArray(strings.Split(s[0], s[1]))
+		},
+		},
+		"join": &Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			arr, ok := args[0].(*Array)
+			if !ok {
+				return newError("argument 1 to `strings.join` must be ARRAY, got %s",
+					args[0].Type())
+			}
+			sep, ok := args[1].(*String)
+			if !ok {
+				return newError("argument 2 to `strings.join` must be STRING, got %s",
+					args[1].Type())
+			}
+
+			parts := make([]string, len(arr.Elements))
+			for i, el := range arr.Elements {
+				switch el := el.(type) {
+				case *String:
+					parts[i] = el.Value
+				case *Char:
+					parts[i] = string(el.Value)
+				default:
+					return newError("argument 1 to `strings.join` must be an ARRAY of STRING, got %s at %d",
+						el.Type(), i)
+				}
+			}
+
+			return &String{Value: strings.Join(parts, sep.Value)}
+		},
+		},
+		"trim": trimBuiltin("strings.trim", strings.TrimSpace, strings.Trim),
+		"trim_left": trimBuiltin("strings.trim_left", func(s string) string {
+			return strings.TrimLeftFunc(s, unicode.IsSpace)
+		}, strings.TrimLeft),
+		"trim_right": trimBuiltin("strings.trim_right", func(s string) string {
+			return strings.TrimRightFunc(s, unicode.IsSpace)
+		}, strings.TrimRight),
+		"replace": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.replace", args, 3)
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.ReplaceAll(s[0], s[1], s[2])}
+		},
+		},
+		"contains": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.contains", args, 2)
+			if err != nil {
+				return err
+			}
+
+			return &Boolean{Value: strings.Contains(s[0], s[1])}
+		},
+		},
+		"starts_with": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.starts_with", args, 2)
+			if err != nil {
+				return err
+			}
+
+			return &Boolean{Value: strings.HasPrefix(s[0], s[1])}
+		},
+		},
+		"ends_with": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.ends_with", args, 2)
+			if err != nil {
+				return err
+			}
+
+			return &Boolean{Value: strings.HasSuffix(s[0], s[1])}
+		},
+		},
+		"index_of": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.index_of", args, 2)
+			if err != nil {
+				return err
+			}
+
+			i := strings.Index(s[0], s[1])
+			if i < 0 {
+				return &Integer{Value: -1}
+			}
+			return &Integer{Value: int64(utf8.RuneCountInString(s[0][:i]))}
+		},
+		},
+		"upper": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.upper", args, 1)
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.ToUpper(s[0])}
+		},
+		},
+		"lower": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.lower", args, 1)
+			if err != nil {
+				return err
+			}
+
+			return &String{Value: strings.ToLower(s[0])}
+		},
+		},
+		"repeat": &Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2",
+					len(args))
+			}
+			s, ok := args[0].(*String)
+			if !ok {
+				return newError("argument 1 to `strings.repeat` must be STRING, got %s",
+					args[0].Type())
+			}
+			n, ok := args[1].(*Integer)
+			if !ok || n.Value < 0 {
+				return newError("argument 2 to `strings.repeat` must be a non-negative INTEGER, got %s",
+					args[1].Inspect())
+			}
+			if len(s.Value) > 0 && n.Value > maxStringLength/int64(len(s.Value)) {
+				return newError("result of `strings.repeat` is too long")
+			}
+
+			return &String{Value: strings.Repeat(s.Value, int(n.Value))}
+		},
+		},
+		"pad_left":  padBuiltin("strings.pad_left", true),
+		"pad_right": padBuiltin("strings.pad_right", false),
+		"chars": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.chars", args, 1)
+			if err != nil {
+				return err
+			}
+
+			elements := []Object{}
+			for _, r := range s[0] {
+				elements = append(elements, &Char{Value: r})
+			}
+			return &Array{Elements: elements}
+		},
+		},
+		"lines": &Builtin{Fn: func(args ...Object) Object {
+			s, err := stringArgs("strings.lines", args, 1)
+			if err != nil {
+				return err
+			}
+
+			text := strings.TrimSuffix(s[0], "\n")
+			if text == "" {
+				return &Array{Elements: []Object{}}
+			}
+			lines := strings.Split(text, "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimSuffix(line, "\r")
+			}
+			return stringArray(lines)
+		},
+		},
+	},
+}
+
+// maxStringLength limits the strings built by repeat and padding
+const maxStringLength = 1 << 30
+
+// stringArgs checks that args are want strings and returns their values
+func stringArgs(name string, args []Object, want int) ([]string, *Error) {
+	if len(args) != want {
+		return nil, newError("wrong number of arguments. got=%d, want=%d",
+			len(args), want)
+	}
+
+	values := make([]string, len(args))
+	for i, arg := range args {
+		s, ok := arg.(*String)
+		if !ok {
+			return nil, newError("argument %d to `%s` must be STRING, got %s",
+				i+1, name, arg.Type())
+		}
+		values[i] = s.Value
+	}
+	return values, nil
+}
+
+func stringArray(values []string) *Array {
+	elements := make([]Object, len(values))
+	for i, v := range values {
+		elements[i] = &String{Value: v}
+	}
+	return &Array{Elements: elements}
+}
+
+// trimBuiltin trims white space, or the characters in an optional
+// second argument
+func trimBuiltin(
+	name string,
+	space func(string) string,
+	cutset func(string, string) string,
+) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) == 2 {
+			s, err := stringArgs(name, args, 2)
+			if err != nil {
+				return err
+			}
+			return &String{Value: cutset(s[0], s[1])}
+		}
+
+		s, err := stringArgs(name, args, 1)
+		if err != nil {
+			return err
+		}
+		return &String{Value: space(s[0])}
+	},
+	}
+}
+
+// padBuiltin pads a string to a width in code points with spaces, or
+// the character in an optional third argument
+func padBuiltin(name string, left bool) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("wrong number of arguments. got=%d, want=2 or 3",
+				len(args))
+		}
+		s, ok := args[0].(*String)
+		if !ok {
+			return newError("argument 1 to `%s` must be STRING, got %s",
+				name, args[0].Type())
+		}
+		width, ok := args[1].(*Integer)
+		if !ok {
+			return newError("argument 2 to `%s` must be INTEGER, got %s",
+				name, args[1].Type())
+		}
+
+		pad := " "
+		if len(args) == 3 {
+			switch arg := args[2].(type) {
+			case *Char:
+				pad = string(arg.Value)
+			case *String:
+				pad = arg.Value
+			}
+			if utf8.RuneCountInString(pad) != 1 {
+				return newError("argument 3 to `%s` must be a single character, got %s",
+					name, args[2].Inspect())
+			}
+		}
+
+		n := width.Value - int64(utf8.RuneCountInString(s.Value))
+		if n <= 0 {
+			return s
+		}
+		if n > maxStringLength/int64(len(pad)) {
+			return newError("result of `%s` is too long", name)
+		}
+
+		padding := strings.Repeat(pad, int(n))
+		if left {
+			return &String{Value: padding + s.Value}
+		}
+		return &String{Value: s.Value + padding}
+	},
+	}
+}
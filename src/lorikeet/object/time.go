@@ -0,0 +1,429 @@
+package object
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Clock tells the time for now, since and sleep
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the clock of the machine running the program
+type SystemClock struct{}
+
+// Now returns the current local time
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// Sleep pauses the program for d
+func (SystemClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// FakeClock is a clock that only moves when slept or advanced, hosts set
+// it as CurrentClock so programs that use the time run the same each time
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a fake clock stopped at start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the time the fake clock is stopped at
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Sleep advances the fake clock by d without waiting
+func (c *FakeClock) Sleep(d time.Duration) { c.Advance(d) }
+
+// Advance moves the fake clock forward by d
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// CurrentClock is the clock used by now, since and sleep
+var CurrentClock Clock = SystemClock{}
+
+// Time object holds an instant, made by now and time.parse
+type Time struct {
+	Value time.Time
+}
+
+// Type will return the time type "TIME"
+func (t *Time) Type() Type { return TIME }
+
+// Inspect will return the time in RFC 3339 format
+func (t *Time) Inspect() string { return t.Value.Format(time.RFC3339Nano) }
+
+// Duration object holds the time between two instants
+type Duration struct {
+	Value time.Duration
+}
+
+// Type will return the duration type "DURATION"
+func (d *Duration) Type() Type { return DURATION }
+
+// Inspect will return the duration like 1h2m3.5s
+func (d *Duration) Inspect() string { return d.Value.String() }
+
+// ErrDivisionByZero is returned by TimeOperation for a duration divided
+// by zero
+var ErrDivisionByZero = errors.New("division by zero")
+
+var errDurationOverflow = errors.New("duration overflow")
+
+// IsTimeOperand reports whether obj is a TIME or DURATION, the operands
+// handled by TimeOperation
+func IsTimeOperand(obj Object) bool {
+	switch obj.(type) {
+	case *Time, *Duration:
+		return true
+	}
+	return false
+}
+
+// TimeOperation applies an arithmetic operator to times and durations:
+//
+//	TIME + DURATION, DURATION + TIME and TIME - DURATION give a TIME
+//	TIME - TIME gives the DURATION between them
+//	DURATION + DURATION and DURATION - DURATION give a DURATION
+//	DURATION * or / an INTEGER or FLOAT scales the DURATION
+//	DURATION / DURATION gives their ratio as a FLOAT
+//
+// The result is nil when the operator does not take those types.
+func TimeOperation(operator string, left, right Object) (Object, error) {
+	switch l := left.(type) {
+	case *Time:
+		switch r := right.(type) {
+		case *Duration:
+			switch operator {
+			case "+":
+				return &Time{Value: l.Value.Add(r.Value)}, nil
+			case "-":
+				if r.Value == math.MinInt64 {
+					return nil, errDurationOverflow
+				}
+				return &Time{Value: l.Value.Add(-r.Value)}, nil
+			}
+		case *Time:
+			if operator == "-" {
+				return &Duration{Value: l.Value.Sub(r.Value)}, nil
+			}
+		}
+	case *Duration:
+		switch r := right.(type) {
+		case *Time:
+			if operator == "+" {
+				return &Time{Value: r.Value.Add(l.Value)}, nil
+			}
+		case *Duration:
+			switch operator {
+			case "+":
+				sum := l.Value + r.Value
+				if (sum > l.Value) != (r.Value > 0) {
+					return nil, errDurationOverflow
+				}
+				return &Duration{Value: sum}, nil
+			case "-":
+				diff := l.Value - r.Value
+				if (diff < l.Value) != (r.Value > 0) {
+					return nil, errDurationOverflow
+				}
+				return &Duration{Value: diff}, nil
+			case "/":
+				if r.Value == 0 {
+					return nil, ErrDivisionByZero
+				}
+				return &Float{Value: float64(l.Value) / float64(r.Value)}, nil
+			}
+		case *Integer, *BigInt, *Float:
+			switch operator {
+			case "*":
+				return scaleDuration(l.Value, number(r), false)
+			case "/":
+				return scaleDuration(l.Value, number(r), true)
+			}
+		}
+	case *Integer, *BigInt, *Float:
+		if r, ok := right.(*Duration); ok && operator == "*" {
+			return scaleDuration(r.Value, number(l), false)
+		}
+	}
+	return nil, nil
+}
+
+// number returns an INTEGER, BIGINT or FLOAT as a float64
+func number(obj Object) float64 {
+	f, _ := floatArg("", []Object{obj}, 0)
+	return f
+}
+
+// scaleDuration multiplies or divides d by n
+func scaleDuration(d time.Duration, n float64, divide bool) (Object, error) {
+	var scaled float64
+	if divide {
+		if n == 0 {
+			return nil, ErrDivisionByZero
+		}
+		scaled = float64(d) / n
+	} else {
+		scaled = float64(d) * n
+	}
+	if math.IsNaN(scaled) || scaled < math.MinInt64 || scaled >= math.MaxInt64 {
+		return nil, errDurationOverflow
+	}
+	return &Duration{Value: time.Duration(scaled)}, nil
+}
+
+// durationArg returns argument i of a builtin as a duration, an INTEGER
+// or FLOAT counts units
+func durationArg(name string, args []Object, i int, unit time.Duration) (time.Duration, *Error) {
+	if d, ok := args[i].(*Duration); ok {
+		return d.Value, nil
+	}
+	switch args[i].(type) {
+	case *Integer, *BigInt, *Float:
+		d, err := scaleDuration(unit, number(args[i]), false)
+		if err != nil {
+			return 0, newError("argument %d to `%s` is too large: %s",
+				i+1, name, args[i].Inspect())
+		}
+		return d.(*Duration).Value, nil
+	}
+	return 0, newError("argument %d to `%s` must be DURATION, INTEGER or FLOAT, got %s",
+		i+1, name, args[i].Type())
+}
+
+// timeArg returns argument i of a builtin as a time
+func timeArg(name string, args []Object, i int) (time.Time, *Error) {
+	if t, ok := args[i].(*Time); ok {
+		return t.Value, nil
+	}
+	return time.Time{}, newError("argument %d to `%s` must be TIME, got %s",
+		i+1, name, args[i].Type())
+}
+
+// layoutArg returns the optional layout argument i of time.format and
+// time.parse, it is "" when left out
+func layoutArg(name string, args []Object, i int) (string, *Error) {
+	if len(args) <= i {
+		return "", nil
+	}
+	layout, ok := args[i].(*String)
+	if !ok {
+		return "", newError("argument %d to `%s` must be STRING, got %s",
+			i+1, name, args[i].Type())
+	}
+	return layout.Value, nil
+}
+
+// timeDirectives are the Go layouts of the % directives of time.format
+// and time.parse
+var timeDirectives = map[byte]string{
+	'Y': "2006",
+	'y': "06",
+	'm': "01",
+	'd': "02",
+	'e': "_2",
+	'j': "002",
+	'H': "15",
+	'I': "03",
+	'M': "04",
+	'S': "05",
+	'L': ".000",
+	'p': "PM",
+	'b': "Jan",
+	'B': "January",
+	'a': "Mon",
+	'A': "Monday",
+	'z': "-0700",
+	'Z': "MST",
+}
+
+// formatTime formats t by a layout of % directives, each directive is
+// formatted on its own so the text around them is written as it is
+func formatTime(t time.Time, layout string) (string, *Error) {
+	var out strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			out.WriteByte(layout[i])
+			continue
+		}
+		if i+1 == len(layout) {
+			return "", newError("layout of `time.format` ends in an incomplete directive: %%")
+		}
+		i++
+		if layout[i] == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		goLayout, ok := timeDirectives[layout[i]]
+		if !ok {
+			return "", newError("unknown directive %%%c in `time.format`", layout[i])
+		}
+		text := t.Format(goLayout)
+		if layout[i] == 'L' {
+			text = text[1:]
+		}
+		out.WriteString(text)
+	}
+	return out.String(), nil
+}
+
+// parseTime parses s by a layout of % directives, times without a zone
+// are in UTC
+func parseTime(s, layout string) (time.Time, *Error) {
+	var goLayout strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' {
+			goLayout.WriteByte(layout[i])
+			continue
+		}
+		if i+1 == len(layout) {
+			return time.Time{}, newError("layout of `time.parse` ends in an incomplete directive: %%")
+		}
+		i++
+		switch layout[i] {
+		case '%':
+			goLayout.WriteByte('%')
+		case 'L':
+			if !strings.HasSuffix(goLayout.String(), ".") {
+				return time.Time{}, newError("%%L in `time.parse` must follow a .")
+			}
+			goLayout.WriteString("000")
+		default:
+			directive, ok := timeDirectives[layout[i]]
+			if !ok {
+				return time.Time{}, newError("unknown directive %%%c in `time.parse`", layout[i])
+			}
+			goLayout.WriteString(directive)
+		}
+	}
+
+	t, err := time.Parse(goLayout.String(), s)
+	if err != nil {
+		return time.Time{}, newError("cannot parse %q as %q", s, layout)
+	}
+	return t, nil
+}
+
+// unitFunction returns a builtin making a duration of a number of units
+func unitFunction(name string, unit time.Duration) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if err := arity(args, 1); err != nil {
+			return err
+		}
+		if _, ok := args[0].(*Duration); ok {
+			return numberError(name, args, 0)
+		}
+
+		d, err := durationArg(name, args, 0, unit)
+		if err != nil {
+			return err
+		}
+		return &Duration{Value: d}
+	},
+	}
+}
+
+// TimeModule of functions on times and durations, now, since and sleep
+// are builtins
+var TimeModule = &Module{
+	Name: "time",
+	Members: map[string]Object{
+		"ms":      unitFunction("time.ms", time.Millisecond),
+		"seconds": unitFunction("time.seconds", time.Second),
+		"minutes": unitFunction("time.minutes", time.Minute),
+		"hours":   unitFunction("time.hours", time.Hour),
+		"format": &Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			t, err := timeArg("time.format", args, 0)
+			if err != nil {
+				return err
+			}
+			layout, err := layoutArg("time.format", args, 1)
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 1 {
+				return &String{Value: t.Format(time.RFC3339)}
+			}
+			s, err := formatTime(t, layout)
+			if err != nil {
+				return err
+			}
+			return &String{Value: s}
+		},
+		},
+		"parse": &Builtin{Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2",
+					len(args))
+			}
+			s, ok := args[0].(*String)
+			if !ok {
+				return newError("argument 1 to `time.parse` must be STRING, got %s",
+					args[0].Type())
+			}
+			text := s.Value
+			layout, err := layoutArg("time.parse", args, 1)
+			if err != nil {
+				return err
+			}
+
+			if len(args) == 1 {
+				t, perr := time.Parse(time.RFC3339, text)
+				if perr != nil {
+					return newError("cannot parse %q as RFC 3339", text)
+				}
+				return &Time{Value: t}
+			}
+			t, err := parseTime(text, layout)
+			if err != nil {
+				return err
+			}
+			return &Time{Value: t}
+		},
+		},
+		"unix": &Builtin{Fn: func(args ...Object) Object {
+			if err := arity(args, 1); err != nil {
+				return err
+			}
+			t, err := timeArg("time.unix", args, 0)
+			if err != nil {
+				return err
+			}
+
+			return &Integer{Value: t.Unix()}
+		},
+		},
+		"from_unix": &Builtin{Fn: func(args ...Object) Object {
+			if err := arity(args, 1); err != nil {
+				return err
+			}
+			seconds, ok := args[0].(*Integer)
+			if !ok {
+				return newError("argument 1 to `time.from_unix` must be INTEGER, got %s",
+					args[0].Type())
+			}
+
+			return &Time{Value: time.Unix(seconds.Value, 0).UTC()}
+		},
+		},
+	},
+}
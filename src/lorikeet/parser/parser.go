@@ -1,39 +1,57 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"lorikeet/ast"
 	"lorikeet/lexer"
 	"lorikeet/token"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 // Precedences
 const (
 	_ int = iota
 	LOWEST
+	COALESCE    // ??
 	EQUALS      // ==
-	LESSGREATER // > or <
+	LESSGREATER // > or < or in
+	UNION       // |
+	INTERSECT   // &
+	RANGE       // .. or ..=
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	PIPE        // |>
+	COMPOSE     // >>
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 )
 
 var precedences = map[token.Type]int{
-	token.EQ:       EQUALS,
-	token.NOTEQ:    EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.PIPE:     PIPE,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.COALESCE:  COALESCE,
+	token.EQ:        EQUALS,
+	token.NOTEQ:     EQUALS,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.IN:        LESSGREATER,
+	token.BAR:       UNION,
+	token.AMPERSAND: INTERSECT,
+	token.RANGE:     RANGE,
+	token.RANGEINCL: RANGE,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.SLASH:     PRODUCT,
+	token.ASTERISK:  PRODUCT,
+	token.PIPE:      PIPE,
+	token.COMPOSE:   COMPOSE,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
+	token.OPTINDEX:  INDEX,
+	token.OPTDOT:    INDEX,
+	token.DOT:       INDEX,
 }
 
 type (
@@ -51,6 +69,10 @@ type Parser struct {
 
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
+
+	// argument is set while the next expression is a call argument,
+	// the only place a _ placeholder may appear
+	argument bool
 }
 
 // New creates instance of *Parser
@@ -72,10 +94,14 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.CHAR, p.parseCharLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.SETOPEN, p.parseSetLiteral)
 	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.MONEY, p.parsePrefixExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.YIELD, p.parseYieldExpression)
 
 	p.infixParseFns = make(map[token.Type]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -86,12 +112,22 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.NOTEQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.RANGE, p.parseInfixExpression)
+	p.registerInfix(token.RANGEINCL, p.parseInfixExpression)
+	p.registerInfix(token.IN, p.parseInfixExpression)
+	p.registerInfix(token.BAR, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
 
 	p.registerInfix(token.PIPE, p.parsePipeExpression)
+	p.registerInfix(token.COMPOSE, p.parseComposeExpression)
 
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.OPTINDEX, p.parseIndexExpression)
+	p.registerInfix(token.OPTDOT, p.parseFieldExpression)
+	p.registerInfix(token.DOT, p.parseFieldExpression)
+	p.registerInfix(token.COALESCE, p.parseInfixExpression)
 
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
@@ -157,10 +193,12 @@ func (p *Parser) ParseProgram() *ast.Program {
 
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
-	case token.LET:
+	case token.LET, token.CONST:
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.ENUM:
+		return p.parseEnumStatement()
 	case token.FUNCTION:
 		if p.isFunctionLiteral() {
 			return p.parseExpressionStatement()
@@ -176,20 +214,36 @@ func (p *Parser) parseStatement() ast.Statement {
 	}
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
+func (p *Parser) parseLetStatement() ast.Statement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	stmt.Mut = p.peekTokenIs(token.MUTATE)
+	// const values are frozen, so they can be neither mut nor destructured
+	isConst := p.curTokenIs(token.CONST)
+
+	stmt.Mut = !isConst && p.peekTokenIs(token.MUTATE)
 	if stmt.Mut {
 		p.nextToken()
 	}
 
+	if !isConst && p.peekTokenIs(token.LPAREN) {
+		return p.parseDestructureStatement(stmt.Token, stmt.Mut)
+	}
+
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		p.nextToken()
+		stmt.Type = p.parseTypeAnnotation()
+		if stmt.Type == nil {
+			return nil
+		}
+	}
+
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
@@ -209,8 +263,46 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+func (p *Parser) parseDestructureStatement(tok token.Token, mut bool) ast.Statement {
+	stmt := &ast.DestructureStatement{Token: tok, Mut: mut}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Names = append(stmt.Names,
+			&ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseFunctionStatement() *ast.LetStatement {
-	lit := &ast.FunctionLiteral{Token: p.curToken}
+	lit := &ast.FunctionLiteral{Token: p.curToken, Generator: p.isGenerator()}
 
 	stmt := &ast.LetStatement{Token: p.curToken}
 
@@ -225,7 +317,9 @@ func (p *Parser) parseFunctionStatement() *ast.LetStatement {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionSignature(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -238,6 +332,54 @@ func (p *Parser) parseFunctionStatement() *ast.LetStatement {
 	return stmt
 }
 
+func (p *Parser) parseEnumStatement() *ast.EnumStatement {
+	stmt := &ast.EnumStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+
+		variant := &ast.EnumVariant{
+			Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		}
+
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			variant.Fields = p.parseFunctionParameters()
+		}
+
+		stmt.Variants = append(stmt.Variants, variant)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	if len(stmt.Variants) == 0 {
+		msg := fmt.Sprintf("enum %s must have at least one variant; line=%d",
+			stmt.Name.Value, stmt.Token.Line)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseMutStatement() *ast.MutStatement {
 	stmt := &ast.MutStatement{}
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
@@ -261,6 +403,27 @@ func (p *Parser) parseMutStatement() *ast.MutStatement {
 	return stmt
 }
 
+func (p *Parser) parseIndexAssignStatement(target *ast.IndexExpression) ast.Statement {
+	p.nextToken()
+	stmt := &ast.IndexAssignStatement{Token: p.curToken, Target: target}
+
+	if target.Optional {
+		msg := fmt.Sprintf("cannot assign to %s; line=%d", target.String(), stmt.Token.Line)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
@@ -285,11 +448,15 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if index, ok := stmt.Expression.(*ast.IndexExpression); ok && p.peekTokenIs(token.ASSIGN) {
+		return p.parseIndexAssignStatement(index)
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -298,6 +465,16 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
+	argument := p.argument
+	p.argument = false
+
+	if p.curTokenIs(token.IDENT) && p.curToken.Literal == "_" &&
+		(!argument || !(p.peekTokenIs(token.COMMA) || p.peekTokenIs(token.RPAREN))) {
+		msg := fmt.Sprintf("_ placeholder can only be used as a call argument; line=%d",
+			p.curToken.Line)
+		p.errors = append(p.errors, msg)
+	}
+
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
@@ -343,6 +520,12 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if n, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			lit.Big = n
+			return lit
+		}
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer; line=%d",
 			p.curToken.Literal, p.curToken.Line)
@@ -399,18 +582,79 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 }
 
 func (p *Parser) parsePipeExpression(exp ast.Expression) ast.Expression {
+	tok := p.curToken
 	precedence := p.curPrecedence()
 	p.nextToken()
+	errors := len(p.errors)
 	right := p.parseExpression(precedence)
+	if len(p.errors) > errors {
+		return nil
+	}
+
+	switch right := right.(type) {
+	case nil:
+		return nil
+	case *ast.PartialExpression:
+		// The piped value takes the place of the placeholder
+		if right.Placeholders() != 1 {
+			msg := fmt.Sprintf("cannot pipe into %s, it must have exactly one _ placeholder; line=%d",
+				right.String(), tok.Line)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+		for i, a := range right.Call.Arguments {
+			if ast.IsPlaceholder(a) {
+				right.Call.Arguments[i] = exp
+			}
+		}
+		return right.Call
+	case *ast.CallExpression:
+		right.Arguments = append(right.Arguments, exp)
+		return right
+	}
+
+	if !p.isFunctionValue(right) {
+		msg := fmt.Sprintf("cannot pipe into %s; line=%d", right.String(), tok.Line)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return &ast.CallExpression{Token: tok, Function: right, Arguments: []ast.Expression{exp}}
+}
+
+func (p *Parser) parseComposeExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	exp := p.parseInfixExpression(left)
 
-	cl, ok := right.(*ast.CallExpression)
-	if !ok {
+	// After a parse error either side may have missing children, which
+	// cannot be printed, and the program will not run anyway
+	infix, ok := exp.(*ast.InfixExpression)
+	if !ok || infix.Right == nil || len(p.errors) > 0 {
 		return nil
 	}
+	for _, side := range []ast.Expression{infix.Left, infix.Right} {
+		if !p.isFunctionValue(side) {
+			msg := fmt.Sprintf("cannot compose %s, it is not a function; line=%d",
+				side.String(), tok.Line)
+			p.errors = append(p.errors, msg)
+			return nil
+		}
+	}
 
-	cl.Arguments = append(cl.Arguments, exp)
+	return infix
+}
 
-	return cl
+// isFunctionValue reports whether the expression may evaluate to a
+// function, literals and arithmetic never do
+func (p *Parser) isFunctionValue(exp ast.Expression) bool {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.Boolean,
+		*ast.ArrayLiteral, *ast.HashLiteral, *ast.PrefixExpression, *ast.SliceExpression:
+		return false
+	case *ast.InfixExpression:
+		return exp.Operator == ">>" || exp.Operator == "??"
+	}
+	return true
 }
 
 func (p *Parser) parseBoolean() ast.Expression {
@@ -418,15 +662,40 @@ func (p *Parser) parseBoolean() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	tuple := &ast.TupleLiteral{Token: p.curToken, Elements: []ast.Expression{}}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return tuple
+	}
+
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
 
+	if !p.peekTokenIs(token.COMMA) {
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		return exp
+	}
+
+	// a comma makes this a tuple, (x,) is a tuple of one
+	tuple.Elements = append(tuple.Elements, exp)
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(token.RPAREN) {
+			break
+		}
+		p.nextToken()
+		tuple.Elements = append(tuple.Elements, p.parseExpression(LOWEST))
+	}
+
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 
-	return exp
+	return tuple
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
@@ -462,6 +731,78 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+
+		if p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+		}
+	}
+
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{Token: p.curToken}
+
+	switch {
+	case p.curTokenIs(token.IDENT) && p.curToken.Literal == "_":
+	case p.curTokenIs(token.IDENT):
+		arm.Variant = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			arm.Bindings = p.parseFunctionParameters()
+		}
+	default:
+		arm.Value = p.parseExpression(LOWEST)
+	}
+
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		arm.Body = p.parseBlockStatement()
+		return arm
+	}
+
+	p.nextToken()
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+	stmt.Expression = p.parseExpression(LOWEST)
+	arm.Body = &ast.BlockStatement{Token: stmt.Token, Statements: []ast.Statement{stmt}}
+
+	return arm
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -480,7 +821,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
-	lit := &ast.FunctionLiteral{Token: p.curToken}
+	lit := &ast.FunctionLiteral{Token: p.curToken, Generator: p.isGenerator()}
 
 	if p.peekTokenIs(token.IDENT) {
 		p.nextToken()
@@ -494,7 +835,9 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionSignature(lit) {
+		return nil
+	}
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -505,6 +848,132 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	return lit
 }
 
+// parseFunctionSignature parses parameters with optional type annotations
+// and an optional -> return type
+func (p *Parser) parseFunctionSignature(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	lit.ParameterTypes = []*ast.TypeAnnotation{}
+
+	for !p.peekTokenIs(token.RPAREN) {
+		if len(lit.Parameters) > 0 && !p.expectPeek(token.COMMA) {
+			return false
+		}
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		var typ *ast.TypeAnnotation
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			typ = p.parseTypeAnnotation()
+			if typ == nil {
+				return false
+			}
+		}
+
+		lit.Parameters = append(lit.Parameters, ident)
+		lit.ParameterTypes = append(lit.ParameterTypes, typ)
+	}
+	p.nextToken()
+
+	if p.peekTokenIs(token.RARROW) {
+		p.nextToken()
+		p.nextToken()
+		lit.ReturnType = p.parseTypeAnnotation()
+		if lit.ReturnType == nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseTypeAnnotation parses int, [T], {K: V} and fn(T, ...) -> R types
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	typ := &ast.TypeAnnotation{Token: p.curToken}
+
+	switch p.curToken.Type {
+	case token.IDENT:
+		typ.Name = p.curToken.Literal
+
+	case token.LBRACKET:
+		typ.Name = "[]"
+		p.nextToken()
+		elem := p.parseTypeAnnotation()
+		if elem == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		typ.Elements = []*ast.TypeAnnotation{elem}
+
+	case token.LBRACE:
+		typ.Name = "{}"
+		p.nextToken()
+		key := p.parseTypeAnnotation()
+		if key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseTypeAnnotation()
+		if value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		typ.Elements = []*ast.TypeAnnotation{key, value}
+
+	case token.FUNCTION:
+		typ.Name = "fn"
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		for !p.peekTokenIs(token.RPAREN) {
+			if len(typ.Elements) > 0 && !p.expectPeek(token.COMMA) {
+				return nil
+			}
+			p.nextToken()
+			param := p.parseTypeAnnotation()
+			if param == nil {
+				return nil
+			}
+			typ.Elements = append(typ.Elements, param)
+		}
+		p.nextToken()
+		if p.peekTokenIs(token.RARROW) {
+			p.nextToken()
+			p.nextToken()
+			typ.Return = p.parseTypeAnnotation()
+			if typ.Return == nil {
+				return nil
+			}
+		}
+
+	default:
+		msg := fmt.Sprintf("expected a type, got %s instead; line=%d",
+			p.curToken.Literal, p.curToken.Line)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return typ
+}
+
+func (p *Parser) isGenerator() bool {
+	return p.curTokenIs(token.FUNCTION) && p.curToken.Literal == "fn*"
+}
+
+func (p *Parser) parseYieldExpression() ast.Expression {
+	expression := &ast.YieldExpression{Token: p.curToken}
+
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) {
+		return expression
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+
+	return expression
+}
+
 func (p *Parser) isFunctionLiteral() bool {
 	return !p.peekTokenIs(token.IDENT)
 }
@@ -543,6 +1012,13 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseCallArguments()
+
+	for _, a := range exp.Arguments {
+		if ast.IsPlaceholder(a) {
+			return &ast.PartialExpression{Token: exp.Token, Call: exp}
+		}
+	}
+
 	return exp
 }
 
@@ -555,11 +1031,13 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 	}
 
 	p.nextToken()
+	p.argument = true
 	args = append(args, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
+		p.argument = true
 		args = append(args, p.parseExpression(LOWEST))
 	}
 
@@ -571,7 +1049,51 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 }
 
 func (p *Parser) parseStringLiteral() ast.Expression {
-	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	value, err := unquoteString(p.curToken.Literal)
+	if err != nil {
+		msg := fmt.Sprintf("could not parse \"%s\" as string; line=%d",
+			p.curToken.Literal, p.curToken.Line)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return &ast.StringLiteral{Token: p.curToken, Value: value}
+}
+
+// unquoteString replaces the escape sequences of a string literal with
+// the characters they stand for, strings may span lines so a new line
+// stands for itself
+func unquoteString(literal string) (string, error) {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(literal); i++ {
+		switch literal[i] {
+		case '\\':
+			quoted.WriteByte(literal[i])
+			if i+1 < len(literal) {
+				i++
+				quoted.WriteByte(literal[i])
+			}
+		case '\n':
+			quoted.WriteString(`\n`)
+		default:
+			quoted.WriteByte(literal[i])
+		}
+	}
+	quoted.WriteByte('"')
+	return strconv.Unquote(quoted.String())
+}
+
+func (p *Parser) parseCharLiteral() ast.Expression {
+	value, err := strconv.Unquote("'" + p.curToken.Literal + "'")
+	if err != nil || value == "" {
+		msg := fmt.Sprintf("could not parse '%s' as character; line=%d",
+			p.curToken.Literal, p.curToken.Line)
+		p.errors = append(p.errors, msg)
+		return nil
+	}
+
+	return &ast.CharLiteral{Token: p.curToken, Value: []rune(value)[0]}
 }
 
 func (p *Parser) registerPrefix(Type token.Type, fn prefixParseFn) {
@@ -590,6 +1112,14 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	return array
 }
 
+func (p *Parser) parseSetLiteral() ast.Expression {
+	set := &ast.SetLiteral{Token: p.curToken}
+
+	set.Elements = p.parseExpressionList(token.RBRACE)
+
+	return set
+}
+
 func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -615,10 +1145,48 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
 
-	p.nextToken()
-	exp.Index = p.parseExpression(LOWEST)
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		index = p.parseExpression(LOWEST)
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		return p.parseSliceExpression(tok, left, index)
+	}
+
+	exp := &ast.IndexExpression{
+		Token:    tok,
+		Left:     left,
+		Index:    index,
+		Optional: tok.Type == token.OPTINDEX,
+	}
+
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseSliceExpression(
+	tok token.Token,
+	left, start ast.Expression,
+) ast.Expression {
+	exp := &ast.SliceExpression{
+		Token:    tok,
+		Left:     left,
+		Start:    start,
+		Optional: tok.Type == token.OPTINDEX,
+	}
+
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.End = p.parseExpression(LOWEST)
+	}
 
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
@@ -627,6 +1195,23 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseFieldExpression parses a.b and a?.b as a["b"] and a?["b"]
+func (p *Parser) parseFieldExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left, Optional: p.curTokenIs(token.OPTDOT)}
+
+	// keywords can be member names too, like regex.match
+	isKeyword := token.LookupIdent(p.peekToken.Literal) == p.peekToken.Type
+	if !p.peekTokenIs(token.IDENT) && !isKeyword {
+		p.peekError(token.IDENT)
+		return nil
+	}
+	p.nextToken()
+
+	exp.Index = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
 func (p *Parser) parseHashLiteral() ast.Expression {
 	hash := &ast.HashLiteral{Token: p.curToken}
 	hash.Pairs = make(map[ast.Expression]ast.Expression)
@@ -643,6 +1228,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
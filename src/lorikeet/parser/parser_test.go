@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"lorikeet/ast"
 	"lorikeet/lexer"
+	"strings"
 	"testing"
 )
 
@@ -173,6 +174,24 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestBigIntegerLiteralExpression(t *testing.T) {
+	input := "99999999999999999999;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Big == nil || literal.Big.String() != "99999999999999999999" {
+		t.Errorf("literal.Big not %s. got=%s", "99999999999999999999", literal.Big)
+	}
+}
+
 func TestFloatLiteralExpression(t *testing.T) {
 	input := "5.1;"
 
@@ -450,6 +469,46 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a *b[2], b[1], 2 * [1, 2][1]);",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a + 1..b * 2;",
+			"((a + 1) .. (b * 2))",
+		},
+		{
+			"0..=n == r;",
+			"((0 ..= n) == r)",
+		},
+		{
+			"a[1:b + 1][-2:];",
+			"((a[1:(b + 1)])[(-2):])",
+		},
+		{
+			"a[:];",
+			"(a[:])",
+		},
+		{
+			"a ?? b == c;",
+			"(a ?? (b == c))",
+		},
+		{
+			"a ?? b ?? c;",
+			"((a ?? b) ?? c)",
+		},
+		{
+			"a?.b?[1] + 1;",
+			"(((a?[b])?[1]) + 1)",
+		},
+		{
+			"a?[1:];",
+			"(a?[1:])",
+		},
+		{
+			"a.b.c + 1;",
+			"(((a[b])[c]) + 1)",
+		},
+		{
+			"strings.split(s, x)[0]",
+			"((strings[split])(s, x)[0])",
+		},
 	}
 
 	for _, tt := range tests {
@@ -657,6 +716,56 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestGeneratorFunctionParsing(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedName  string
+		expectedYield string
+	}{
+		{"fn* naturals(i) { yield i; }", "naturals", "(yield i)"},
+		{"let g = fn*() { yield; }", "g", "(yield)"},
+		{"fn* g() { let x = yield 1 + 2; }", "g", "(yield (1 + 2))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.LetStatement. got=%T",
+				program.Statements[0])
+		}
+
+		function, ok := stmt.Value.(*ast.FunctionLiteral)
+		if !ok {
+			t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+		}
+		if !function.Generator {
+			t.Errorf("function.Generator is not true")
+		}
+		if function.Name != tt.expectedName {
+			t.Errorf("function.Name is not %q. got=%q", tt.expectedName, function.Name)
+		}
+
+		var yield ast.Expression
+		switch bodyStmt := function.Body.Statements[0].(type) {
+		case *ast.ExpressionStatement:
+			yield = bodyStmt.Expression
+		case *ast.LetStatement:
+			yield = bodyStmt.Value
+		}
+		if _, ok := yield.(*ast.YieldExpression); !ok {
+			t.Fatalf("body expression is not ast.YieldExpression. got=%T", yield)
+		}
+		if yield.String() != tt.expectedYield {
+			t.Errorf("yield.String() is not %q. got=%q", tt.expectedYield, yield.String())
+		}
+	}
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -756,6 +865,16 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 			expectedIdent: "add",
 			expectedArgs:  []string{"1", "add(10, 2)"},
 		},
+		{
+			input:         "[1] |> push(_, 4);",
+			expectedIdent: "push",
+			expectedArgs:  []string{"[1]", "4"},
+		},
+		{
+			input:         "10 |> add;",
+			expectedIdent: "add",
+			expectedArgs:  []string{"10"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -789,6 +908,254 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestPartialAndComposeExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1, _)", "add(1, _)"},
+		{"add(_, f(_))", "add(_, f(_))"},
+		{"f >> g", "(f >> g)"},
+		{"f >> g >> h", "((f >> g) >> h)"},
+		{"x |> f >> g", "(f >> g)(x)"},
+		{"x |> add(1, _) >> g", "(add(1, _) >> g)(x)"},
+		{"f >> g(1)", "(f >> g(1))"},
+		{"1 + 2 |> f", "(1 + f(2))"},
+		{"1..10 |> f", "(1 .. f(10))"},
+		{"(1..10) |> f", "f((1 .. 10))"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	l := lexer.New("add(1, _, 2, _)")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	partial, ok := stmt.Expression.(*ast.PartialExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.PartialExpression. got=%T", stmt.Expression)
+	}
+	if partial.Placeholders() != 2 {
+		t.Errorf("partial.Placeholders() wrong. want=2, got=%d", partial.Placeholders())
+	}
+}
+
+func TestPlaceholderErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = _;", "_ placeholder can only be used as a call argument; line=1"},
+		{"f(_ + 1)", "_ placeholder can only be used as a call argument; line=1"},
+		{"f(-_)", "_ placeholder can only be used as a call argument; line=1"},
+		{"[_]", "_ placeholder can only be used as a call argument; line=1"},
+		{"1 |> 2", "cannot pipe into 2; line=1"},
+		{"1 |> f(_, _)", "cannot pipe into f(_, _), it must have exactly one _ placeholder; line=1"},
+		{"f >> 1", "cannot compose 1, it is not a function; line=1"},
+		{`"a" >> f`, "cannot compose a, it is not a function; line=1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser error for %q but got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong parser error. want=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestComposeAfterParseError(t *testing.T) {
+	tests := []string{"!> >> 0", ">0!> >>0", "[!] >> 1"}
+
+	for _, input := range tests {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		expected := "no prefix parse function for"
+		if len(errors) == 0 || !strings.HasPrefix(errors[0], expected) {
+			t.Errorf("wrong parser errors for %q. want=%q..., got=%q", input, expected, errors)
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: int = 1;", "let x: int = 1;"},
+		{"let mut x: float = 1.5;", "let x: float = 1.5;"},
+		{"let names: [string] = [];", "let names: [string] = [];"},
+		{"let ages: {string: int} = {};", "let ages: {string: int} = {};"},
+		{"let f: fn(int, int) -> int = add;", "let f: fn(int, int) -> int = add;"},
+		{"fn(a: int, b) -> int { a }", "fn(a: int, b) -> int a"},
+		{"fn add(a: int, b: int) -> int { a + b }", "fn add = fn<add>(a: int, b: int) -> int (a + b);"},
+		{"fn(a: [[int]]) { a }", "fn(a: [[int]]) a"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let x: = 1;", "expected a type, got = instead; line=1"},
+		{"fn(a: 1) { a }", "expected a type, got 1 instead; line=1"},
+		{"let x: {string} = {};", "expected next token to be :, got } instead; line=1"},
+	}
+
+	for _, tt := range errors {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %q but got none", tt.input)
+			continue
+		}
+		if p.Errors()[0] != tt.expected {
+			t.Errorf("wrong parser error. want=%q, got=%q", tt.expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestTuplesAndSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(1)", "1"},
+		{"()", "()"},
+		{"(1,)", "(1,)"},
+		{"(1, \"a\")", "(1, a)"},
+		{"(1, 2,)", "(1, 2)"},
+		{"((1, 2), 3)", "((1, 2), 3)"},
+		{"#{}", "#{}"},
+		{"#{1, 2 + 3}", "#{1, (2 + 3)}"},
+		{"a | b & c", "(a | (b & c))"},
+		{"a | b - c", "(a | (b - c))"},
+		{"x in a | b", "(x in (a | b))"},
+		{"x in s == true", "((x in s) == true)"},
+		{"let (a, b) = (1, 2);", "let (a, b) = (1, 2);"},
+		{"let mut (a) = t;", "let (a) = t;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"let () = t;", "expected next token to be IDENT, got ) instead; line=1"},
+		{"let (a, 1) = t;", "expected next token to be IDENT, got INT instead; line=1"},
+		{"(1, 2", "expected next token to be ), got EOF instead; line=1"},
+	}
+
+	for _, tt := range errors {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %q but got none", tt.input)
+			continue
+		}
+		if p.Errors()[0] != tt.expected {
+			t.Errorf("wrong parser error. want=%q, got=%q", tt.expected, p.Errors()[0])
+		}
+	}
+}
+
+func TestConstAndIndexAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"const x = [1];", "const x = [1];"},
+		{"const x: [int] = [1];", "const x: [int] = [1];"},
+		{"a[0] = 1;", "(a[0]) = 1;"},
+		{"a[i + 1] = b[i] * 2", "(a[(i + 1)]) = ((b[i]) * 2);"},
+		{`h["a"][0] = x;`, "((h[a])[0]) = x;"},
+		{"a[0] == 1", "((a[0]) == 1)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{"const mut x = 1;", "expected next token to be IDENT, got MUTATE instead; line=1"},
+		{"const (a, b) = t;", "expected next token to be IDENT, got ( instead; line=1"},
+		{"a?[0] = 1;", "cannot assign to (a?[0]); line=1"},
+	}
+
+	for _, tt := range errors {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 {
+			t.Errorf("expected parser error for %q but got none", tt.input)
+			continue
+		}
+		if p.Errors()[0] != tt.expected {
+			t.Errorf("wrong parser error. want=%q, got=%q", tt.expected, p.Errors()[0])
+		}
+	}
+}
+
 func TestFunctionLiteralWithName(t *testing.T) {
 	input := `let myFunction = fn() { };`
 
@@ -1024,21 +1391,91 @@ func checkParserErrors(t *testing.T, p *Parser) {
 }
 
 func TestStringLiteralExpression(t *testing.T) {
-	input := `"hello world";`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello world";`, "hello world"},
+		{`"a\nb"`, "a\nb"},
+		{`"\t\\\""`, "\t\\\""},
+		{`"\u00e9"`, "é"},
+		{`"\U0001F600"`, "😀"},
+		{"\"two\nlines\"", "two\nlines"},
+	}
 
-	l := lexer.New(input)
-	p := New(l)
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-	stmt := program.Statements[0].(*ast.ExpressionStatement)
-	literal, ok := stmt.Expression.(*ast.StringLiteral)
-	if !ok {
-		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %q. got=%q", tt.expected, literal.Value)
+		}
 	}
 
-	if literal.Value != "hello world" {
-		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{`"\q"`, `could not parse "\q" as string; line=1`},
+		{`"\u00"`, `could not parse "\u00" as string; line=1`},
+		{"say(1);\nsay(\"\\d\")", `could not parse "\d" as string; line=2`},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		if len(p.Errors()) == 0 || p.Errors()[0] != tt.expected {
+			t.Errorf("wrong parser errors for %s. want=%q, got=%q", tt.input, tt.expected, p.Errors())
+		}
+	}
+}
+
+func TestCharLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected rune
+	}{
+		{"'a'", 'a'},
+		{"'é'", 'é'},
+		{`'\n'`, '\n'},
+		{`'\''`, '\''},
+		{`'\u00e9'`, 'é'},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.CharLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.CharLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %q. got=%q", tt.expected, literal.Value)
+		}
+	}
+
+	for _, input := range []string{"''", "'ab'", `'\q'`} {
+		l := lexer.New(input)
+		p := New(l)
+		p.ParseProgram()
+
+		expected := "could not parse " + input + " as character; line=1"
+		if len(p.Errors()) == 0 || p.Errors()[0] != expected {
+			t.Errorf("wrong parser errors for %s. want=%q, got=%q", input, expected, p.Errors())
+		}
 	}
 }
 
@@ -1088,6 +1525,176 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingSliceExpressions(t *testing.T) {
+	input := "myArray[1:2 + 2]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	sliceExp, ok := stmt.Expression.(*ast.SliceExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.SliceExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, sliceExp.Left, "myArray") {
+		return
+	}
+
+	if !testIntegerLiteral(t, sliceExp.Start, 1) {
+		return
+	}
+
+	if !testInfixExpression(t, sliceExp.End, 2, "+", 2) {
+		return
+	}
+}
+
+func TestParsingFieldExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		optional bool
+		field    string
+	}{
+		{"config?.name", true, "name"},
+		{"config.name", false, "name"},
+		{"config.match", false, "match"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+		}
+
+		if indexExp.Optional != tt.optional {
+			t.Fatalf("indexExp.Optional is not %t", tt.optional)
+		}
+
+		if !testIdentifier(t, indexExp.Left, "config") {
+			return
+		}
+
+		field, ok := indexExp.Index.(*ast.StringLiteral)
+		if !ok || field.Value != tt.field {
+			t.Fatalf("indexExp.Index is not StringLiteral %q. got=%T (%+v)",
+				tt.field, indexExp.Index, indexExp.Index)
+		}
+	}
+}
+
+func TestEnumStatement(t *testing.T) {
+	input := "enum Shape { Circle(r), Rect(w, h), Empty }"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d",
+			len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.EnumStatement)
+	if !ok {
+		t.Fatalf("stmt not *ast.EnumStatement. got=%T", program.Statements[0])
+	}
+
+	if stmt.Name.Value != "Shape" {
+		t.Errorf("stmt.Name.Value not %q. got=%q", "Shape", stmt.Name.Value)
+	}
+
+	if stmt.String() != input {
+		t.Errorf("stmt.String() wrong. want=%q, got=%q", input, stmt.String())
+	}
+
+	expected := []struct {
+		name   string
+		fields int
+	}{
+		{"Circle", 1},
+		{"Rect", 2},
+		{"Empty", 0},
+	}
+
+	if len(stmt.Variants) != len(expected) {
+		t.Fatalf("stmt.Variants has wrong length. got=%d", len(stmt.Variants))
+	}
+
+	for i, v := range expected {
+		variant := stmt.Variants[i]
+		if variant.Name.Value != v.name {
+			t.Errorf("variant %d name not %q. got=%q", i, v.name, variant.Name.Value)
+		}
+		if len(variant.Fields) != v.fields {
+			t.Errorf("variant %d has wrong number of fields. want=%d, got=%d",
+				i, v.fields, len(variant.Fields))
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (shape) {
+		Circle(r) => r * r,
+		Rect(w, h) => { w * h }
+		1 => 1
+		_ => 0
+	}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MatchExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Subject, "shape") {
+		return
+	}
+
+	if len(exp.Arms) != 4 {
+		t.Fatalf("exp.Arms has wrong length. got=%d", len(exp.Arms))
+	}
+
+	expected := []string{
+		"Circle(r) => (r * r)",
+		"Rect(w, h) => (w * h)",
+		"1 => 1",
+		"_ => 0",
+	}
+
+	for i, arm := range exp.Arms {
+		if arm.String() != expected[i] {
+			t.Errorf("arm %d wrong. want=%q, got=%q", i, expected[i], arm.String())
+		}
+	}
+
+	if exp.Arms[0].Variant == nil || len(exp.Arms[0].Bindings) != 1 {
+		t.Errorf("arm 0 is not a variant pattern with 1 binding")
+	}
+
+	if !testIntegerLiteral(t, exp.Arms[2].Value, 1) {
+		return
+	}
+
+	if exp.Arms[3].Variant != nil || exp.Arms[3].Value != nil {
+		t.Errorf("arm 3 is not a wildcard pattern")
+	}
+}
+
 func TestParsingEmptyHashLiteral(t *testing.T) {
 	input := "{}"
 
@@ -9,7 +9,10 @@ import (
 	"lorikeet/lexer"
 	"lorikeet/object"
 	"lorikeet/parser"
+	"lorikeet/types"
 	"lorikeet/vm"
+	"os"
+	"time"
 )
 
 // PROMPT characters
@@ -27,6 +30,11 @@ func Start(in io.Reader, out io.Writer) {
 	for i, v := range object.Builtins {
 		symbolTable.DefineBuiltin(i, v.Name)
 	}
+	for i, m := range object.Modules {
+		symbolTable.DefineModule(i, m.Name)
+	}
+	checker := types.New()
+	random := object.NewRand(time.Now().UnixNano())
 
 	for {
 		fmt.Printf(PROMPT)
@@ -45,6 +53,15 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		if errors := checker.Check(program); len(errors) != 0 {
+			PrintTypeErrors(out, errors)
+			continue
+		}
+
+		for _, warning := range checker.Warnings() {
+			fmt.Fprintf(out, "Careful! %s\n", warning)
+		}
+
 		comp := compiler.NewWithState(symbolTable, constants)
 		err := comp.Compile(program)
 		if err != nil {
@@ -52,11 +69,20 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
+		for _, warning := range comp.Warnings() {
+			fmt.Fprintf(out, "Careful! %s\n", warning)
+		}
+
 		code := comp.Bytecode()
 		constants = code.Constants
 
 		machine := vm.NewWithGlobalsStore(code, globals)
+		machine.SetRand(random)
+		machine.SetKeepErrors(true)
 		err = machine.Run()
+		if exit, ok := err.(*object.Exit); ok {
+			os.Exit(exit.Code)
+		}
 		if err != nil {
 			fmt.Fprintf(out, "Darn! Executing bytecode failed:\n %s\n", err)
 			continue
@@ -86,6 +112,14 @@ func PrintParserErrors(out io.Writer, errors []string) {
 	}
 }
 
+// PrintTypeErrors print all type errors
+func PrintTypeErrors(out io.Writer, errors []string) {
+	io.WriteString(out, " type errors:\n")
+	for _, msg := range errors {
+		io.WriteString(out, "\t"+msg+"\n")
+	}
+}
+
 func scanLinesEscapable(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	if atEOF && len(data) == 0 {
 		return 0, nil, nil
@@ -0,0 +1,25 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDroppedErrorKeepsSession(t *testing.T) {
+	// The scanner takes every buffered line at once, so lines are read
+	// one byte at a time like they are typed
+	in := iotest.OneByteReader(strings.NewReader("let x = 2;\nlen(1)\nlen(1); x + 1\nx\n"))
+	var out bytes.Buffer
+	Start(in, &out)
+
+	warning := "Careful! argument to `len` not supported, got int; line=1\n"
+	expected := "2\n" +
+		warning + "Error: argument to `len` not supported, got INTEGER\n" +
+		warning + "3\n" +
+		"2\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
@@ -34,7 +34,23 @@ const (
 	EQ    = "=="
 	NOTEQ = "!="
 
-	PIPE = "|>"
+	PIPE    = "|>"
+	COMPOSE = ">>"
+
+	BAR       = "|"
+	AMPERSAND = "&"
+
+	ARROW  = "=>"
+	RARROW = "->"
+
+	RANGE     = ".."
+	RANGEINCL = "..="
+
+	COALESCE = "??"
+	OPTDOT   = "?."
+	OPTINDEX = "?["
+
+	DOT = "."
 
 	MONEY = "$"
 
@@ -49,12 +65,15 @@ const (
 	RBRACE   = "}"
 	LBRACKET = "["
 	RBRACKET = "]"
+	SETOPEN  = "#{"
 
 	STRING = "STRING"
+	CHAR   = "CHAR"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	MUTATE   = "MUTATE"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
@@ -62,11 +81,16 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	MACRO    = "MACRO"
+	ENUM     = "ENUM"
+	MATCH    = "MATCH"
+	YIELD    = "YIELD"
+	IN       = "IN"
 )
 
 var keywords = map[string]Type{
 	"fn":     FUNCTION,
 	"let":    LET,
+	"const":  CONST,
 	"mut":    MUTATE,
 	"true":   TRUE,
 	"false":  FALSE,
@@ -74,6 +98,10 @@ var keywords = map[string]Type{
 	"else":   ELSE,
 	"return": RETURN,
 	"macro":  MACRO,
+	"enum":   ENUM,
+	"match":  MATCH,
+	"yield":  YIELD,
+	"in":     IN,
 }
 
 // LookupIdent is used to check if Ident
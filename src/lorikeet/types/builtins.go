@@ -0,0 +1,423 @@
+package types
+
+import "fmt"
+
+// builtinChecks type the calls of builtin functions, builtins without an
+// entry accept anything and return any
+var builtinChecks = map[string]func(c *Checker, args []Type, line int) Type{
+	"len": func(c *Checker, args []Type, line int) Type {
+		if !c.arity("len", args, 1, line) {
+			return Int
+		}
+		switch prune(args[0]).(type) {
+		case *Array, *Hash, *Var:
+			return Int
+		}
+		if t := prune(args[0]); t != String && t != Char && t != Bytes && t != Range && t != Any {
+			c.warnf(line, "argument to `len` not supported, got %s", args[0])
+		}
+		return Int
+	},
+	"say": func(c *Checker, args []Type, line int) Type {
+		return Null
+	},
+	"ask": func(c *Checker, args []Type, line int) Type {
+		return String
+	},
+	"head": arrayElement("head"),
+	"last": arrayElement("last"),
+	"tail": func(c *Checker, args []Type, line int) Type {
+		if !c.arity("tail", args, 1, line) || !c.expectArray("tail", args[0], line) {
+			return Any
+		}
+		return args[0]
+	},
+	"push": func(c *Checker, args []Type, line int) Type {
+		if !c.arity("push", args, 2, line) || !c.expectArray("push", args[0], line) {
+			return Any
+		}
+		if array, ok := prune(args[0]).(*Array); ok {
+			if c.tryUnify(array.Elem, args[1]) {
+				return array
+			}
+			return &Array{Elem: Any}
+		}
+		return Any
+	},
+	"int":    conversion("int", Int),
+	"float":  conversion("float", Float),
+	"string": conversion("string", String),
+	"char":   conversion("char", Char),
+	"bytes":  conversion("bytes", Bytes),
+	"freeze": func(c *Checker, args []Type, line int) Type {
+		if !c.arity("freeze", args, 1, line) {
+			return Any
+		}
+		return args[0]
+	},
+	"map": func(c *Checker, args []Type, line int) Type {
+		if c.generatorCallback("map", args, line) {
+			return Generator
+		}
+		_, result := c.callback("map", args, 2, line)
+		return &Array{Elem: result}
+	},
+	"filter": func(c *Checker, args []Type, line int) Type {
+		if c.generatorCallback("filter", args, line) {
+			return Generator
+		}
+		elem, _ := c.callback("filter", args, 2, line)
+		return &Array{Elem: elem}
+	},
+	"reduce": func(c *Checker, args []Type, line int) Type {
+		if !c.arity("reduce", args, 3, line) {
+			return Any
+		}
+		elem := c.iterElem("reduce", args[0], line)
+		acc := args[2]
+		fn := &Func{Params: []Type{acc, elem}, Return: acc}
+		if !c.tryUnify(fn, args[1]) {
+			c.warnf(line, "cannot use %s as %s in argument 2 to reduce", args[1], fn)
+		}
+		return acc
+	},
+	"each": func(c *Checker, args []Type, line int) Type {
+		c.callback("each", args, 2, line)
+		return Null
+	},
+	"find": func(c *Checker, args []Type, line int) Type {
+		elem, _ := c.callback("find", args, 2, line)
+		return elem
+	},
+	"any": func(c *Checker, args []Type, line int) Type {
+		c.callback("any", args, 2, line)
+		return Bool
+	},
+	"all": func(c *Checker, args []Type, line int) Type {
+		c.callback("all", args, 2, line)
+		return Bool
+	},
+	"sort_by": elementArray("sort_by"),
+	"format":  formatted("format", String),
+	"sayf":    formatted("sayf", Null),
+
+	"json_parse":     signature("json_parse", Any, 1, String),
+	"json_stringify": signature("json_stringify", String, 1, Any, Any),
+	"args":           signature("args", &Array{Elem: String}, 0),
+	"env":            signature("env", String, 1, String),
+	"exit":           signature("exit", Null, 0, Int),
+	"re":             signature("re", Regex, 1, String),
+	"now":            signature("now", Time, 0),
+	"sleep": func(c *Checker, args []Type, line int) Type {
+		if c.arity("sleep", args, 1, line) && prune(args[0]) != Duration {
+			c.expectNumber("sleep", args[0], 0, line)
+		}
+		return Null
+	},
+	"since":      signature("since", Duration, 1, Time),
+	"random":     signature("random", Float, 0),
+	"random_int": signature("random_int", Int, 2, Int, Int),
+	"choice": func(c *Checker, args []Type, line int) Type {
+		if !c.arity("choice", args, 1, line) {
+			return Any
+		}
+		return c.iterElem("choice", args[0], line)
+	},
+	"shuffle": func(c *Checker, args []Type, line int) Type {
+		if !c.arity("shuffle", args, 1, line) {
+			return Any
+		}
+		return &Array{Elem: c.iterElem("shuffle", args[0], line)}
+	},
+	"seed": signature("seed", Null, 1, Int),
+
+	"regex.match":    signature("regex.match", Bool, 1, String),
+	"regex.find":     signature("regex.find", String, 1, String),
+	"regex.find_all": signature("regex.find_all", &Array{Elem: String}, 1, String),
+	"regex.captures": signature("regex.captures", &Array{Elem: String}, 1, String),
+	"regex.named":    signature("regex.named", &Hash{Key: String, Value: String}, 1, String),
+	"regex.split":    signature("regex.split", &Array{Elem: String}, 1, String),
+	"regex.replace":  signature("regex.replace", String, 2, String, Any),
+
+	"strings.split":       signature("strings.split", &Array{Elem: String}, 2, String, String),
+	"strings.join":        signature("strings.join", String, 2, &Array{Elem: Any}, String),
+	"strings.trim":        signature("strings.trim", String, 1, String, String),
+	"strings.trim_left":   signature("strings.trim_left", String, 1, String, String),
+	"strings.trim_right":  signature("strings.trim_right", String, 1, String, String),
+	"strings.replace":     signature("strings.replace", String, 3, String, String, String),
+	"strings.contains":    signature("strings.contains", Bool, 2, String, String),
+	"strings.starts_with": signature("strings.starts_with", Bool, 2, String, String),
+	"strings.ends_with":   signature("strings.ends_with", Bool, 2, String, String),
+	"strings.index_of":    signature("strings.index_of", Int, 2, String, String),
+	"strings.upper":       signature("strings.upper", String, 1, String),
+	"strings.lower":       signature("strings.lower", String, 1, String),
+	"strings.repeat":      signature("strings.repeat", String, 2, String, Int),
+	"strings.pad_left":    signature("strings.pad_left", String, 2, String, Int, Any),
+	"strings.pad_right":   signature("strings.pad_right", String, 2, String, Int, Any),
+	"strings.chars":       signature("strings.chars", &Array{Elem: Char}, 1, String),
+	"strings.lines":       signature("strings.lines", &Array{Elem: String}, 1, String),
+
+	"math.sqrt":   numeric("math.sqrt", Float, 1),
+	"math.sin":    numeric("math.sin", Float, 1),
+	"math.cos":    numeric("math.cos", Float, 1),
+	"math.tan":    numeric("math.tan", Float, 1),
+	"math.log":    numeric("math.log", Float, 1),
+	"math.log10":  numeric("math.log10", Float, 1),
+	"math.exp":    numeric("math.exp", Float, 1),
+	"math.atan2":  numeric("math.atan2", Float, 2),
+	"math.pow":    numeric("math.pow", Any, 2),
+	"math.floor":  numeric("math.floor", Int, 1),
+	"math.ceil":   numeric("math.ceil", Int, 1),
+	"math.round":  numeric("math.round", Int, 1),
+	"math.is_nan": numeric("math.is_nan", Bool, 1),
+	"math.is_inf": numeric("math.is_inf", Bool, 1),
+	"math.gcd":    signature("math.gcd", Int, 2, Int, Int),
+	"math.lcm":    signature("math.lcm", Int, 2, Int, Int),
+	"math.abs": func(c *Checker, args []Type, line int) Type {
+		numeric("math.abs", Any, 1)(c, args, line)
+		if len(args) != 1 {
+			return Any
+		}
+		return args[0]
+	},
+	"math.clamp": func(c *Checker, args []Type, line int) Type {
+		numeric("math.clamp", Any, 3)(c, args, line)
+		return c.joinAll(args)
+	},
+	"math.min": extreme("math.min"),
+	"math.max": extreme("math.max"),
+	"done": func(c *Checker, args []Type, line int) Type {
+		if !c.arity("done", args, 1, line) {
+			return Bool
+		}
+		if !c.tryUnify(Generator, args[0]) {
+			c.warnf(line, "argument to `done` must be generator, got %s", args[0])
+		}
+		return Bool
+	},
+
+	"fs.read_file":   signature("fs.read_file", String, 1, String),
+	"fs.write_file":  signature("fs.write_file", Null, 2, String, String),
+	"fs.append_file": signature("fs.append_file", Null, 2, String, String),
+	"fs.list_dir":    signature("fs.list_dir", &Array{Elem: String}, 1, String),
+	"fs.exists":      signature("fs.exists", Bool, 1, String),
+	"fs.remove":      signature("fs.remove", Null, 1, String),
+	"fs.mkdir":       signature("fs.mkdir", Null, 1, String),
+	"path.join": func(c *Checker, args []Type, line int) Type {
+		if len(args) == 0 {
+			c.warnf(line, "wrong number of arguments to path.join: want=1 or more, got=0")
+		}
+		for i, arg := range args {
+			if !c.tryUnify(String, arg) {
+				c.warnf(line, "cannot use %s as string in argument %d to path.join", arg, i+1)
+			}
+		}
+		return String
+	},
+	"path.base": signature("path.base", String, 1, String),
+	"path.dir":  signature("path.dir", String, 1, String),
+	"path.ext":  signature("path.ext", String, 1, String),
+
+	"time.ms":        numeric("time.ms", Duration, 1),
+	"time.seconds":   numeric("time.seconds", Duration, 1),
+	"time.minutes":   numeric("time.minutes", Duration, 1),
+	"time.hours":     numeric("time.hours", Duration, 1),
+	"time.format":    signature("time.format", String, 1, Time, String),
+	"time.parse":     signature("time.parse", Time, 1, String, String),
+	"time.unix":      signature("time.unix", Int, 1, Time),
+	"time.from_unix": signature("time.from_unix", Time, 1, Int),
+}
+
+func arrayElement(name string) func(c *Checker, args []Type, line int) Type {
+	return func(c *Checker, args []Type, line int) Type {
+		if !c.arity(name, args, 1, line) || !c.expectArray(name, args[0], line) {
+			return Any
+		}
+		if array, ok := prune(args[0]).(*Array); ok {
+			return array.Elem
+		}
+		return Any
+	}
+}
+
+// elementArray types builtins that return some of the elements of
+// their first argument
+func elementArray(name string) func(c *Checker, args []Type, line int) Type {
+	return func(c *Checker, args []Type, line int) Type {
+		elem, _ := c.callback(name, args, 2, line)
+		return &Array{Elem: elem}
+	}
+}
+
+// signature types a builtin that takes params and returns result, the
+// params after the first required ones are optional
+func signature(
+	name string,
+	result Type,
+	required int,
+	params ...Type,
+) func(c *Checker, args []Type, line int) Type {
+	return func(c *Checker, args []Type, line int) Type {
+		if len(args) < required || len(args) > len(params) {
+			want := fmt.Sprint(required)
+			if required != len(params) {
+				want = fmt.Sprintf("%d to %d", required, len(params))
+			}
+			c.warnf(line, "wrong number of arguments to %s: want=%s, got=%d",
+				name, want, len(args))
+			return result
+		}
+		for i, arg := range args {
+			if !c.tryUnify(params[i], arg) {
+				c.warnf(line, "cannot use %s as %s in argument %d to %s",
+					arg, params[i], i+1, name)
+			}
+		}
+		return result
+	}
+}
+
+// formatted types a builtin taking a format string and any values
+func formatted(name string, result Type) func(c *Checker, args []Type, line int) Type {
+	return func(c *Checker, args []Type, line int) Type {
+		if len(args) == 0 {
+			c.warnf(line, "wrong number of arguments to %s: want=1 or more, got=0", name)
+			return result
+		}
+		if !c.tryUnify(String, args[0]) {
+			c.warnf(line, "cannot use %s as string in argument 1 to %s", args[0], name)
+		}
+		return result
+	}
+}
+
+// numeric types a builtin taking want INTEGER or FLOAT arguments
+func numeric(name string, result Type, want int) func(c *Checker, args []Type, line int) Type {
+	return func(c *Checker, args []Type, line int) Type {
+		if !c.arity(name, args, want, line) {
+			return result
+		}
+		for i, arg := range args {
+			c.expectNumber(name, arg, i, line)
+		}
+		return result
+	}
+}
+
+// extreme types math.min and math.max, which take numbers or an
+// array of numbers
+func extreme(name string) func(c *Checker, args []Type, line int) Type {
+	return func(c *Checker, args []Type, line int) Type {
+		if len(args) == 1 {
+			if array, ok := prune(args[0]).(*Array); ok {
+				c.expectNumber(name, array.Elem, 0, line)
+				return array.Elem
+			}
+		}
+		for i, arg := range args {
+			c.expectNumber(name, arg, i, line)
+		}
+		return c.joinAll(args)
+	}
+}
+
+func (c *Checker) expectNumber(name string, t Type, i int, line int) {
+	switch prune(t) {
+	case Int, Float, Any:
+		return
+	}
+	if _, ok := prune(t).(*Var); ok {
+		return
+	}
+	c.warnf(line, "argument %d to `%s` must be int or float, got %s", i+1, name, t)
+}
+
+// joinAll returns the type shared by all of types, or any
+func (c *Checker) joinAll(types []Type) Type {
+	if len(types) == 0 {
+		return Any
+	}
+	t := types[0]
+	for _, other := range types[1:] {
+		t = join(t, other)
+	}
+	return t
+}
+
+func conversion(name string, result Type) func(c *Checker, args []Type, line int) Type {
+	return func(c *Checker, args []Type, line int) Type {
+		c.arity(name, args, 1, line)
+		return result
+	}
+}
+
+func (c *Checker) arity(name string, args []Type, want int, line int) bool {
+	if len(args) != want {
+		c.warnf(line, "wrong number of arguments to %s: want=%d, got=%d",
+			name, want, len(args))
+		return false
+	}
+	return true
+}
+
+func (c *Checker) expectArray(name string, t Type, line int) bool {
+	switch prune(t).(type) {
+	case *Array, *Var:
+		return true
+	}
+	if prune(t) == Any {
+		return true
+	}
+	c.warnf(line, "argument to `%s` must be array, got %s", name, t)
+	return false
+}
+
+// callback checks a builtin that calls its second argument with each
+// element of the first, returning the element type and the type the
+// function returns
+func (c *Checker) callback(name string, args []Type, want int, line int) (Type, Type) {
+	if !c.arity(name, args, want, line) {
+		return Any, Any
+	}
+
+	elem := c.iterElem(name, args[0], line)
+	fn := &Func{Params: []Type{elem}, Return: c.newVar()}
+	if !c.tryUnify(fn, args[1]) {
+		c.warnf(line, "cannot use %s as %s in argument 2 to %s", args[1], fn, name)
+	}
+	return elem, fn.Return
+}
+
+// generatorCallback checks map or filter over a generator, which return
+// a generator, the values of a generator are not typed
+func (c *Checker) generatorCallback(name string, args []Type, line int) bool {
+	if len(args) != 2 || prune(args[0]) != Generator {
+		return false
+	}
+
+	fn := &Func{Params: []Type{Any}, Return: c.newVar()}
+	if !c.tryUnify(fn, args[1]) {
+		c.warnf(line, "cannot use %s as %s in argument 2 to %s", args[1], fn, name)
+	}
+	return true
+}
+
+// iterElem returns the type of the elements of an iterable
+func (c *Checker) iterElem(name string, t Type, line int) Type {
+	switch t := prune(t).(type) {
+	case *Array:
+		return t.Elem
+	case *Var:
+		return Any
+	}
+	switch prune(t) {
+	case String:
+		return String
+	case Range, Bytes:
+		return Int
+	case Any:
+		return Any
+	}
+	c.warnf(line, "argument to `%s` must be iterable, got %s", name, t)
+	return Any
+}
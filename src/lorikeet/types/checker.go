@@ -0,0 +1,785 @@
+package types
+
+import (
+	"fmt"
+	"lorikeet/ast"
+	"lorikeet/object"
+)
+
+type binding struct {
+	scheme    *Scheme
+	annotated bool
+}
+
+// Env maps names to their type schemes
+type Env struct {
+	store map[string]*binding
+	outer *Env
+}
+
+func newEnv(outer *Env) *Env {
+	return &Env{store: make(map[string]*binding), outer: outer}
+}
+
+func (e *Env) define(name string, scheme *Scheme, annotated bool) {
+	e.store[name] = &binding{scheme: scheme, annotated: annotated}
+}
+
+func (e *Env) resolve(name string) (*binding, bool) {
+	b, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.resolve(name)
+	}
+	return b, ok
+}
+
+func (e *Env) freeVars() []*Var {
+	vars := []*Var{}
+	for env := e; env != nil; env = env.outer {
+		for _, b := range env.store {
+			for _, v := range freeVars(b.scheme.Type, nil) {
+				if !containsVar(b.scheme.Vars, v) && !containsVar(vars, v) {
+					vars = append(vars, v)
+				}
+			}
+		}
+	}
+	return vars
+}
+
+func containsVar(vars []*Var, v *Var) bool {
+	for _, o := range vars {
+		if o == v {
+			return true
+		}
+	}
+	return false
+}
+
+// returnSlot collects the types a function can return
+type returnSlot struct {
+	typ       Type
+	annotated bool
+	ignore    bool // generators do not return their body's value
+}
+
+// Checker infers the types of a program, unannotated code is inferred
+// Hindley-Milner style and anything only known at runtime becomes any
+type Checker struct {
+	env      *Env
+	enums    map[string]*Enum
+	returns  []*returnSlot
+	trail    []*Var
+	nextVar  int
+	errors   []string
+	warnings []string
+}
+
+// New creates a checker that knows the builtin functions
+func New() *Checker {
+	env := newEnv(nil)
+	for _, v := range object.Builtins {
+		b := &Builtin{Name: v.Name, Check: builtinChecks[v.Name]}
+		env.define(v.Name, &Scheme{Type: b}, false)
+	}
+	for _, m := range object.Modules {
+		env.define(m.Name, &Scheme{Type: moduleType(m)}, false)
+	}
+
+	return &Checker{env: env, enums: make(map[string]*Enum)}
+}
+
+func moduleType(m *object.Module) *Module {
+	module := &Module{Name: m.Name, Members: make(map[string]Type)}
+	for name, member := range m.Members {
+		qualified := m.Name + "." + name
+		switch member.(type) {
+		case *object.Builtin:
+			module.Members[name] = &Builtin{Name: qualified, Check: builtinChecks[qualified]}
+		case *object.Integer:
+			module.Members[name] = Int
+		case *object.Float:
+			module.Members[name] = Float
+		default:
+			module.Members[name] = Any
+		}
+	}
+	return module
+}
+
+// Check type checks a program with a new checker
+func Check(program *ast.Program) []string {
+	return New().Check(program)
+}
+
+// Check type checks a program and returns the conflicts with its type
+// annotations, definitions are kept so later programs can use them
+func (c *Checker) Check(program *ast.Program) []string {
+	c.errors = []string{}
+	c.warnings = []string{}
+	for _, s := range program.Statements {
+		c.statement(s)
+	}
+	return c.errors
+}
+
+// Warnings returns the mismatches found by the last Check that only
+// involve inferred types, the program may still run
+func (c *Checker) Warnings() []string {
+	return c.warnings
+}
+
+// errorf reports a conflict with a type annotation
+func (c *Checker) errorf(line int, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	c.errors = append(c.errors, fmt.Sprintf("%s; line=%d", msg, line))
+}
+
+// warnf reports a mismatch between inferred types
+func (c *Checker) warnf(line int, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	c.warnings = append(c.warnings, fmt.Sprintf("%s; line=%d", msg, line))
+}
+
+// statement checks a statement and returns the value it leaves in a block
+func (c *Checker) statement(s ast.Statement) Type {
+	switch s := s.(type) {
+	case *ast.ExpressionStatement:
+		return c.infer(s.Expression)
+
+	case *ast.LetStatement:
+		c.letStatement(s)
+
+	case *ast.DestructureStatement:
+		c.infer(s.Value)
+		for _, name := range s.Names {
+			c.env.define(name.Value, &Scheme{Type: Any}, false)
+		}
+
+	case *ast.IndexAssignStatement:
+		c.indexAssign(s)
+
+	case *ast.MutStatement:
+		t := c.infer(s.Value)
+		b, ok := c.env.resolve(s.Name.Value)
+		if !ok {
+			break
+		}
+		if c.tryUnify(b.scheme.Type, t) || !b.annotated {
+			break
+		}
+		c.errorf(s.Line(), "cannot assign %s to %s of type %s",
+			t, s.Name.Value, b.scheme.Type)
+
+	case *ast.ReturnStatement:
+		var t Type = Null
+		if s.ReturnValue != nil {
+			t = c.infer(s.ReturnValue)
+		}
+		if len(c.returns) > 0 {
+			c.addReturn(c.returns[len(c.returns)-1], t, s.Line())
+		}
+		// Nothing after a return runs, so it matches any value
+		return c.newVar()
+
+	case *ast.EnumStatement:
+		enum := &Enum{Name: s.Name.Value}
+		c.enums[enum.Name] = enum
+		for _, v := range s.Variants {
+			var t Type = enum
+			if len(v.Fields) > 0 {
+				params := make([]Type, len(v.Fields))
+				for i := range params {
+					params[i] = Any
+				}
+				t = &Func{Params: params, Return: enum}
+			}
+			c.env.define(v.Name.Value, &Scheme{Type: t}, false)
+		}
+	}
+
+	return Null
+}
+
+func (c *Checker) letStatement(s *ast.LetStatement) {
+	var annotated Type
+	if s.Type != nil {
+		annotated = c.annotation(s.Type)
+	}
+
+	// Functions can refer to themselves
+	_, isFunction := s.Value.(*ast.FunctionLiteral)
+	var self *Var
+	if isFunction {
+		self = c.newVar()
+		c.env.define(s.Name.Value, &Scheme{Type: self}, false)
+	}
+
+	t := c.infer(s.Value)
+	if isFunction {
+		c.tryUnify(self, t)
+	}
+
+	if annotated != nil {
+		if !c.tryUnify(annotated, t) {
+			c.errorf(s.Line(), "cannot use %s as %s in let %s", t, annotated, s.Name.Value)
+		}
+		t = annotated
+	}
+
+	// An earlier binding of the name, or the self binding of a function,
+	// would keep its variables bound
+	delete(c.env.store, s.Name.Value)
+	c.env.define(s.Name.Value, c.generalize(t), annotated != nil)
+}
+
+func (c *Checker) generalize(t Type) *Scheme {
+	bound := c.env.freeVars()
+	vars := []*Var{}
+	for _, v := range freeVars(t, nil) {
+		if !containsVar(bound, v) {
+			vars = append(vars, v)
+		}
+	}
+	return &Scheme{Vars: vars, Type: t}
+}
+
+func (c *Checker) addReturn(slot *returnSlot, t Type, line int) {
+	switch {
+	case slot.ignore:
+	case slot.annotated:
+		if !c.tryUnify(slot.typ, t) {
+			c.errorf(line, "cannot return %s from function returning %s", t, slot.typ)
+		}
+	default:
+		slot.typ = join(slot.typ, t)
+	}
+}
+
+func (c *Checker) block(b *ast.BlockStatement) Type {
+	var t Type = Null
+	if b == nil {
+		return t
+	}
+	for _, s := range b.Statements {
+		t = c.statement(s)
+	}
+	return t
+}
+
+func (c *Checker) infer(node ast.Expression) Type {
+	switch node := node.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.CharLiteral:
+		return Char
+	case *ast.Boolean:
+		return Bool
+
+	case *ast.Identifier:
+		b, ok := c.env.resolve(node.Value)
+		if !ok {
+			// Undefined variables are reported by the compiler
+			return Any
+		}
+		return c.instantiate(b.scheme)
+
+	case *ast.ArrayLiteral:
+		var elem Type
+		for _, e := range node.Elements {
+			elem = join(elem, c.infer(e))
+		}
+		if elem == nil {
+			elem = c.newVar()
+		}
+		return &Array{Elem: elem}
+
+	case *ast.TupleLiteral:
+		for _, e := range node.Elements {
+			c.infer(e)
+		}
+		return Any
+
+	case *ast.SetLiteral:
+		for _, e := range node.Elements {
+			c.infer(e)
+		}
+		return Any
+
+	case *ast.HashLiteral:
+		var key, value Type
+		for k, v := range node.Pairs {
+			key = join(key, c.infer(k))
+			value = join(value, c.infer(v))
+		}
+		if key == nil {
+			key, value = c.newVar(), c.newVar()
+		}
+		return &Hash{Key: key, Value: value}
+
+	case *ast.PrefixExpression:
+		return c.prefix(node)
+
+	case *ast.InfixExpression:
+		return c.infix(node)
+
+	case *ast.IfExpression:
+		c.infer(node.Condition)
+		consequence := c.block(node.Consequence)
+		var alternative Type = Null
+		if node.Alternative != nil {
+			alternative = c.block(node.Alternative)
+		}
+		return join(consequence, alternative)
+
+	case *ast.MatchExpression:
+		return c.match(node)
+
+	case *ast.FunctionLiteral:
+		return c.function(node)
+
+	case *ast.CallExpression:
+		if ident, ok := node.Function.(*ast.Identifier); ok &&
+			(ident.Value == "quote" || ident.Value == "unquote") {
+			return Any
+		}
+		fn := c.infer(node.Function)
+		args := make([]Type, len(node.Arguments))
+		for i, a := range node.Arguments {
+			args[i] = c.infer(a)
+		}
+		return c.call(fn, args, node.Function.String(), node.Line())
+
+	case *ast.PartialExpression:
+		fn := c.infer(node.Call.Function)
+		args := make([]Type, len(node.Call.Arguments))
+		params := []Type{}
+		for i, a := range node.Call.Arguments {
+			if ast.IsPlaceholder(a) {
+				args[i] = c.newVar()
+				params = append(params, args[i])
+			} else {
+				args[i] = c.infer(a)
+			}
+		}
+		ret := c.call(fn, args, node.Call.Function.String(), node.Line())
+		return &Func{Params: params, Return: ret}
+
+	case *ast.IndexExpression:
+		return c.index(node)
+
+	case *ast.SliceExpression:
+		return c.slice(node)
+
+	case *ast.YieldExpression:
+		if node.Value != nil {
+			c.infer(node.Value)
+		}
+		return Any
+	}
+
+	return Any
+}
+
+func (c *Checker) prefix(node *ast.PrefixExpression) Type {
+	right := c.infer(node.Right)
+
+	switch node.Operator {
+	case "!":
+		return Bool
+	case "-":
+		switch prune(right).(type) {
+		case *Var:
+			return right
+		}
+		if t := prune(right); t == Int || t == Float || t == Duration || t == Any {
+			return t
+		}
+		c.warnf(node.Line(), "unknown operator: -%s", right)
+		return Any
+	}
+
+	return right
+}
+
+func (c *Checker) infix(node *ast.InfixExpression) Type {
+	if node.Operator == ">>" {
+		first, second := c.infer(node.Left), c.infer(node.Right)
+		arg := c.newVar()
+		middle := c.call(first, []Type{arg}, node.Left.String(), node.Line())
+		ret := c.call(second, []Type{middle}, node.Right.String(), node.Line())
+		return &Func{Params: []Type{arg}, Return: ret}
+	}
+
+	left, right := c.infer(node.Left), c.infer(node.Right)
+
+	switch node.Operator {
+	case "??":
+		if prune(left) == Null {
+			return right
+		}
+		return join(left, right)
+	case "==", "!=", "in":
+		return Bool
+	case "..", "..=":
+		if !c.tryUnify(Int, left) || !c.tryUnify(Int, right) {
+			c.warnf(node.Line(), "range bounds must be int, got %s%s%s",
+				left, node.Operator, right)
+		}
+		return Range
+	}
+
+	if t, ok := timeOperation(node.Operator, prune(left), prune(right)); ok {
+		return t
+	}
+
+	if !c.tryUnify(left, right) {
+		c.warnf(node.Line(), "type mismatch: %s %s %s", left, node.Operator, right)
+		return Any
+	}
+
+	t := prune(left)
+	if _, ok := t.(*Var); !ok && t != Any && !operatorSupports(node.Operator, t) {
+		c.warnf(node.Line(), "unknown operator: %s %s %s", left, node.Operator, right)
+		return Any
+	}
+
+	if node.Operator == "<" || node.Operator == ">" {
+		return Bool
+	}
+	return t
+}
+
+func operatorSupports(operator string, t Type) bool {
+	switch operator {
+	case "<", ">":
+		if _, ok := t.(*Array); ok {
+			return true
+		}
+		return t == Int || t == Float || t == String || t == Char || t == Bytes
+	case "+":
+		return t == Int || t == Float || t == String
+	case "-", "*", "/":
+		return t == Int || t == Float
+	}
+	return false
+}
+
+// timeOperation types the operators taking times and durations, ok is
+// false when neither operand is one
+func timeOperation(operator string, left, right Type) (Type, bool) {
+	isTime := func(t Type) bool { return t == Time || t == Duration }
+	if !isTime(left) && !isTime(right) {
+		return nil, false
+	}
+	isNumber := func(t Type) bool { return t == Int || t == Float }
+
+	switch {
+	case left == Any || right == Any:
+		return Any, true
+	case operator == "<" || operator == ">":
+		if left == right {
+			return Bool, true
+		}
+	case operator == "+" && left == Time && right == Duration,
+		operator == "+" && left == Duration && right == Time,
+		operator == "-" && left == Time && right == Duration:
+		return Time, true
+	case operator == "-" && left == Time && right == Time,
+		(operator == "+" || operator == "-") && left == Duration && right == Duration,
+		(operator == "*" || operator == "/") && left == Duration && isNumber(right),
+		operator == "*" && isNumber(left) && right == Duration:
+		return Duration, true
+	case operator == "/" && left == Duration && right == Duration:
+		return Float, true
+	}
+
+	if _, ok := left.(*Var); ok {
+		return Any, true
+	}
+	if _, ok := right.(*Var); ok {
+		return Any, true
+	}
+	return nil, false
+}
+
+func (c *Checker) match(node *ast.MatchExpression) Type {
+	c.infer(node.Subject)
+
+	var result Type
+	for _, arm := range node.Arms {
+		outer := c.env
+		if len(arm.Bindings) > 0 {
+			c.env = newEnv(outer)
+			for _, b := range arm.Bindings {
+				c.env.define(b.Value, &Scheme{Type: Any}, false)
+			}
+		}
+		if arm.Value != nil {
+			c.infer(arm.Value)
+		}
+		result = join(result, c.block(arm.Body))
+		c.env = outer
+	}
+
+	if result == nil {
+		return c.newVar()
+	}
+	return result
+}
+
+func (c *Checker) function(node *ast.FunctionLiteral) Type {
+	outer := c.env
+	c.env = newEnv(outer)
+	defer func() { c.env = outer }()
+
+	params := make([]Type, len(node.Parameters))
+	annotated := make([]bool, len(node.Parameters))
+	for i, p := range node.Parameters {
+		annotated[i] = i < len(node.ParameterTypes) && node.ParameterTypes[i] != nil
+		if annotated[i] {
+			params[i] = c.annotation(node.ParameterTypes[i])
+		} else {
+			params[i] = c.newVar()
+		}
+		c.env.define(p.Value, &Scheme{Type: params[i]}, annotated[i])
+	}
+
+	slot := &returnSlot{ignore: node.Generator}
+	if node.ReturnType != nil {
+		slot.typ = c.annotation(node.ReturnType)
+		slot.annotated = true
+	}
+
+	c.returns = append(c.returns, slot)
+	body := c.block(node.Body)
+	line := node.Line()
+	if n := len(node.Body.Statements); n > 0 {
+		line = node.Body.Statements[n-1].Line()
+	}
+	c.addReturn(slot, body, line)
+	c.returns = c.returns[:len(c.returns)-1]
+
+	if node.Generator {
+		return &Func{Params: params, Return: Generator, Annotated: annotated}
+	}
+	return &Func{Params: params, Return: slot.typ, Annotated: annotated}
+}
+
+// call checks calling a value of type fn with arguments of the given
+// types and returns the type of the result
+func (c *Checker) call(fn Type, args []Type, name string, line int) Type {
+	switch f := prune(fn).(type) {
+	case *Func:
+		if len(args) != len(f.Params) {
+			c.warnf(line, "wrong number of arguments to %s: want=%d, got=%d",
+				name, len(f.Params), len(args))
+			return f.Return
+		}
+		for i := range args {
+			if c.tryUnify(f.Params[i], args[i]) {
+				continue
+			}
+			report := c.warnf
+			if f.annotated(i) {
+				report = c.errorf
+			}
+			report(line, "cannot use %s as %s in argument %d to %s",
+				args[i], f.Params[i], i+1, name)
+		}
+		return f.Return
+
+	case *Builtin:
+		if f.Check == nil {
+			return Any
+		}
+		return f.Check(c, args, line)
+
+	case *Var:
+		ret := c.newVar()
+		c.unify(f, &Func{Params: args, Return: ret})
+		return ret
+	}
+
+	if t := prune(fn); t != Any && t != Generator {
+		c.warnf(line, "cannot call %s of type %s", name, t)
+	}
+	return Any
+}
+
+func (c *Checker) index(node *ast.IndexExpression) Type {
+	left, index := c.infer(node.Left), c.infer(node.Index)
+
+	switch t := prune(left).(type) {
+	case *Array:
+		c.expectIndex(left, index, node.Line())
+		return t.Elem
+	case *Hash:
+		c.tryUnify(t.Key, index)
+		return t.Value
+	case *Module:
+		return c.member(t, node)
+	case *Var:
+		return Any
+	}
+
+	switch prune(left) {
+	case String:
+		c.expectIndex(left, index, node.Line())
+		return String
+	case Range, Bytes:
+		c.expectIndex(left, index, node.Line())
+		return Int
+	case Regex:
+		return c.method(node)
+	case Null:
+		if node.Optional {
+			return Null
+		}
+	case Any:
+		return Any
+	}
+
+	c.warnf(node.Line(), "index operator not supported: %s", left)
+	return Any
+}
+
+func (c *Checker) member(m *Module, node *ast.IndexExpression) Type {
+	name, ok := node.Index.(*ast.StringLiteral)
+	if !ok {
+		return Any
+	}
+	t, ok := m.Members[name.Value]
+	if !ok {
+		c.warnf(node.Line(), "module %s has no member %s", m.Name, name.Value)
+		return Any
+	}
+	return t
+}
+
+// method types regex.name, regex methods are checked like builtins
+func (c *Checker) method(node *ast.IndexExpression) Type {
+	name, ok := node.Index.(*ast.StringLiteral)
+	if !ok {
+		return Any
+	}
+	qualified := "regex." + name.Value
+	check, ok := builtinChecks[qualified]
+	if !ok {
+		c.warnf(node.Line(), "regex has no method %s", name.Value)
+		return Any
+	}
+	return &Builtin{Name: qualified, Check: check}
+}
+
+func (c *Checker) indexAssign(s *ast.IndexAssignStatement) {
+	left := c.infer(s.Target.Left)
+	index, value := c.infer(s.Target.Index), c.infer(s.Value)
+
+	var elem Type
+	switch t := prune(left).(type) {
+	case *Array:
+		c.expectIndex(left, index, s.Line())
+		elem = t.Elem
+	case *Hash:
+		c.tryUnify(t.Key, index)
+		elem = t.Value
+	case *Var:
+		return
+	default:
+		if t != Any {
+			c.warnf(s.Line(), "index assignment not supported: %s", left)
+		}
+		return
+	}
+
+	if !c.tryUnify(elem, value) {
+		c.warnf(s.Line(), "cannot assign %s to element of %s", value, left)
+	}
+}
+
+func (c *Checker) expectIndex(left, index Type, line int) {
+	if !c.tryUnify(Int, index) {
+		c.warnf(line, "index operator not supported: %s[%s]", left, index)
+	}
+}
+
+func (c *Checker) slice(node *ast.SliceExpression) Type {
+	left := c.infer(node.Left)
+	for _, bound := range []ast.Expression{node.Start, node.End} {
+		if bound == nil {
+			continue
+		}
+		if t := c.infer(bound); !c.tryUnify(Int, t) && prune(t) != Null {
+			c.warnf(node.Line(), "slice bound must be int, got %s", t)
+		}
+	}
+
+	switch t := prune(left).(type) {
+	case *Array, *Var:
+		return t
+	}
+	switch t := prune(left); t {
+	case String, Bytes, Range, Any:
+		return t
+	case Null:
+		if node.Optional {
+			return Null
+		}
+	}
+
+	c.warnf(node.Line(), "slice operator not supported: %s", left)
+	return Any
+}
+
+var basicTypes = map[string]*Basic{
+	"int":       Int,
+	"float":     Float,
+	"string":    String,
+	"char":      Char,
+	"bytes":     Bytes,
+	"bool":      Bool,
+	"null":      Null,
+	"range":     Range,
+	"generator": Generator,
+	"regex":     Regex,
+	"time":      Time,
+	"duration":  Duration,
+	"any":       Any,
+}
+
+// annotation converts a type annotation to a type
+func (c *Checker) annotation(ta *ast.TypeAnnotation) Type {
+	switch ta.Name {
+	case "[]":
+		return &Array{Elem: c.annotation(ta.Elements[0])}
+	case "{}":
+		return &Hash{Key: c.annotation(ta.Elements[0]), Value: c.annotation(ta.Elements[1])}
+	case "fn":
+		params := make([]Type, len(ta.Elements))
+		annotated := make([]bool, len(ta.Elements))
+		for i, e := range ta.Elements {
+			params[i] = c.annotation(e)
+			annotated[i] = true
+		}
+		var ret Type = Any
+		if ta.Return != nil {
+			ret = c.annotation(ta.Return)
+		}
+		return &Func{Params: params, Return: ret, Annotated: annotated}
+	}
+
+	if t, ok := basicTypes[ta.Name]; ok {
+		return t
+	}
+	if e, ok := c.enums[ta.Name]; ok {
+		return e
+	}
+
+	c.errorf(ta.Line(), "unknown type %s", ta.Name)
+	return Any
+}
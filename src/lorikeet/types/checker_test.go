@@ -0,0 +1,222 @@
+package types
+
+import (
+	"lorikeet/ast"
+	"lorikeet/lexer"
+	"lorikeet/parser"
+	"testing"
+)
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`let x: int = "a";`, []string{"cannot use string as int in let x; line=1"}},
+		{`let mut x: int = 1; x = "a";`, []string{"cannot assign string to x of type int; line=1"}},
+		{`let xs: [int] = ["a"];`, []string{"cannot use [string] as [int] in let xs; line=1"}},
+		{`let h: {string: int} = {1: 1};`, []string{"cannot use {int: int} as {string: int} in let h; line=1"}},
+		{`let x: foo = 1;`, []string{"unknown type foo; line=1"}},
+		{`let f: fn(int) -> int = fn(x) { x }; f("a")`, []string{"cannot use string as int in argument 1 to f; line=1"}},
+		{
+			`fn f(a: int) { a }
+			f("a")`,
+			[]string{"cannot use string as int in argument 1 to f; line=2"},
+		},
+		{
+			`fn f(a: int) -> string {
+				a
+			}`,
+			[]string{"cannot return int from function returning string; line=2"},
+		},
+		{
+			`fn f(a: int) -> int {
+				if (a > 1) { return "big"; }
+				a
+			}`,
+			[]string{"cannot return string from function returning int; line=2"},
+		},
+		{"let n: int = map(fn*() { yield 1 }(), fn(x) { x });", []string{"cannot use generator as int in let n; line=1"}},
+		{`let n: int = strings.upper("a");`, []string{"cannot use string as int in let n; line=1"}},
+		{`let n: int = re("a").find("a");`, []string{"cannot use string as int in let n; line=1"}},
+		{"let d: duration = now() - time.ms(1);", []string{"cannot use time as duration in let d; line=1"}},
+		{"let s: string = choice([1]);", []string{"cannot use int as string in let s; line=1"}},
+		{`let n: int = json_stringify([1]);`, []string{"cannot use string as int in let n; line=1"}},
+		{`let n: int = format("%d", 1);`, []string{"cannot use string as int in let n; line=1"}},
+		{"let x: int = math.sqrt(4);", []string{"cannot use float as int in let x; line=1"}},
+		{`let xs: [string] = map([1], fn(x) { x + 1 });`, []string{"cannot use [int] as [string] in let xs; line=1"}},
+	}
+
+	for _, tt := range tests {
+		checker := New()
+		expectFindings(t, tt.input, "errors", checker.Check(parse(t, tt.input)), tt.expected)
+		expectFindings(t, tt.input, "warnings", checker.Warnings(), nil)
+	}
+}
+
+func TestTypeWarnings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`1 + "a"`, []string{"type mismatch: int + string; line=1"}},
+		{"true + false", []string{"unknown operator: bool + bool; line=1"}},
+		{`-"a"`, []string{"unknown operator: -string; line=1"}},
+		{`1.."a"`, []string{"range bounds must be int, got int..string; line=1"}},
+		{
+			`let add = fn(a, b) { a + b }; add(1, "a")`,
+			[]string{"cannot use string as int in argument 2 to add; line=1"},
+		},
+		{
+			"let add = fn(a, b) { a + b }; add(1)",
+			[]string{"wrong number of arguments to add: want=2, got=1; line=1"},
+		},
+		{"let f = fn(x) { x * 2 }; f(2); f(2.5)", []string{"cannot use float as int in argument 1 to f; line=1"}},
+		{"5()", []string{"cannot call 5 of type int; line=1"}},
+		{"1 | 2", []string{"unknown operator: int | int; line=1"}},
+		{"'a' + 'b'", []string{"unknown operator: char + char; line=1"}},
+		{`[1]["a"]`, []string{"index operator not supported: [int][string]; line=1"}},
+		{`[1, 2]["a":]`, []string{"slice bound must be int, got string; line=1"}},
+		{"1[0:1]", []string{"slice operator not supported: int; line=1"}},
+		{"len(1)", []string{"argument to `len` not supported, got int; line=1"}},
+		{`push(1, 2)`, []string{"argument to `push` must be array, got int; line=1"}},
+		{"done(1)", []string{"argument to `done` must be generator, got int; line=1"}},
+		{"strings.nope", []string{"module strings has no member nope; line=1"}},
+		{`strings.split("a", 1)`, []string{"cannot use int as string in argument 2 to strings.split; line=1"}},
+		{`strings.trim()`, []string{"wrong number of arguments to strings.trim: want=1 to 2, got=0; line=1"}},
+		{`fs.write_file("a", 1)`, []string{"cannot use int as string in argument 2 to fs.write_file; line=1"}},
+		{`path.join("a", 1)`, []string{"cannot use int as string in argument 2 to path.join; line=1"}},
+		{`exit("a")`, []string{"cannot use string as int in argument 1 to exit; line=1"}},
+		{"env(1)", []string{"cannot use int as string in argument 1 to env; line=1"}},
+		{`re("a").nope`, []string{"regex has no method nope; line=1"}},
+		{`re("a").match(1)`, []string{"cannot use int as string in argument 1 to regex.match; line=1"}},
+		{"now() + 1", []string{"type mismatch: time + int; line=1"}},
+		{"now() * time.ms(1)", []string{"type mismatch: time * duration; line=1"}},
+		{"time.ms(1) < now()", []string{"type mismatch: duration < time; line=1"}},
+		{`since("a")`, []string{"cannot use string as time in argument 1 to since; line=1"}},
+		{`sleep("a")`, []string{"argument 1 to `sleep` must be int or float, got string; line=1"}},
+		{"time.format(1)", []string{"cannot use int as time in argument 1 to time.format; line=1"}},
+		{"random_int(1.5, 2)", []string{"cannot use float as int in argument 1 to random_int; line=1"}},
+		{"shuffle(1)", []string{"argument to `shuffle` must be iterable, got int; line=1"}},
+		{`seed("a")`, []string{"cannot use string as int in argument 1 to seed; line=1"}},
+		{"json_parse(1)", []string{"cannot use int as string in argument 1 to json_parse; line=1"}},
+		{"format(1)", []string{"cannot use int as string in argument 1 to format; line=1"}},
+		{"sayf()", []string{"wrong number of arguments to sayf: want=1 or more, got=0; line=1"}},
+		{`math.sqrt("a")`, []string{"argument 1 to `math.sqrt` must be int or float, got string; line=1"}},
+		{`math.max(["a"])`, []string{"argument 1 to `math.max` must be int or float, got string; line=1"}},
+		{"math.gcd(1.5, 2)", []string{"cannot use float as int in argument 1 to math.gcd; line=1"}},
+		{"map(1, len)", []string{"argument to `map` must be iterable, got int; line=1"}},
+		{`filter(["a"], fn(x) { x + 1 })`, []string{"cannot use fn(int) -> int as fn(string) -> t2 in argument 2 to filter; line=1"}},
+		{"any([1], 1)", []string{"cannot use int as fn(int) -> t1 in argument 2 to any; line=1"}},
+		{`let a = [1]; a[0] = "b";`, []string{"cannot assign string to element of [int]; line=1"}},
+		{`let h = {"a": 1}; h["b"] = true;`, []string{"cannot assign bool to element of {string: int}; line=1"}},
+		{`let h = {"a": 1}; h["b"] = "x"`, []string{"cannot assign string to element of {string: int}; line=1"}},
+		{`let s = "a"; s[0] = "b";`, []string{"index assignment not supported: string; line=1"}},
+		{
+			`let x = 1 + "a";
+			let y = true - 1;`,
+			[]string{
+				"type mismatch: int + string; line=1",
+				"type mismatch: bool - int; line=2",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		checker := New()
+		expectFindings(t, tt.input, "errors", checker.Check(parse(t, tt.input)), nil)
+		expectFindings(t, tt.input, "warnings", checker.Warnings(), tt.expected)
+	}
+}
+
+func TestWellTypedPrograms(t *testing.T) {
+	tests := []string{
+		"let id = fn(x) { x }; id(1); id(\"a\");",
+		"let add = fn(a, b) { a + b }; add(1, 2); add(\"a\", \"b\");",
+		"let apply = fn(f, v) { f(v) }; apply(len, [1]); apply(fn(x) { x * 2 }, 3);",
+		"let fib = fn(n, a, b) { if (n == 0) { return a; } fib(n - 1, b, a + b) }; let x: int = fib(10, 0, 1);",
+		"fn add(a: int, b: int) -> int { a + b } add(1, 2)",
+		"let names: [string] = [\"a\"]; let ages: {string: int} = {\"bob\": 3};",
+		"let arr = [\"test\", 10, true]; let h = {\"a\": 1, \"b\": \"x\"}; h[\"a\"] ?? 0",
+		"let mut c = 0; c = c + 1; c = \"s\";",
+		"if (true) { 1 } else { \"a\" }",
+		"enum Shape { Circle(r), Rect(w, h) } let s: Shape = Circle(2); match (s) { Circle(r) => r * r, Rect(w, h) => w * h }",
+		"fn* nat(i) { yield i; nat(i + 1) } let it = nat(0); it(); done(it);",
+		"let add = fn(a, b) { a + b }; let inc = add(1, _); let f = inc >> inc; f(1);",
+		"[1, 2] |> push(_, 3) |> len",
+		"let g = fn*() { yield 1 }; let m: generator = map(g(), fn(x) { x + 1 }); let f: generator = filter(g(), fn(x) { x > 0 }); m(); f();",
+		"let r = 1..5; r[0]; [1, 2, 3][1:]; \"abc\"[-1]; len(r);",
+		"let f: fn(int) -> int = fn(x) { x + 1 }; f(1)",
+		"say(1, \"a\"); let x: int = int(ask(\"n\")); string(x) + \"!\"",
+		"let xs = tail([1, 2, 3]); let x: int = head(xs);",
+		"let f = fn(g) { g(1) + 1 }; f(fn(x) { x })",
+		"let c: char = 'a'; let b: bytes = bytes(\"é\"); let n: int = b[0] + int(c); c < 'b'; len(b[1:]); len('\\n');",
+		"let (a, b) = (1, \"a\"); let s = #{a} | #{2} - #{3}; let found: bool = b in s;",
+		"let xs: [string] = map([1, 2], fn(x) { string(x) }); let n: int = reduce(xs, fn(acc, x) { acc + len(x) }, 0);",
+		"let big: [int] = filter(1..10, fn(x) { x > 5 }) |> sort_by(_, fn(x) { -x }); let s: string = find([\"a\"], fn(x) { x == \"a\" });",
+		"let parts: [string] = strings.split(\"a,b\", \",\"); let s: string = strings.join(parts, \"\") |> strings.upper; let cs: [char] = strings.chars(s);",
+		"let up = map([\"a\"], strings.upper); let i: int = strings.index_of(\"ab\", \"b\"); strings.pad_left(\"1\", 3, '0');",
+		"let r: int = math.round(math.pi); let f: float = math.sqrt(2) + math.e; let m: int = math.max([1, 2]); let a: float = math.abs(-1.5); let n: int = math.clamp(5, 0, 3) + math.gcd(4, 6);",
+		"let s: string = format(\"%s %d %v\", \"a\", 1, [true]); sayf(\"%6.2f\", 1.5);",
+		"let config = json_parse(\"{}\"); let n: int = config[\"n\"]; let s: string = json_stringify(config, 2);",
+		"let names: [string] = fs.list_dir(\".\"); let ok: bool = fs.exists(path.join(\"a\", \"b\")); let s: string = fs.read_file(path.dir(\"a/b\"));",
+		"let xs: [string] = args(); let home: string = env(\"HOME\") ?? \"/\"; if (len(xs) == 0) { exit(1); } exit();",
+		"let r: regex = re(\"(\\\\d+)\"); let ok: bool = r.match(\"1\"); let all: [string] = r.find_all(\"1 2\"); let s: string = r.replace(\"1\", fn(m) { m[1] }); let h: {string: string} = r.named(\"1\");",
+		"let t: time = now(); sleep(10); sleep(time.seconds(1)); let d: duration = since(t) * 2 + time.ms(1.5); let ratio: float = d / time.ms(1); let later: time = t + -d; let dt: duration = later - t; let ok: bool = d > time.ms(1); let s: string = time.format(time.parse(\"2021\", \"%Y\"), \"%d\"); let u: int = time.unix(time.from_unix(0));",
+		"seed(1); let f: float = random(); let n: int = random_int(1, 6) + choice(1..=6); let xs: [string] = shuffle([\"a\", \"b\"]); let s: string = choice(xs);",
+		"let f = fn(x) { if (x == 0) { \"zero\" } else { x } }; f(0)",
+		"let f = fn(n) { if (n == 0) { return; } n }; f(2) + 1",
+		"let f = fn(x) { x }; let g = f; g(1); g(\"s\")",
+		"let pair = fn(a, b) { [a, b] }; pair(1, \"a\")",
+		"let mk = fn() { fn(x) { x } }; let i = mk(); i(1); i(\"a\")",
+		"fn* naturals(i) { yield i; naturals(i + 1) } let take = fn(n, it) { it() }; take(5, naturals(1)); let f: fn() -> int = naturals(1);",
+		"const xs: [int] = [1]; let ys = freeze([1, 2]); let n: int = ys[0]; let h = {}; h[1] = 2;",
+	}
+
+	for _, input := range tests {
+		checker := New()
+		expectFindings(t, input, "errors", checker.Check(parse(t, input)), nil)
+		expectFindings(t, input, "warnings", checker.Warnings(), nil)
+	}
+}
+
+func TestCheckerKeepsDefinitions(t *testing.T) {
+	checker := New()
+
+	errors := checker.Check(parse(t, "fn add(a: int, b: int) -> int { a + b }"))
+	if len(errors) != 0 {
+		t.Fatalf("unexpected type errors: %q", errors)
+	}
+
+	errors = checker.Check(parse(t, `add(1, "a")`))
+	expected := "cannot use string as int in argument 2 to add; line=1"
+	if len(errors) != 1 || errors[0] != expected {
+		t.Errorf("wrong errors. want=%q, got=%q", expected, errors)
+	}
+}
+
+func expectFindings(t *testing.T, input, kind string, got, want []string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("wrong number of %s for %q. want=%q, got=%q", kind, input, want, got)
+		return
+	}
+	for i, finding := range got {
+		if finding != want[i] {
+			t.Errorf("wrong %s for %q. want=%q, got=%q", kind, input, want[i], finding)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %q", input, p.Errors())
+	}
+	return program
+}
@@ -0,0 +1,333 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type of a Lorikeet value
+type Type interface {
+	String() string
+}
+
+// Basic type identified by its name
+type Basic struct {
+	Name string
+}
+
+func (b *Basic) String() string { return b.Name }
+
+// Basic types
+var (
+	Int       = &Basic{Name: "int"}
+	Float     = &Basic{Name: "float"}
+	String    = &Basic{Name: "string"}
+	Char      = &Basic{Name: "char"}
+	Bytes     = &Basic{Name: "bytes"}
+	Bool      = &Basic{Name: "bool"}
+	Null      = &Basic{Name: "null"}
+	Range     = &Basic{Name: "range"}
+	Generator = &Basic{Name: "generator"}
+	Regex     = &Basic{Name: "regex"}
+	Time      = &Basic{Name: "time"}
+	Duration  = &Basic{Name: "duration"}
+
+	// Any is the type of values that are not known until runtime,
+	// it is compatible with every type
+	Any = &Basic{Name: "any"}
+)
+
+// Array type
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string { return "[" + a.Elem.String() + "]" }
+
+// Hash type
+type Hash struct {
+	Key   Type
+	Value Type
+}
+
+func (h *Hash) String() string {
+	return "{" + h.Key.String() + ": " + h.Value.String() + "}"
+}
+
+// Func type, Annotated marks the parameters whose types were written
+// in the program
+type Func struct {
+	Params    []Type
+	Return    Type
+	Annotated []bool
+}
+
+func (f *Func) annotated(i int) bool {
+	return i < len(f.Annotated) && f.Annotated[i]
+}
+
+func (f *Func) String() string {
+	params := []string{}
+	for _, p := range f.Params {
+		params = append(params, p.String())
+	}
+	return "fn(" + strings.Join(params, ", ") + ") -> " + f.Return.String()
+}
+
+// Enum type of the variants of an enum declaration
+type Enum struct {
+	Name string
+}
+
+func (e *Enum) String() string { return e.Name }
+
+// Builtin type of a builtin function, calls are checked by Check
+type Builtin struct {
+	Name  string
+	Check func(c *Checker, args []Type, line int) Type
+}
+
+func (b *Builtin) String() string { return "builtin " + b.Name }
+
+// Module type of a builtin module, members are read with module.name
+type Module struct {
+	Name    string
+	Members map[string]Type
+}
+
+func (m *Module) String() string { return "module " + m.Name }
+
+// Var is a type variable, Instance is set once it is bound
+type Var struct {
+	ID       int
+	Instance Type
+}
+
+func (v *Var) String() string {
+	if v.Instance != nil {
+		return v.Instance.String()
+	}
+	return fmt.Sprintf("t%d", v.ID)
+}
+
+// Scheme is a type generalized over its variables
+type Scheme struct {
+	Vars []*Var
+	Type Type
+}
+
+// prune follows bound type variables
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.Instance == nil {
+			return t
+		}
+		t = v.Instance
+	}
+}
+
+func occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		return t == v
+	case *Array:
+		return occurs(v, t.Elem)
+	case *Hash:
+		return occurs(v, t.Key) || occurs(v, t.Value)
+	case *Func:
+		for _, p := range t.Params {
+			if occurs(v, p) {
+				return true
+			}
+		}
+		return occurs(v, t.Return)
+	}
+	return false
+}
+
+// unify binds type variables so a and b become the same type, bindings
+// are recorded on the trail so a failed unification can be undone
+func (c *Checker) unify(a, b Type) bool {
+	a, b = prune(a), prune(b)
+
+	if a == Any || b == Any {
+		return true
+	}
+
+	if v, ok := a.(*Var); ok {
+		if a == b {
+			return true
+		}
+		if occurs(v, b) {
+			return false
+		}
+		v.Instance = b
+		c.trail = append(c.trail, v)
+		return true
+	}
+	if _, ok := b.(*Var); ok {
+		return c.unify(b, a)
+	}
+
+	switch a := a.(type) {
+	case *Basic:
+		if f, ok := b.(*Func); ok && a == Generator {
+			return len(f.Params) <= 1
+		}
+		return a == b
+	case *Enum:
+		e, ok := b.(*Enum)
+		return ok && e.Name == a.Name
+	case *Module:
+		return a == b
+	case *Array:
+		e, ok := b.(*Array)
+		return ok && c.unify(a.Elem, e.Elem)
+	case *Hash:
+		h, ok := b.(*Hash)
+		return ok && c.unify(a.Key, h.Key) && c.unify(a.Value, h.Value)
+	case *Builtin:
+		return isCallable(b)
+	case *Func:
+		switch f := b.(type) {
+		case *Builtin:
+			return true
+		case *Func:
+			if len(a.Params) != len(f.Params) {
+				return false
+			}
+			for i := range a.Params {
+				if !c.unify(a.Params[i], f.Params[i]) {
+					return false
+				}
+			}
+			return c.unify(a.Return, f.Return)
+		case *Basic:
+			// Generators are called like functions of at most one argument
+			return f == Generator && len(a.Params) <= 1
+		}
+	}
+
+	return false
+}
+
+// tryUnify unifies a and b, undoing any bindings when they do not match
+func (c *Checker) tryUnify(a, b Type) bool {
+	mark := len(c.trail)
+	if c.unify(a, b) {
+		return true
+	}
+	for _, v := range c.trail[mark:] {
+		v.Instance = nil
+	}
+	c.trail = c.trail[:mark]
+	return false
+}
+
+// join returns the type of a value that is either a or b, types that
+// differ widen to any without binding either, a nil a is no value yet
+func join(a, b Type) Type {
+	if a == nil || same(a, b) {
+		return b
+	}
+	return Any
+}
+
+// same reports whether a and b are already the same type
+func same(a, b Type) bool {
+	a, b = prune(a), prune(b)
+
+	switch a := a.(type) {
+	case *Enum:
+		e, ok := b.(*Enum)
+		return ok && e.Name == a.Name
+	case *Array:
+		e, ok := b.(*Array)
+		return ok && same(a.Elem, e.Elem)
+	case *Hash:
+		h, ok := b.(*Hash)
+		return ok && same(a.Key, h.Key) && same(a.Value, h.Value)
+	case *Func:
+		f, ok := b.(*Func)
+		if !ok || len(a.Params) != len(f.Params) {
+			return false
+		}
+		for i := range a.Params {
+			if !same(a.Params[i], f.Params[i]) {
+				return false
+			}
+		}
+		return same(a.Return, f.Return)
+	}
+	return a == b
+}
+
+func isCallable(t Type) bool {
+	switch prune(t).(type) {
+	case *Func, *Builtin, *Var:
+		return true
+	}
+	return prune(t) == Any
+}
+
+func (c *Checker) newVar() *Var {
+	c.nextVar++
+	return &Var{ID: c.nextVar}
+}
+
+func (c *Checker) instantiate(s *Scheme) Type {
+	if len(s.Vars) == 0 {
+		return s.Type
+	}
+	mapping := make(map[*Var]Type, len(s.Vars))
+	for _, v := range s.Vars {
+		mapping[v] = c.newVar()
+	}
+	return substitute(s.Type, mapping)
+}
+
+func substitute(t Type, mapping map[*Var]Type) Type {
+	switch t := prune(t).(type) {
+	case *Var:
+		if r, ok := mapping[t]; ok {
+			return r
+		}
+		return t
+	case *Array:
+		return &Array{Elem: substitute(t.Elem, mapping)}
+	case *Hash:
+		return &Hash{Key: substitute(t.Key, mapping), Value: substitute(t.Value, mapping)}
+	case *Func:
+		params := make([]Type, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = substitute(p, mapping)
+		}
+		return &Func{Params: params, Return: substitute(t.Return, mapping), Annotated: t.Annotated}
+	default:
+		return t
+	}
+}
+
+// freeVars appends the unbound variables of t
+func freeVars(t Type, vars []*Var) []*Var {
+	switch t := prune(t).(type) {
+	case *Var:
+		for _, v := range vars {
+			if v == t {
+				return vars
+			}
+		}
+		return append(vars, t)
+	case *Array:
+		return freeVars(t.Elem, vars)
+	case *Hash:
+		return freeVars(t.Value, freeVars(t.Key, vars))
+	case *Func:
+		for _, p := range t.Params {
+			vars = freeVars(p, vars)
+		}
+		return freeVars(t.Return, vars)
+	}
+	return vars
+}
@@ -0,0 +1,29 @@
+package vm
+
+import "fmt"
+
+// ErrorKind classifies the errors returned by Run
+type ErrorKind string
+
+// Error kinds
+const (
+	RuntimeError  ErrorKind = "RuntimeError"
+	TypeError     ErrorKind = "TypeError"
+	ZeroDivision  ErrorKind = "ZeroDivision"
+	StackOverflow ErrorKind = "StackOverflow"
+
+	// InternalError is a fault in the VM itself rather than the program
+	InternalError ErrorKind = "InternalError"
+)
+
+// Error is a runtime error of a Lorikeet program
+type Error struct {
+	Kind    ErrorKind
+	Message string
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func newError(kind ErrorKind, format string, a ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
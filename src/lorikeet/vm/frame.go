@@ -10,6 +10,7 @@ type Frame struct {
 	cl          *object.Closure
 	ip          int
 	basePointer int
+	generator   *object.Generator // set while a generator is resumed
 }
 
 // NewFrame inits frame
@@ -5,6 +5,8 @@ import (
 	"lorikeet/code"
 	"lorikeet/compiler"
 	"lorikeet/object"
+	"math/rand"
+	"time"
 )
 
 // StackSize of VM
@@ -36,6 +38,19 @@ type VM struct {
 
 	frames      []*Frame
 	framesIndex int
+
+	// maxCalls stops programs that never finish after that many calls,
+	// zero means no limit
+	maxCalls int
+	calls    int
+
+	// keepErrors pops an error a statement drops like any other value
+	// instead of stopping the program
+	keepErrors bool
+
+	// random is the generator of random, shuffle and the other random
+	// builtins, each VM has its own so seeding one does not change another
+	random *rand.Rand
 }
 
 // New init VM
@@ -57,6 +72,8 @@ func New(bytecode *compiler.Bytecode) *VM {
 
 		frames:      frames,
 		framesIndex: 1,
+
+		random: object.NewRand(time.Now().UnixNano()),
 	}
 }
 
@@ -67,6 +84,29 @@ func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
 	return vm
 }
 
+// SetRand replaces the random number generator, a generator made with
+// a fixed seed makes runs reproducible
+func (vm *VM) SetRand(r *rand.Rand) {
+	vm.random = r
+}
+
+// SetMaxCalls stops the program with a runtime error after n function
+// calls, zero means no limit
+func (vm *VM) SetMaxCalls(n int) {
+	vm.maxCalls = n
+}
+
+// SetKeepErrors lets an error a statement drops be popped like any other
+// value, so a REPL can print it, by default it stops the program
+func (vm *VM) SetKeepErrors(keep bool) {
+	vm.keepErrors = keep
+}
+
+// Rand returns the random number generator of the VM
+func (vm *VM) Rand() *rand.Rand {
+	return vm.random
+}
+
 // StackTop reads from top of VM stack
 func (vm *VM) StackTop() object.Object {
 	if vm.sp == 0 {
@@ -77,7 +117,7 @@ func (vm *VM) StackTop() object.Object {
 
 func (vm *VM) push(o object.Object) error {
 	if vm.sp >= StackSize {
-		return fmt.Errorf("stack overflow")
+		return newError(StackOverflow, "stack overflow")
 	}
 
 	vm.stack[vm.sp] = o
@@ -93,12 +133,53 @@ func (vm *VM) pop() object.Object {
 }
 
 // Run starts the VM main loop and evaluates opcodes
-func (vm *VM) Run() error {
+func (vm *VM) Run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = newError(InternalError, "internal error: %v", r)
+		}
+	}()
+
+	return vm.run(0)
+}
+
+// Call calls fn with args and runs it until it returns, builtins use
+// it to call the functions passed to them
+func (vm *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	base := vm.framesIndex
+
+	err := vm.push(fn)
+	if err != nil {
+		return nil, err
+	}
+	for _, arg := range args {
+		err := vm.push(arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = vm.executeCall(len(args))
+	if err != nil {
+		return nil, err
+	}
+
+	err = vm.run(base)
+	if err != nil {
+		return nil, err
+	}
+
+	return vm.pop(), nil
+}
+
+// run evaluates opcodes until the frames above base have returned
+func (vm *VM) run(base int) error {
 	var ip int
 	var ins code.Instructions
 	var op code.Opcode
 
-	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+	for vm.framesIndex > base &&
+		vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
 		vm.currentFrame().ip++
 
 		ip = vm.currentFrame().ip
@@ -113,14 +194,18 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv:
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpUnion, code.OpIntersect:
 			err := vm.executeBinaryOperation(op)
 			if err != nil {
 				return err
 			}
 
 		case code.OpPop:
-			vm.pop()
+			// An error that a statement drops is not handled by the
+			// program, it stops the program like a runtime error
+			if errObj, ok := vm.pop().(*object.Error); ok && !vm.keepErrors {
+				return newError(RuntimeError, "%s", errObj.Message)
+			}
 
 		case code.OpTrue:
 			err := vm.push(True)
@@ -165,12 +250,35 @@ func (vm *VM) Run() error {
 				vm.currentFrame().ip = pos - 1
 			}
 
+		case code.OpJumpNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if isNull(vm.StackTop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpNotNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if !isNull(vm.StackTop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+
 		case code.OpNull:
 			err := vm.push(Null)
 			if err != nil {
 				return err
 			}
 
+		case code.OpUnset:
+			// A captured variable that is not set yet, set by OpSetFree
+			err := vm.push(nil)
+			if err != nil {
+				return err
+			}
+
 		case code.OpSetGlobal:
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
@@ -181,7 +289,12 @@ func (vm *VM) Run() error {
 			globalIndex := code.ReadUint16(ins[ip+1:])
 			vm.currentFrame().ip += 2
 
-			err := vm.push(vm.globals[globalIndex])
+			global := vm.globals[globalIndex]
+			if global == nil {
+				return newError(RuntimeError, "variable used before it is set")
+			}
+
+			err := vm.push(global)
 			if err != nil {
 				return err
 			}
@@ -200,7 +313,12 @@ func (vm *VM) Run() error {
 
 			frame := vm.currentFrame()
 
-			err := vm.push(vm.stack[frame.basePointer+int(localIndex)])
+			local := vm.stack[frame.basePointer+int(localIndex)]
+			if local == nil {
+				return newError(RuntimeError, "variable used before it is set")
+			}
+
+			err := vm.push(local)
 			if err != nil {
 				return err
 			}
@@ -232,6 +350,57 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpTuple:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			tuple := vm.buildTuple(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+
+			err := vm.push(tuple)
+			if err != nil {
+				return err
+			}
+
+		case code.OpSet:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			set, err := vm.buildSet(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp = vm.sp - numElements
+
+			err = vm.push(set)
+			if err != nil {
+				return err
+			}
+
+		case code.OpUnpack:
+			numNames := int(code.ReadUint8(ins[ip+1:]))
+			vm.currentFrame().ip++
+
+			err := vm.executeUnpack(numNames)
+			if err != nil {
+				return err
+			}
+
+		case code.OpIn:
+			container := vm.pop()
+			element := vm.pop()
+
+			found, ok := object.Contains(container, element)
+			if !ok {
+				return newError(TypeError, "unsupported types for in: %s in %s",
+					element.Type(), container.Type())
+			}
+
+			err := vm.push(nativeBoolToBooleanObject(found))
+			if err != nil {
+				return err
+			}
+
 		case code.OpIndex:
 			index := vm.pop()
 			left := vm.pop()
@@ -241,6 +410,64 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSetIndex(left, index, value)
+			if err != nil {
+				return err
+			}
+
+		case code.OpFreeze:
+			err := vm.push(object.Freeze(vm.pop()))
+			if err != nil {
+				return err
+			}
+
+		case code.OpSlice:
+			end := vm.pop()
+			start := vm.pop()
+			left := vm.pop()
+
+			err := vm.executeSliceExpression(left, start, end)
+			if err != nil {
+				return err
+			}
+
+		case code.OpRange:
+			inclusive := code.ReadUint8(ins[ip+1:]) == 1
+			vm.currentFrame().ip++
+
+			err := vm.executeRangeOperator(inclusive)
+			if err != nil {
+				return err
+			}
+
+		case code.OpMatchVariant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			tag := vm.constants[constIndex].(*object.String).Value
+			variant, ok := vm.pop().(*object.Variant)
+
+			err := vm.push(nativeBoolToBooleanObject(ok && variant.Tag() == tag))
+			if err != nil {
+				return err
+			}
+
+		case code.OpGetField:
+			fieldIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			variant := vm.pop().(*object.Variant)
+
+			err := vm.push(variant.Values[fieldIndex])
+			if err != nil {
+				return err
+			}
+
 		case code.OpCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
@@ -250,10 +477,10 @@ func (vm *VM) Run() error {
 				return err
 			}
 
-		case code.OpLazyCall:
+		case code.OpTailCall:
 			numArgs := code.ReadUint8(ins[ip+1:])
 
-			err := vm.executeLazyCall(int(numArgs))
+			err := vm.executeTailCall(int(numArgs))
 			if err != nil {
 				return err
 			}
@@ -262,6 +489,7 @@ func (vm *VM) Run() error {
 			returnValue := vm.pop()
 
 			frame := vm.popFrame()
+			vm.finishGenerator(frame)
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(returnValue)
@@ -271,6 +499,7 @@ func (vm *VM) Run() error {
 
 		case code.OpReturn:
 			frame := vm.popFrame()
+			vm.finishGenerator(frame)
 			vm.sp = frame.basePointer - 1
 
 			err := vm.push(Null)
@@ -278,6 +507,22 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpYield:
+			value := vm.pop()
+
+			frame := vm.popFrame()
+			gen := frame.generator
+			gen.IP = frame.ip
+			gen.Stack = make([]object.Object, vm.sp-frame.basePointer)
+			copy(gen.Stack, vm.stack[frame.basePointer:vm.sp])
+			gen.Running = false
+			vm.sp = frame.basePointer - 1
+
+			err := vm.push(value)
+			if err != nil {
+				return err
+			}
+
 		case code.OpGetBuiltin:
 			builtinIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
@@ -289,6 +534,15 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpGetModule:
+			moduleIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			err := vm.push(object.Modules[moduleIndex])
+			if err != nil {
+				return err
+			}
+
 		case code.OpClosure:
 			constIndex := code.ReadUint16(ins[ip+1:])
 			numFree := code.ReadUint8(ins[ip+3:])
@@ -299,12 +553,30 @@ func (vm *VM) Run() error {
 				return err
 			}
 
+		case code.OpSetFree:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			freeIndex := code.ReadUint8(ins[ip+2:])
+			vm.currentFrame().ip += 2
+
+			// The closure is not there when the code making it did not run
+			frame := vm.currentFrame()
+			value := vm.pop()
+			closure, ok := vm.stack[frame.basePointer+int(localIndex)].(*object.Closure)
+			if ok {
+				closure.Free[freeIndex] = value
+			}
+
 		case code.OpGetFree:
 			freeIndex := code.ReadUint8(ins[ip+1:])
 			vm.currentFrame().ip++
 
 			currentClosure := vm.currentFrame().cl
-			err := vm.push(currentClosure.Free[freeIndex])
+			free := currentClosure.Free[freeIndex]
+			if free == nil {
+				return newError(RuntimeError, "variable used before it is set")
+			}
+
+			err := vm.push(free)
 			if err != nil {
 				return err
 			}
@@ -336,14 +608,18 @@ func (vm *VM) executeBinaryOperation(op code.Opcode) error {
 	rightType := right.Type()
 
 	switch {
-	case leftType == object.INTEGER && rightType == object.INTEGER:
+	case object.IsInteger(left) && object.IsInteger(right):
 		return vm.executeBinaryIntegerOperation(op, left, right)
 	case leftType == object.STRING && rightType == object.STRING:
 		return vm.executeBinaryStringOperation(op, left, right)
 	case leftType == object.FLOAT && rightType == object.FLOAT:
 		return vm.executeBinaryFloatOperation(op, left, right)
+	case leftType == object.SET && rightType == object.SET:
+		return vm.executeBinarySetOperation(op, left, right)
+	case object.IsTimeOperand(left) || object.IsTimeOperand(right):
+		return vm.executeBinaryTimeOperation(op, left, right)
 	default:
-		return fmt.Errorf("unsupported types for binary operation: %s %s",
+		return newError(TypeError, "unsupported types for binary operation: %s %s",
 			leftType, rightType)
 	}
 }
@@ -352,25 +628,26 @@ func (vm *VM) executeBinaryIntegerOperation(
 	op code.Opcode,
 	left, right object.Object,
 ) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+	var operator string
 
-	var result int64
+	if op == code.OpDiv && object.BigValue(right).Sign() == 0 {
+		return newError(ZeroDivision, "division by zero")
+	}
 
 	switch op {
 	case code.OpAdd:
-		result = leftValue + rightValue
+		operator = "+"
 	case code.OpSub:
-		result = leftValue - rightValue
+		operator = "-"
 	case code.OpMul:
-		result = leftValue * rightValue
+		operator = "*"
 	case code.OpDiv:
-		result = leftValue / rightValue
+		operator = "/"
 	default:
-		return fmt.Errorf("unknown integer operator: %d", op)
+		return newError(TypeError, "unknown integer operator: %s", operatorSymbol(op))
 	}
 
-	return vm.push(&object.Integer{Value: result})
+	return vm.push(object.IntegerOperation(operator, left, right))
 }
 
 func (vm *VM) executeBinaryFloatOperation(
@@ -392,7 +669,7 @@ func (vm *VM) executeBinaryFloatOperation(
 	case code.OpDiv:
 		result = leftValue / rightValue
 	default:
-		return fmt.Errorf("unknown float operator: %d", op)
+		return newError(TypeError, "unknown float operator: %s", operatorSymbol(op))
 	}
 
 	return vm.push(&object.Float{Value: result})
@@ -402,61 +679,71 @@ func (vm *VM) executeComparison(op code.Opcode) error {
 	right := vm.pop()
 	left := vm.pop()
 
-	if left.Type() == object.INTEGER || right.Type() == object.INTEGER {
+	if object.IsInteger(left) && object.IsInteger(right) {
 		return vm.executeIntegerComparison(op, left, right)
 	}
 
-	if right.Type() == object.STRING || right.Type() == object.STRING {
-		return vm.executeStringComparison(op, left, right)
-	}
-
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(right == left))
+		return vm.push(nativeBoolToBooleanObject(object.Equal(left, right)))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(right != left))
-	default:
-		return fmt.Errorf("unknown operator: %d (%s %s)",
-			op, left.Type(), right.Type())
+		return vm.push(nativeBoolToBooleanObject(!object.Equal(left, right)))
+	case code.OpGreaterThan:
+		greater, ok := object.GreaterThan(left, right)
+		if ok {
+			return vm.push(nativeBoolToBooleanObject(greater))
+		}
 	}
+
+	return newError(TypeError, "unknown operator: %s %s %s",
+		left.Type(), operatorSymbol(op), right.Type())
 }
 
 func (vm *VM) executeIntegerComparison(
 	op code.Opcode,
 	left, right object.Object,
 ) error {
-	leftValue := left.(*object.Integer).Value
-	rightValue := right.(*object.Integer).Value
+	cmp := object.CompareIntegers(left, right)
 
 	switch op {
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+		return vm.push(nativeBoolToBooleanObject(cmp == 0))
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
+		return vm.push(nativeBoolToBooleanObject(cmp != 0))
 	case code.OpGreaterThan:
-		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+		return vm.push(nativeBoolToBooleanObject(cmp > 0))
 	default:
-		return fmt.Errorf("unknown operator: %d", op)
+		return newError(TypeError, "unknown operator: %s", operatorSymbol(op))
 	}
 }
 
-func (vm *VM) executeStringComparison(
-	op code.Opcode,
-	left, right object.Object,
-) error {
-	leftValue := left.(*object.String).Value
-	rightValue := right.(*object.String).Value
-
+// operatorSymbol returns the operator an opcode was compiled from, the
+// operands of < are swapped so it is compiled to OpGreaterThan
+func operatorSymbol(op code.Opcode) string {
 	switch op {
+	case code.OpAdd:
+		return "+"
+	case code.OpSub:
+		return "-"
+	case code.OpMul:
+		return "*"
+	case code.OpDiv:
+		return "/"
+	case code.OpGreaterThan:
+		return ">"
 	case code.OpEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue == leftValue))
+		return "=="
 	case code.OpNotEqual:
-		return vm.push(nativeBoolToBooleanObject(rightValue != leftValue))
-	case code.OpGreaterThan:
-		return vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
-	default:
-		return fmt.Errorf("unknown operator: %d", op)
+		return "!="
+	case code.OpUnion:
+		return "|"
+	case code.OpIntersect:
+		return "&"
+	}
+	if def, err := code.Lookup(byte(op)); err == nil {
+		return def.Name
 	}
+	return fmt.Sprintf("%d", op)
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -466,7 +753,19 @@ func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	return False
 }
 
-func (vm *VM) executeLazyCall(numArgs int) error {
+func (vm *VM) executeTailCall(numArgs int) error {
+	cl, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok {
+		// Anything other than a closure is called normally, the
+		// following OpReturnValue returns its result
+		vm.currentFrame().ip++
+		return vm.executeCall(numArgs)
+	}
+	if numArgs != cl.Fn.NumParameters {
+		return newError(TypeError, "wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
 	clargs := vm.stack[vm.sp-1-numArgs : vm.sp]
 	// Clean stack and remove old frame
 	oldFrame := vm.popFrame()
@@ -476,44 +775,35 @@ func (vm *VM) executeLazyCall(numArgs int) error {
 		vm.push(obj)
 	}
 
-	cl, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
-	if !ok {
-		return fmt.Errorf("lazy can only be used on closures")
-	}
-	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
-			cl.Fn.NumParameters, numArgs)
-	}
-
 	frame := NewFrame(cl, vm.sp-numArgs)
-	vm.pushFrame(frame)
 
-	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	// A tail call from a generator into another generator function
+	// delegates to it, anything else finishes the generator
+	if gen := oldFrame.generator; gen != nil {
+		if cl.Fn.Generator {
+			gen.Closure = cl
+			frame.generator = gen
+		} else {
+			vm.finishGenerator(oldFrame)
+		}
+	} else if cl.Fn.Generator {
+		return vm.newGenerator(cl, numArgs)
+	}
 
-	return nil
+	return vm.enterFrame(frame)
 }
 
 func (vm *VM) executeBangOperator() error {
 	operand := vm.pop()
 
-	switch operand {
-	case True:
-		return vm.push(False)
-	case False:
-		return vm.push(True)
-	case Null:
-		return vm.push(True)
-	default:
-		return vm.push(False)
-	}
+	return vm.push(nativeBoolToBooleanObject(!isTruthy(operand)))
 }
 
 func (vm *VM) executeMinusOperator() error {
 	operand := vm.pop()
 
-	if operand.Type() == object.INTEGER {
-		value := operand.(*object.Integer).Value
-		return vm.push(&object.Integer{Value: -value})
+	if object.IsInteger(operand) {
+		return vm.push(object.NegateInteger(operand))
 	}
 
 	if operand.Type() == object.FLOAT {
@@ -521,7 +811,11 @@ func (vm *VM) executeMinusOperator() error {
 		return vm.push(&object.Float{Value: -value})
 	}
 
-	return fmt.Errorf("unsupported type for negation: %s", operand.Type())
+	if operand.Type() == object.DURATION {
+		return vm.executeBinaryTimeOperation(code.OpSub, &object.Duration{}, operand)
+	}
+
+	return newError(TypeError, "unsupported type for negation: %s", operand.Type())
 }
 
 func isTruthy(obj object.Object) bool {
@@ -538,12 +832,17 @@ func isTruthy(obj object.Object) bool {
 	}
 }
 
+func isNull(obj object.Object) bool {
+	_, ok := obj.(*object.Null)
+	return ok
+}
+
 func (vm *VM) executeBinaryStringOperation(
 	op code.Opcode,
 	left, right object.Object,
 ) error {
 	if op != code.OpAdd {
-		return fmt.Errorf("unknown string operator: %d", op)
+		return newError(TypeError, "unknown string operator: %s", operatorSymbol(op))
 	}
 
 	leftValue := left.(*object.String).Value
@@ -552,6 +851,55 @@ func (vm *VM) executeBinaryStringOperation(
 	return vm.push(&object.String{Value: leftValue + rightValue})
 }
 
+func (vm *VM) executeBinarySetOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	var operator string
+	switch op {
+	case code.OpUnion:
+		operator = "|"
+	case code.OpIntersect:
+		operator = "&"
+	case code.OpSub:
+		operator = "-"
+	default:
+		return newError(TypeError, "unknown set operator: %s", operatorSymbol(op))
+	}
+
+	return vm.push(object.SetOperation(operator, left.(*object.Set), right.(*object.Set)))
+}
+
+func (vm *VM) executeBinaryTimeOperation(
+	op code.Opcode,
+	left, right object.Object,
+) error {
+	var operator string
+	switch op {
+	case code.OpAdd:
+		operator = "+"
+	case code.OpSub:
+		operator = "-"
+	case code.OpMul:
+		operator = "*"
+	case code.OpDiv:
+		operator = "/"
+	}
+
+	result, err := object.TimeOperation(operator, left, right)
+	if err == object.ErrDivisionByZero {
+		return newError(ZeroDivision, "division by zero")
+	}
+	if err != nil {
+		return newError(RuntimeError, "%s", err)
+	}
+	if result == nil {
+		return newError(TypeError, "unsupported types for binary operation: %s %s",
+			left.Type(), right.Type())
+	}
+	return vm.push(result)
+}
+
 func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 	elements := make([]object.Object, endIndex-startIndex)
 
@@ -562,47 +910,217 @@ func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
 	return &object.Array{Elements: elements}
 }
 
+func (vm *VM) buildTuple(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+	copy(elements, vm.stack[startIndex:endIndex])
+
+	return &object.Tuple{Elements: elements}
+}
+
 func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
-	hashedPairs := make(map[object.HashKey]object.HashPair)
+	hash := object.NewHash()
 
 	for i := startIndex; i < endIndex; i += 2 {
 		key := vm.stack[i]
 		value := vm.stack[i+1]
 
-		pair := object.HashPair{Key: key, Value: value}
-
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			return nil, newError(TypeError, "unusable as hash key: %s", key.Type())
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) buildSet(startIndex, endIndex int) (object.Object, error) {
+	set := object.NewSet()
+
+	for i := startIndex; i < endIndex; i++ {
+		el, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return nil, newError(TypeError, "unusable as set element: %s", vm.stack[i].Type())
 		}
 
-		hashedPairs[hashKey.HashKey()] = pair
+		set.Add(el)
 	}
 
-	return &object.Hash{Pairs: hashedPairs}, nil
+	return set, nil
+}
+
+// executeUnpack replaces a tuple or array of n elements with its
+// elements, pushed last to first so the first is on top
+func (vm *VM) executeUnpack(n int) error {
+	value := vm.pop()
+
+	var elements []object.Object
+	switch value := value.(type) {
+	case *object.Tuple:
+		elements = value.Elements
+	case *object.Array:
+		elements = value.Elements
+	default:
+		return newError(TypeError, "cannot destructure %s", value.Type())
+	}
+
+	if len(elements) != n {
+		return newError(TypeError, "cannot destructure %s of %d elements into %d names",
+			value.Type(), len(elements), n)
+	}
+
+	for i := n - 1; i >= 0; i-- {
+		err := vm.push(elements[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	iterable, isIterable := left.(object.Iterable)
+
 	switch {
-	case left.Type() == object.ARRAY && index.Type() == object.INTEGER:
-		return vm.executeArrayIndex(left, index)
+	case isIterable && index.Type() == object.INTEGER:
+		return vm.executeIterableIndex(iterable, index)
 	case left.Type() == object.HASH:
 		return vm.executeHashIndex(left, index)
+	case left.Type() == object.MODULE:
+		return vm.executeModuleMember(left.(*object.Module), index)
+	case left.Type() == object.REGEX:
+		return vm.executeRegexMethod(left.(*object.Regex), index)
+	case isIterable:
+		return newError(TypeError, "index must be INTEGER, got %s", index.Type())
 	default:
-		return fmt.Errorf("index operator not supported: %s", left.Type())
+		return newError(TypeError, "index operator not supported: %s", left.Type())
 	}
 }
 
-func (vm *VM) executeArrayIndex(array, index object.Object) error {
-	arrayObject := array.(*object.Array)
-	i := index.(*object.Integer).Value
-	max := int64(len(arrayObject.Elements) - 1)
+func (vm *VM) executeModuleMember(module *object.Module, index object.Object) error {
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError(TypeError, "module member must be STRING, got %s", index.Type())
+	}
+
+	member, ok := module.Members[name.Value]
+	if !ok {
+		return newError(RuntimeError, "module %s has no member %s", module.Name, name.Value)
+	}
+
+	return vm.push(member)
+}
+
+func (vm *VM) executeRegexMethod(regex *object.Regex, index object.Object) error {
+	name, ok := index.(*object.String)
+	if !ok {
+		return newError(TypeError, "regex method must be STRING, got %s", index.Type())
+	}
+
+	method, ok := regex.Method(name.Value)
+	if !ok {
+		return newError(RuntimeError, "regex has no method %s", name.Value)
+	}
+
+	return vm.push(method)
+}
+
+func (vm *VM) executeSetIndex(left, index, value object.Object) error {
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError(TypeError, "index must be INTEGER, got %s", index.Type())
+		}
+		if left.Frozen {
+			return newError(RuntimeError, "cannot change frozen ARRAY")
+		}
+		offset, ok := object.ResolveIndex(left, i.Value)
+		if !ok {
+			return newError(RuntimeError, "index out of range: %d", i.Value)
+		}
+		if object.References(value, left) {
+			return newError(RuntimeError, "cannot store ARRAY inside itself")
+		}
+		left.Elements[offset] = value
+
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(TypeError, "unusable as hash key: %s", index.Type())
+		}
+		if left.Frozen {
+			return newError(RuntimeError, "cannot change frozen HASH")
+		}
+		if object.References(value, left) {
+			return newError(RuntimeError, "cannot store HASH inside itself")
+		}
+		left.Set(key, value)
+
+	default:
+		return newError(TypeError, "index assignment not supported: %s", left.Type())
+	}
+
+	return nil
+}
 
-	if i < 0 || i > max {
+func (vm *VM) executeIterableIndex(iterable object.Iterable, index object.Object) error {
+	i, ok := object.ResolveIndex(iterable, index.(*object.Integer).Value)
+	if !ok {
 		return vm.push(Null)
 	}
 
-	return vm.push(arrayObject.Elements[i])
+	return vm.push(iterable.At(i))
+}
+
+func (vm *VM) executeSliceExpression(left, start, end object.Object) error {
+	iterable, ok := left.(object.Iterable)
+	if !ok {
+		return newError(TypeError, "slice operator not supported: %s", left.Type())
+	}
+
+	lo, err := sliceBound(start, 0)
+	if err != nil {
+		return err
+	}
+
+	hi, err := sliceBound(end, iterable.Len())
+	if err != nil {
+		return err
+	}
+
+	lo, hi = object.SliceBounds(iterable, lo, hi)
+
+	return vm.push(iterable.Slice(lo, hi))
+}
+
+func sliceBound(bound object.Object, fallback int64) (int64, error) {
+	switch bound := bound.(type) {
+	case *object.Integer:
+		return bound.Value, nil
+	case *object.Null:
+		return fallback, nil
+	default:
+		return 0, newError(TypeError, "slice bound must be INTEGER, got %s", bound.Type())
+	}
+}
+
+func (vm *VM) executeRangeOperator(inclusive bool) error {
+	end := vm.pop()
+	start := vm.pop()
+
+	if start.Type() != object.INTEGER || end.Type() != object.INTEGER {
+		return newError(TypeError, "range bounds must be INTEGER, got %s..%s",
+			start.Type(), end.Type())
+	}
+
+	r, ok := object.NewRange(start.(*object.Integer).Value, end.(*object.Integer).Value, inclusive)
+	if !ok {
+		return newError(RuntimeError, "range too large: %s", r.Inspect())
+	}
+	return vm.push(r)
 }
 
 func (vm *VM) executeHashIndex(hash, index object.Object) error {
@@ -610,24 +1128,36 @@ func (vm *VM) executeHashIndex(hash, index object.Object) error {
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return fmt.Errorf("unusable as hash key: %s", index.Type())
+		return newError(TypeError, "unusable as hash key: %s", index.Type())
 	}
 
-	pair, ok := hashObject.Pairs[key.HashKey()]
+	value, ok := hashObject.Get(key)
 	if !ok {
 		return vm.push(Null)
 	}
 
-	return vm.push(pair.Value)
+	return vm.push(value)
 }
 
 func (vm *VM) currentFrame() *Frame {
 	return vm.frames[vm.framesIndex-1]
 }
 
-func (vm *VM) pushFrame(f *Frame) {
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return newError(StackOverflow, "stack overflow")
+	}
+	if vm.maxCalls > 0 {
+		vm.calls++
+		if vm.calls > vm.maxCalls {
+			return newError(RuntimeError, "call limit of %d exceeded", vm.maxCalls)
+		}
+	}
+
 	vm.frames[vm.framesIndex] = f
 	vm.framesIndex++
+
+	return nil
 }
 
 func (vm *VM) popFrame() *Frame {
@@ -637,18 +1167,111 @@ func (vm *VM) popFrame() *Frame {
 
 func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
 	if numArgs != cl.Fn.NumParameters {
-		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
+		return newError(TypeError, "wrong number of arguments: want=%d, got=%d",
 			cl.Fn.NumParameters, numArgs)
 	}
+	if cl.Fn.Generator {
+		return vm.newGenerator(cl, numArgs)
+	}
 
 	frame := NewFrame(cl, vm.sp-numArgs)
-	vm.pushFrame(frame)
+	return vm.enterFrame(frame)
+}
 
-	vm.sp = frame.basePointer + cl.Fn.NumLocals
+// enterFrame pushes a frame for a call and reserves its locals
+func (vm *VM) enterFrame(frame *Frame) error {
+	if frame.basePointer+frame.cl.Fn.NumLocals >= StackSize {
+		return newError(StackOverflow, "stack overflow")
+	}
+
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	// Clear locals left over from earlier calls
+	for i := vm.sp; i < frame.basePointer+frame.cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = frame.basePointer + frame.cl.Fn.NumLocals
 
 	return nil
 }
 
+// newGenerator replaces the call on the stack with a suspended generator
+// holding the arguments as its first locals
+func (vm *VM) newGenerator(cl *object.Closure, numArgs int) error {
+	stack := make([]object.Object, cl.Fn.NumLocals)
+	copy(stack, vm.stack[vm.sp-numArgs:vm.sp])
+	vm.sp = vm.sp - numArgs - 1
+
+	return vm.push(&object.Generator{Closure: cl, IP: -1, Stack: stack})
+}
+
+// resumeGenerator restores the suspended frame of a generator, an
+// optional argument becomes the value of the pending yield
+func (vm *VM) resumeGenerator(gen *object.Generator, numArgs int) error {
+	if numArgs > 1 {
+		return newError(TypeError, "wrong number of arguments: want=0 or 1, got=%d", numArgs)
+	}
+	if gen.Running {
+		return newError(RuntimeError, "generator is already running")
+	}
+
+	var sent object.Object = Null
+	if numArgs == 1 {
+		sent = vm.pop()
+	}
+	if gen.Done {
+		vm.stack[vm.sp-1] = Null
+		return nil
+	}
+	if gen.Next != nil {
+		gen.Running = true
+		result, err := gen.Next(vm)
+		gen.Running = false
+		if err != nil {
+			return err
+		}
+		vm.stack[vm.sp-1] = result
+		return nil
+	}
+
+	frame := NewFrame(gen.Closure, vm.sp)
+	frame.ip = gen.IP
+	frame.generator = gen
+
+	for _, obj := range gen.Stack {
+		err := vm.push(obj)
+		if err != nil {
+			return err
+		}
+	}
+	if gen.IP >= 0 {
+		err := vm.push(sent)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := vm.pushFrame(frame)
+	if err != nil {
+		return err
+	}
+	gen.Running = true
+
+	return nil
+}
+
+// finishGenerator marks the generator of a returning frame as done
+func (vm *VM) finishGenerator(frame *Frame) {
+	if gen := frame.generator; gen != nil {
+		gen.Done = true
+		gen.Running = false
+		gen.Stack = nil
+	}
+}
+
 func (vm *VM) executeCall(numArgs int) error {
 	callee := vm.stack[vm.sp-1-numArgs]
 	switch callee := callee.(type) {
@@ -656,15 +1279,31 @@ func (vm *VM) executeCall(numArgs int) error {
 		return vm.callClosure(callee, numArgs)
 	case *object.Builtin:
 		return vm.callBuiltin(callee, numArgs)
+	case *object.Constructor:
+		return vm.callConstructor(callee, numArgs)
+	case *object.Generator:
+		return vm.resumeGenerator(callee, numArgs)
 	default:
-		return fmt.Errorf("calling non-closure and non-builtin")
+		return newError(TypeError, "calling non-closure and non-builtin")
 	}
 }
 
 func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	args := vm.stack[vm.sp-numArgs : vm.sp]
 
-	result := builtin.Fn(args...)
+	var result object.Object
+	if builtin.CallbackFn != nil {
+		var err error
+		result, err = builtin.CallbackFn(vm, args...)
+		if err != nil {
+			return err
+		}
+	} else {
+		result = builtin.Fn(args...)
+	}
+	if exit, ok := result.(*object.Exit); ok {
+		return exit
+	}
 	vm.sp = vm.sp - numArgs - 1
 
 	if result != nil {
@@ -676,11 +1315,28 @@ func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
 	return nil
 }
 
+func (vm *VM) callConstructor(constructor *object.Constructor, numArgs int) error {
+	if numArgs != len(constructor.Fields) {
+		return newError(TypeError, "wrong number of arguments: want=%d, got=%d",
+			len(constructor.Fields), numArgs)
+	}
+
+	values := make([]object.Object, numArgs)
+	copy(values, vm.stack[vm.sp-numArgs:vm.sp])
+	vm.sp = vm.sp - numArgs - 1
+
+	return vm.push(&object.Variant{
+		Enum:   constructor.Enum,
+		Name:   constructor.Name,
+		Values: values,
+	})
+}
+
 func (vm *VM) pushClosure(constIndex, numFree int) error {
 	constant := vm.constants[constIndex]
 	function, ok := constant.(*object.CompiledFunction)
 	if !ok {
-		return fmt.Errorf("not a function: %+v", constant)
+		return newError(RuntimeError, "not a function: %+v", constant)
 	}
 
 	free := make([]object.Object, numFree)
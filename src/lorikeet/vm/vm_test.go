@@ -1,13 +1,17 @@
 package vm
 
 import (
+	"bufio"
 	"fmt"
 	"lorikeet/ast"
 	"lorikeet/compiler"
 	"lorikeet/lexer"
 	"lorikeet/object"
 	"lorikeet/parser"
+	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
 type vmTestCase struct {
@@ -89,6 +93,15 @@ func TestBooleanExpressions(t *testing.T) {
 		{"!!false", false},
 		{"!!5", true},
 		{"!(if (false) { 5; })", true},
+		{`!strings.contains("a", "b")`, true},
+		{`!strings.contains("a", "a")`, false},
+		{"1.5 < 2.5", true},
+		{"math.nan < 1.0", false},
+		{"math.nan > 1.0", false},
+		{"1.0 < math.nan", false},
+		{"math.nan > math.nan", false},
+		{"math.nan == math.nan", false},
+		{"math.nan != math.nan", true},
 	}
 
 	runVMTests(t, tests)
@@ -106,6 +119,9 @@ func TestConditionals(t *testing.T) {
 		{"if (1 > 2) { 10 }", Null},
 		{"if (false) { 10 }", Null},
 		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+		{"if (true) { }", Null},
+		{"if (true) { let x = 1; }", Null},
+		{"if (false) { 10 } else { let x = 1; }", Null},
 	}
 
 	runVMTests(t, tests)
@@ -131,6 +147,44 @@ func TestStringExpressions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestUnicodeStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("héllo")`, 5},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[1:3]`, "él"},
+		{`"🐵🙈"[1]`, "🙈"},
+		{`"é" > "z"`, true},
+		{"int('é')", 233},
+		{"string(char(233)) + \"!\"", "é!"},
+		{`string(char("é"))`, "é"},
+		{"'a' < 'b'", true},
+		{"'a' == char(97)", true},
+		{"'\\n' == char(10)", true},
+		{`'é' in "héllo"`, true},
+		{"{'a': 1}['a']", 1},
+		{`len("a\nb")`, 3},
+		{`len("\u00e9")`, 1},
+		{`"é" == "\u00e9"`, true},
+		{`"a\nb"[1]`, "\n"},
+		{`'\n' in "a\nb"`, true},
+		{`len('\n')`, 1},
+		{`len(bytes("é"))`, 2},
+		{`len(bytes("a\nb"))`, 3},
+		{`bytes("é")[0]`, 195},
+		{`string(bytes("héllo")[1:3])`, "é"},
+		{"string(bytes([104, 105]))", "hi"},
+		{`bytes("ab") == bytes([97, 98])`, true},
+		{`bytes("ab") < bytes("b")`, true},
+		{"string(bytes([255]))", &object.Error{Message: "argument to `string` is not valid UTF-8"}},
+		{"bytes([256])", &object.Error{Message: "argument to `bytes` must be an ARRAY of integers 0 to 255, got 256 at 0"}},
+		{`char("ab")`, &object.Error{Message: "argument to `char` must be a single character, got \"ab\""}},
+		{"char(-1)", &object.Error{Message: "argument to `char` is not a code point, got -1"}},
+	}
+
+	runVMTests(t, tests)
+}
+
 func TestArrayLiterals(t *testing.T) {
 	tests := []vmTestCase{
 		{"[]", []int{}},
@@ -144,20 +198,20 @@ func TestArrayLiterals(t *testing.T) {
 func TestHashLiterals(t *testing.T) {
 	tests := []vmTestCase{
 		{
-			"{}", map[object.HashKey]int64{},
+			"{}", map[object.Hashable]int64{},
 		},
 		{
 			"{1: 2, 2: 3}",
-			map[object.HashKey]int64{
-				(&object.Integer{Value: 1}).HashKey(): 2,
-				(&object.Integer{Value: 2}).HashKey(): 3,
+			map[object.Hashable]int64{
+				&object.Integer{Value: 1}: 2,
+				&object.Integer{Value: 2}: 3,
 			},
 		},
 		{
 			"{1 + 1: 2 * 2, 3 + 3: 4 * 4}",
-			map[object.HashKey]int64{
-				(&object.Integer{Value: 2}).HashKey(): 4,
-				(&object.Integer{Value: 6}).HashKey(): 16,
+			map[object.Hashable]int64{
+				&object.Integer{Value: 2}: 4,
+				&object.Integer{Value: 6}: 16,
 			},
 		},
 	}
@@ -172,7 +226,14 @@ func TestIndexExpressions(t *testing.T) {
 		{"[[1, 1, 1]][0][0]", 1},
 		{"[][0]", Null},
 		{"[1, 2, 3][99]", Null},
-		{"[1][-1]", Null},
+		{"[1][-1]", 1},
+		{"[1, 2, 3][-3]", 1},
+		{"[1, 2, 3][-4]", Null},
+		{`"abc"[1]`, "b"},
+		{`"abc"[-1]`, "c"},
+		{"(1..4)[0]", 1},
+		{"(1..4)[-1]", 3},
+		{"(1..4)[3]", Null},
 		{"{1: 1, 2: 2}[1]", 1},
 		{"{1: 1, 2: 2}[2]", 2},
 		{"{1: 1}[0]", Null},
@@ -182,6 +243,173 @@ func TestIndexExpressions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestRanges(t *testing.T) {
+	tests := []vmTestCase{
+		{"1..4", &object.Range{Start: 1, End: 4}},
+		{"1..=4", &object.Range{Start: 1, End: 4, Inclusive: true}},
+		{"1 + 1..2 * 3", &object.Range{Start: 2, End: 6}},
+		{"len(1..4)", 3},
+		{"len(1..=4)", 4},
+		{"len(4..1)", 0},
+		{"(1..=4)[3]", 4},
+		{"len(-1..9223372036854775806)", 9223372036854775807},
+		{"(5..9223372036854775807)[0]", 5},
+		{"(5..9223372036854775807)[-1]", 9223372036854775806},
+		{"len((-9223372036854775807 - 1)..-1)", 9223372036854775807},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestSliceExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3, 4][:2]", []int{1, 2}},
+		{"[1, 2, 3, 4][2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:]", []int{1, 2, 3, 4}},
+		{"[1, 2, 3, 4][-2:]", []int{3, 4}},
+		{"[1, 2, 3, 4][:-1]", []int{1, 2, 3}},
+		{"[1, 2, 3, 4][3:1]", []int{}},
+		{"[1, 2, 3, 4][-10:10]", []int{1, 2, 3, 4}},
+		{"let a = [1, 2, 3]; let i = 1; a[i:i + 1]", []int{2}},
+		{`"hello world"[:5]`, "hello"},
+		{`"hello world"[-5:]`, "world"},
+		{"(0..10)[2:5]", &object.Range{Start: 2, End: 5}},
+		{"(0..=10)[-2:]", &object.Range{Start: 9, End: 11}},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestRangeAndSliceErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{`1.."a"`, "range bounds must be INTEGER, got INTEGER..STRING"},
+		{`[1, 2]["a":]`, "slice bound must be INTEGER, got STRING"},
+		{"1[0:1]", "slice operator not supported: INTEGER"},
+		{"[1, 2][9223372036854775807 + 1]", "index must be INTEGER, got BIGINT"},
+		{`"ab"[-9223372036854775807 - 2]`, "index must be INTEGER, got BIGINT"},
+		{"len(0..=9223372036854775807)", "range too large: 0..=9223372036854775807"},
+		{"len(-9223372036854775807..9223372036854775807)", "range too large: -9223372036854775807..9223372036854775807"},
+		{"(-5..9223372036854775807)[0]", "range too large: -5..9223372036854775807"},
+		{"(-9223372036854775807 - 1)..=9223372036854775807", "range too large: -9223372036854775808..=9223372036854775807"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Fatalf("expected VM error but resulted in none.")
+		}
+
+		if err.Error() != tt.expected {
+			t.Fatalf("wrong VM error: want=%q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestNullSafeOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{"1 ?? 2", 1},
+		{"[][0] ?? 2", 2},
+		{"[][0] ?? [][1] ?? 3", 3},
+		{"false ?? 1", false},
+		{`let h = {"a": {"b": 5}}; h?.a?.b`, 5},
+		{`let h = {"a": {"b": 5}}; h?.c?.b`, Null},
+		{`let h = {"a": {"b": 5}}; h?.c?.b ?? 0`, 0},
+		{`let h = {"a": [1, 2]}; h?["a"]?[-1]`, 2},
+		{`let h = {}; h?["a"]?[0]`, Null},
+		{`let h = {}; h["a"]?[1:]`, Null},
+		{`let h = {"a": [1, 2]}; h["a"]?[1:]`, []int{2}},
+		{`let f = fn() { say("called"); 1 }; 2 ?? f()`, 2},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestEnumsAndMatch(t *testing.T) {
+	shapes := `
+	enum Shape { Circle(r), Rect(w, h), Empty }
+	let area = fn(s) {
+		match (s) {
+			Circle(r) => 3 * r * r,
+			Rect(w, h) => { let a = w * h; a },
+			Empty => 0,
+		}
+	};
+	`
+
+	tests := []vmTestCase{
+		{shapes + "area(Circle(2))", 12},
+		{shapes + "area(Rect(2, 5))", 10},
+		{shapes + "area(Empty)", 0},
+		{shapes + "area(1)", Null},
+		{shapes + "Rect(2, 5)", &object.Variant{Enum: "Shape", Name: "Rect",
+			Values: []object.Object{&object.Integer{Value: 2}, &object.Integer{Value: 5}}}},
+		{shapes + "Empty", &object.Variant{Enum: "Shape", Name: "Empty"}},
+		{shapes + "match (Circle(1)) { Rect(w, h) => w, _ => 7 }", 7},
+		{shapes + "match (Circle(1)) { Empty => 1 }", Null},
+		{shapes + "match (Circle(5)) { Circle(r) => fn() { r } }()", 5},
+		{shapes + "let r = 1; match (Circle(5)) { Circle(r) => r }; r", 1},
+		{`match (2) { 1 => "one", 2 => "two" }`, "two"},
+		{`match ("b") { "a" => 1, _ => 2 }`, 2},
+		{`match (1) { 1 => {} }`, Null},
+		{`match (match (1) { 1 => 2 }) { 2 => 3 }`, 3},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestGenerators(t *testing.T) {
+	naturals := `
+	fn* naturals(i) { yield i; $naturals(i + 1) }
+	let collect = fn(it, n, acc) {
+		if (n == 0) { return acc; }
+		$collect(it, n - 1, push(acc, it()))
+	};
+	let take = fn(n, it) { collect(it, n, []) };
+	`
+
+	tests := []vmTestCase{
+		{`let g = fn*() { yield 1; yield 2 }; let it = g(); it()`, 1},
+		{`let g = fn*() { yield 1; yield 2 }; let it = g(); it(); it()`, 2},
+		{`let g = fn*() { yield 1; yield 2 }; let it = g(); it(); it(); it()`, Null},
+		{`let g = fn*() { yield 1; 3 }; let it = g(); it(); it()`, 3},
+		{`let g = fn*() { yield 1; 3 }; let it = g(); it(); it(); it()`, Null},
+		{`let g = fn*() { yield 1 }; let it = g(); it(); done(it)`, false},
+		{`let g = fn*() { yield 1 }; let it = g(); it(); it(); done(it)`, true},
+		{`let g = fn*(a, b) { let c = a + b; yield c; yield c * 2 }; let it = g(1, 2); it(); it()`, 6},
+		{`let g = fn*() { let x = yield 1; yield x * 2 }; let it = g(); it(); it(21)`, 42},
+		{`let g = fn*() { yield 1 + (yield 2) }; let it = g(); it(); it(10)`, 11},
+		{`let g = fn*(n) { yield n; yield n + 1 }; let a = g(1); let b = g(10); a(); b(); [a(), b()]`, []int{2, 11}},
+		{`let g = fn*() { let f = fn(x) { x * 2 }; yield f(2) }; g()()`, 4},
+		{naturals + "take(5, naturals(0))", []int{0, 1, 2, 3, 4}},
+		{naturals + "len(take(5000, naturals(0)))", 5000},
+		{naturals + "naturals(3) |> take(2)", []int{3, 4}},
+		{`fn h() { 9 } fn* g() { yield 1; $h() } let it = g(); it(); let v = it(); if (done(it)) { v }`, 9},
+		{`fn* g() { yield 1 } fn f() { $g() } f()()`, 1},
+		{naturals + "naturals(1) |> map(_, fn(x) { x * 10 }) |> take(3)", []int{10, 20, 30}},
+		{naturals + "naturals(1) |> filter(_, fn(x) { x / 3 * 3 == x }) |> take(3)", []int{3, 6, 9}},
+		{naturals + "naturals(1) |> filter(_, fn(x) { x > 2 }) |> map(_, fn(x) { x * x }) |> take(2)", []int{9, 16}},
+		{naturals + "let mut calls = 0; let it = map(naturals(0), fn(x) { calls = calls + 1; x }); it(); it(); calls", 2},
+		{`let it = map(fn*() { yield 1; 5 }(), fn(x) { x * 2 }); [it(), it()]`, []int{2, 5}},
+		{`let it = map(fn*() { yield 1; 5 }(), fn(x) { x * 2 }); it(); it(); done(it)`, true},
+		{`let it = map(fn*() { yield 1 }(), fn(x) { x * 2 }); it(); it(); it()`, Null},
+		{`let it = filter(fn*() { yield 1; yield 2 }(), fn(x) { x > 1 }); it()`, 2},
+		{`let it = filter(fn*() { yield 1; yield 2 }(), fn(x) { x > 1 }); it(); done(it)`, false},
+		{`let it = filter(fn*() { yield 1; yield 2 }(), fn(x) { x > 1 }); it(); it(); done(it)`, true},
+	}
+
+	runVMTests(t, tests)
+}
+
 func TestCallingFunctionsWithBindings(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -318,6 +546,22 @@ func TestCallingFunctionsWithWrongArguments(t *testing.T) {
 			input:    `fn(a, b) { a + b; }(1);`,
 			expected: `wrong number of arguments: want=2, got=1`,
 		},
+		{
+			input:    `enum E { A(a, b) } A(1);`,
+			expected: `wrong number of arguments: want=2, got=1`,
+		},
+		{
+			input:    `fn*(a) { yield a; }();`,
+			expected: `wrong number of arguments: want=1, got=0`,
+		},
+		{
+			input:    `fn*() { yield 1; }()(1, 2);`,
+			expected: `wrong number of arguments: want=0 or 1, got=2`,
+		},
+		{
+			input:    `let mut it = 0; it = fn*() { yield it() }(); it();`,
+			expected: `generator is already running`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -346,6 +590,9 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len(1..11)`, 10},
+		{`let e = len(1); 1`, 1},
+		{`let e = len(1); len([e])`, 1},
 		{
 			`len(1)`,
 			&object.Error{
@@ -387,6 +634,691 @@ func TestBuiltinFunctions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestHigherOrderBuiltins(t *testing.T) {
+	tests := []vmTestCase{
+		{"map([1, 2, 3], fn(x) { x * 2 })", []int{2, 4, 6}},
+		{"map(1..4, fn(x) { x * x })", []int{1, 4, 9}},
+		{`map(["a", "bb"], len)`, []int{1, 2}},
+		{"map([], fn(x) { x })", []int{}},
+		{"let n = 10; map([1, 2], fn(x) { x + n })", []int{11, 12}},
+		{"filter([1, 2, 3, 4], fn(x) { x > 2 })", []int{3, 4}},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)", 16},
+		{"reduce([], fn(acc, x) { acc + x }, 0)", 0},
+		{"each([1, 2], fn(x) { x })", Null},
+		{"find([1, 2, 3], fn(x) { x > 1 })", 2},
+		{"find([1, 2, 3], fn(x) { x > 5 })", Null},
+		{"any([1, 2], fn(x) { x > 1 })", true},
+		{"any([], fn(x) { true })", false},
+		{"all([1, 2], fn(x) { x > 1 })", false},
+		{"all([], fn(x) { false })", true},
+		{"sort_by([3, 1, 2], fn(x) { x })", []int{1, 2, 3}},
+		{"sort_by([3, 1, 2], fn(x) { -x })", []int{3, 2, 1}},
+		{"sort_by([[2, 1], [1, 2], [2, 0]], fn(p) { p[0] })[1]", []int{2, 1}},
+		{"fn f(n) { if (n == 0) { return 0; } reduce([n], fn(acc, x) { acc + f(x - 1) }, 1) } f(100)", 100},
+		{"[1, 2, 3] |> filter(_, fn(x) { x != 2 }) |> map(_, fn(x) { x * 10 })", []int{10, 30}},
+		{"(1..10) |> filter(_, fn(x) { x > 7 })", []int{8, 9}},
+		{
+			"map(1, len)",
+			&object.Error{Message: "argument to `map` must be iterable, got INTEGER"},
+		},
+		{
+			"filter([1], 1)",
+			&object.Error{Message: "argument to `filter` must be a function, got INTEGER"},
+		},
+		{
+			"map(fn*() { yield 1 }(), 1)",
+			&object.Error{Message: "argument to `map` must be a function, got INTEGER"},
+		},
+		{
+			"reduce([1], fn(a, b) { a })",
+			&object.Error{Message: "wrong number of arguments. got=2, want=3"},
+		},
+		{
+			`sort_by([1, "a"], fn(x) { x })`,
+			&object.Error{Message: "keys of `sort_by` cannot be compared, got STRING and INTEGER"},
+		},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestStringsModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`strings.split("a,b,,c", ",")`, "[a, b, , c]"},
+		{`strings.split("hé", "")`, "[h, é]"},
+		{`strings.join(["a", "b"], ", ")`, "a, b"},
+		{`strings.join(strings.chars("hé"), "-")`, "h-é"},
+		{`strings.trim("  hi ")`, "hi"},
+		{`strings.trim("xhix", "x")`, "hi"},
+		{`strings.trim_left("  hi ")`, "hi "},
+		{`strings.trim_right("  hi ")`, "  hi"},
+		{`strings.replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`strings.contains("héllo", "él")`, "true"},
+		{`strings.starts_with("héllo", "hé")`, "true"},
+		{`strings.ends_with("héllo", "x")`, "false"},
+		{`strings.index_of("héllo", "l")`, "2"},
+		{`strings.index_of("héllo", "z")`, "-1"},
+		{`strings.upper("héllo")`, "HÉLLO"},
+		{`strings.lower("ÀB")`, "àb"},
+		{`strings.repeat("ab", 3)`, "ababab"},
+		{`strings.repeat("ab", 0)`, ""},
+		{`strings.pad_left("é", 3)`, "  é"},
+		{`strings.pad_left("7", 3, '0')`, "007"},
+		{`strings.pad_right("é", 3, "*")`, "é**"},
+		{`strings.pad_right("long", 2)`, "long"},
+		{`strings.chars("hé")`, "[h, é]"},
+		{"strings.lines(\"a\r\nb\n\")", "[a, b]"},
+		{`strings.lines("")`, "[]"},
+		{`strings.lines("a\nb\\c")`, `[a, b\c]`},
+		{`strings.upper("\t") == "\t"`, "true"},
+		{`strings.upper("a\nb")`, "A\nB"},
+		{`strings.lower("\U0001F600")`, "😀"},
+		{`strings.lower("\u00c9\t")`, "é\t"},
+		{`len(strings.chars("a\nb"))`, "3"},
+		{`strings.chars("\t\u00e9")[1] == 'é'`, "true"},
+		{`strings.split("a\tb", "\t")`, "[a, b]"},
+		{`strings.index_of("\u00e9\tx", "x")`, "2"},
+		{`strings.trim("\t hi\n")`, "hi"},
+		{`strings.pad_left("\u00e9", 3, '\t')`, "\t\té"},
+		{`map(["a", "b"], strings.upper)`, "[A, B]"},
+		{`fn() { strings.lower("A") }()`, "a"},
+		{`let m = strings; m.upper("a")`, "A"},
+		{"strings", "module strings"},
+		{`strings.split(1, ",")`, "Error: argument 1 to `strings.split` must be STRING, got INTEGER"},
+		{`strings.join([1], ",")`, "Error: argument 1 to `strings.join` must be an ARRAY of STRING, got INTEGER at 0"},
+		{`strings.repeat("a", -1)`, "Error: argument 2 to `strings.repeat` must be a non-negative INTEGER, got -1"},
+		{`strings.repeat("ab", 9223372036854775807)`, "Error: result of `strings.repeat` is too long"},
+		{`strings.pad_left("a", 3, "ab")`, "Error: argument 3 to `strings.pad_left` must be a single character, got ab"},
+		{`strings.upper()`, "Error: wrong number of arguments. got=0, want=1"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format("%-10s|%6.2f", "apple", 1.5)`, "apple     |  1.50"},
+		{`format("%5s|%.2s|%-3c|", "héllo", "héllo", 'é')`, "héllo|hé|é  |"},
+		{`format("%d %05d %+d %x %X %b %o", 3, 42, 7, 255, 255, 5, 8)`, "3 00042 +7 ff FF 101 10"},
+		{`format("%d", math.pow(2, 70))`, "1180591620717411303424"},
+		{`format("%x %x", "hi", bytes("hi"))`, "6869 6869"},
+		{`format("%f %.1f %e %g", 2, 2.25, 1500.0, 0.5)`, "2.000000 2.2 1.500000e+03 0.5"},
+		{`format("%c%c %t", 'a', 98, false)`, "ab false"},
+		{`format("%q %q", "a b", 'x')`, `"a b" 'x'`},
+		{`format("%v %v %v", [1, "a"], {"k": 2}, true)`, "[1, a] {k: 2} true"},
+		{`format("%v %v %v", (1, 'c'), #{1}, 1..3)`, "(1, c) #{1} 1..3"},
+		{`format("%-8v|", [1])`, "[1]     |"},
+		{`format("100%%")`, "100%"},
+		{`format("")`, ""},
+		{`format("%d", "a")`, "Error: %d in `format` needs INTEGER, got STRING for argument 2"},
+		{`format("%s %6.2f", "a", "b")`, "Error: %6.2f in `format` needs FLOAT or INTEGER, got STRING for argument 3"},
+		{`format("%s", [1])`, "Error: %s in `format` needs STRING or CHAR, got ARRAY for argument 2"},
+		{`format("%t", 1)`, "Error: %t in `format` needs BOOLEAN, got INTEGER for argument 2"},
+		{`format("%c", -1)`, "Error: %c in `format` needs CHAR or an INTEGER code point, got INTEGER for argument 2"},
+		{`format("%d %d", 1)`, "Error: missing argument for %d in `format`"},
+		{`format("%d", 1, 2)`, "Error: too many arguments to `format`, format uses 1, got 2"},
+		{`format("%y", 1)`, "Error: unknown verb %y in `format`"},
+		{`format("50%")`, "Error: format of `format` ends in an incomplete verb: %"},
+		{`format("%5%")`, "Error: verb %5% in `format` takes no flags, width or precision"},
+		{`format("%2000000d", 1)`, "Error: width in `format` is too large: %2000000"},
+		{`format(1)`, "Error: argument 1 to `format` must be STRING, got INTEGER"},
+		{`format()`, "Error: wrong number of arguments to `format`. got=0, want=1 or more"},
+		{`sayf("%d", "a")`, "Error: %d in `sayf` needs INTEGER, got STRING for argument 2"},
+		{`sayf("")`, "null"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestRegex(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`re("\\d+")`, `re("\\d+")`},
+		{`re("(\\d+)-(\\d+)").match("a 12-34 b")`, "true"},
+		{`re("^\\d+$").match("12a")`, "false"},
+		{`re("\\d+").find("a 12 b 34")`, "12"},
+		{`re("\\d+").find("none")`, "null"},
+		{`re("\\d+").find_all("a 12 b 34")`, "[12, 34]"},
+		{`re("x").find_all("abc")`, "[]"},
+		{`re("(\\d+)-(\\d+)").captures("x 1-2 y 3-4")`, "[1-2, 1, 2]"},
+		{`re("(a)|(b)").captures("b")`, "[b, null, b]"},
+		{`re("(a)").captures("c") ?? "none"`, "none"},
+		{`re("(?P<year>\\d{4})-(?P<month>\\d\\d)(-(?P<day>\\d\\d))?").named("on 2024-05")`, "{year: 2024, month: 05, day: null}"},
+		{`re("(?P<word>\\w+)").named("hi there").word`, "hi"},
+		{`re("(\\d+)-(\\d+)").replace("1-2 and 3-4", "$2-$1")`, "2-1 and 4-3"},
+		{`re("(?P<n>\\d)").replace("a1b2", "<${n}>")`, "a<1>b<2>"},
+		{`re("(\\d+)-(\\d+)").replace("1-2 and 3-4", fn(m) { string(int(m[1]) + int(m[2])) })`, "3 and 7"},
+		{`re("[aeiou]").replace("hello", strings.upper)`, "Error: argument 1 to `strings.upper` must be STRING, got ARRAY"},
+		{`re("o").replace("foo", fn(m) { 1 })`, "Error: function passed to `regex.replace` must return STRING, got INTEGER"},
+		{`re("o").replace("foo", 1)`, "Error: argument 2 to `regex.replace` must be STRING or a function, got INTEGER"},
+		{`re("\\s*,\\s*").split("a , b,c")`, "[a, b, c]"},
+		{`re("\t").replace("a\tb", "\n")`, "a\nb"},
+		{`re("\n").split("a\nb")`, "[a, b]"},
+		{`re("é+").find("hééllo")`, "éé"},
+		{`let m = re("a").match; m("cat")`, "true"},
+		{`re("a+") == re("a+")`, "true"},
+		{`map(["ab", "cd"], re("[a-c]").match)`, "[true, true]"},
+		{`re("(")`, "Error: invalid regex \"(\": missing closing ): `(`"},
+		{`re(1)`, "Error: argument to `re` must be STRING, got INTEGER"},
+		{`re("a").match(1)`, "Error: argument 1 to `regex.match` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestArgsAndEnv(t *testing.T) {
+	object.Args = []string{"a", "b c"}
+	defer func() { object.Args = nil }()
+	t.Setenv("LORIKEET_TEST", "yes")
+	os.Unsetenv("LORIKEET_UNSET")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"args()", "[a, b c]"},
+		{"len(args())", "2"},
+		{`env("LORIKEET_TEST")`, "yes"},
+		{`env("LORIKEET_UNSET") ?? "none"`, "none"},
+		{"args(1)", "Error: wrong number of arguments. got=1, want=0"},
+		{"env(1)", "Error: argument to `env` must be STRING, got INTEGER"},
+		{"exit(256)", "Error: argument to `exit` must be an INTEGER from 0 to 255, got 256"},
+		{`exit("a")`, "Error: argument to `exit` must be an INTEGER from 0 to 255, got a"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"exit(3); 1", 3},
+		{"exit()", 0},
+		{"let f = fn() { exit(4); 1 }; f() + 1", 4},
+		{"map([1, 2], fn(x) { if (x == 2) { exit(5); } x })", 5},
+		{"fn* g() { exit(6); yield 1; } let it = g(); it()", 6},
+		{"let x = exit(7); 1", 7},
+		{"say(exit(8))", 8},
+		{`re("a").replace("a", fn(m) { exit(9) })`, 9},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		exit, ok := err.(*object.Exit)
+		if !ok {
+			t.Errorf("wrong error for %q. want=Exit, got=%T (%v)", tt.input, err, err)
+			continue
+		}
+		if exit.Code != tt.expected {
+			t.Errorf("wrong exit code for %q. want=%d, got=%d", tt.input, tt.expected, exit.Code)
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	start := time.Date(2024, time.January, 2, 3, 4, 5, 0, time.UTC)
+	defer func() { object.CurrentClock = object.SystemClock{} }()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"now()", "2024-01-02T03:04:05Z"},
+		{"let t = now(); sleep(1500); since(t)", "1.5s"},
+		{"let t = now(); sleep(time.minutes(2)); now() - t", "2m0s"},
+		{"let t = now(); sleep(0.5); since(t) == time.ms(0.5)", "true"},
+		{"now() + time.hours(1)", "2024-01-02T04:04:05Z"},
+		{"time.hours(1) + now()", "2024-01-02T04:04:05Z"},
+		{"now() - time.seconds(5)", "2024-01-02T03:04:00Z"},
+		{"now() + time.ms(1) > now()", "true"},
+		{"time.seconds(90)", "1m30s"},
+		{"time.seconds(1) + time.ms(250)", "1.25s"},
+		{"time.seconds(1) - time.minutes(1)", "-59s"},
+		{"-time.seconds(1)", "-1s"},
+		{"time.seconds(3) * 2", "6s"},
+		{"2 * time.seconds(3)", "6s"},
+		{"time.seconds(3) * 0.5", "1.5s"},
+		{"time.seconds(3) / 2", "1.5s"},
+		{"time.seconds(3) / time.ms(1)", "3000"},
+		{"time.ms(1) < time.seconds(1)", "true"},
+		{"time.ms(1000) == time.seconds(1)", "true"},
+		{"sort_by([time.hours(1), time.ms(5)], fn(d) { d })", "[5ms, 1h0m0s]"},
+		{"string(time.minutes(1.5))", "1m30s"},
+		{`time.format(now(), "%Y-%m-%d %H:%M:%S.%L")`, "2024-01-02 03:04:05.000"},
+		{`time.format(now(), "%a %A %b %B %e %j %I%p %y %z %Z %%")`,
+			"Tue Tuesday Jan January  2 002 03AM 24 +0000 UTC %"},
+		{`time.format(now(), "Monday %d")`, "Monday 02"},
+		{"time.format(now())", "2024-01-02T03:04:05Z"},
+		{`time.parse("2021-03-04 05:06:07.250", "%Y-%m-%d %H:%M:%S.%L")`, "2021-03-04T05:06:07.25Z"},
+		{`time.parse("2021-03-04T05:06:07+02:00")`, "2021-03-04T05:06:07+02:00"},
+		{`time.parse("4 Mar 2021", "%e %b %Y") < now()`, "true"},
+		{"time.unix(now())", "1704164645"},
+		{"time.from_unix(0)", "1970-01-01T00:00:00Z"},
+		{"now(1)", "Error: wrong number of arguments. got=1, want=0"},
+		{"sleep(-1)", "Error: argument to `sleep` must not be negative, got -1"},
+		{`sleep("1")`, "Error: argument 1 to `sleep` must be DURATION, INTEGER or FLOAT, got STRING"},
+		{"since(1)", "Error: argument 1 to `since` must be TIME, got INTEGER"},
+		{"time.ms(time.ms(1))", "Error: argument 1 to `time.ms` must be INTEGER or FLOAT, got DURATION"},
+		{"time.ms(math.inf)", "Error: argument 1 to `time.ms` is too large: +Inf"},
+		{`time.format(now(), "%Q")`, "Error: unknown directive %Q in `time.format`"},
+		{`time.format(now(), "%")`, "Error: layout of `time.format` ends in an incomplete directive: %"},
+		{`time.parse("2021", "%L")`, "Error: %L in `time.parse` must follow a ."},
+		{`time.parse("soon", "%Y")`, `Error: cannot parse "soon" as "%Y"`},
+		{`time.parse("soon")`, `Error: cannot parse "soon" as RFC 3339`},
+	}
+
+	for _, tt := range tests {
+		object.CurrentClock = object.NewFakeClock(start)
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = random(); x > -0.1 == (x < 1.0)", "true"},
+		{"let n = random_int(3, 5); n > 2 == (n < 6)", "true"},
+		{"random_int(4, 4)", "4"},
+		{"random_int(-9223372036854775807 - 1, -9223372036854775807 - 1)", "-9223372036854775808"},
+		{"let n = random_int(-9223372036854775807 - 1, 9223372036854775807); n == n", "true"},
+		{"let n = random_int(-1, 9223372036854775807); n > -2", "true"},
+		{`choice(["a"])`, "a"},
+		{"let c = choice((1, 2)); c in #{1, 2}", "true"},
+		{"choice(7..=7)", "7"},
+		{"sort_by(shuffle([3, 1, 2, 1]), fn(x) { x })", "[1, 1, 2, 3]"},
+		{"shuffle(1..1)", "[]"},
+		{"seed(1); let a = [random(), random_int(0, 1000), shuffle(1..10)]; seed(1); a == [random(), random_int(0, 1000), shuffle(1..10)]", "true"},
+		{"random(1)", "Error: wrong number of arguments. got=1, want=0"},
+		{"random_int(1)", "Error: wrong number of arguments. got=1, want=2"},
+		{"random_int(1.0, 2)", "Error: argument 1 to `random_int` must be INTEGER, got FLOAT"},
+		{"random_int(2, 1)", "Error: arguments to `random_int` must not be in reverse order, got 2 and 1"},
+		{"choice([])", "Error: argument to `choice` must not be empty"},
+		{"choice(1)", "Error: argument to `choice` must be iterable, got INTEGER"},
+		{"shuffle(0..9223372036854775807)", "Error: argument to `shuffle` is too long, got 9223372036854775807 elements, want at most 16777216"},
+		{`seed("a")`, "Error: argument to `seed` must be INTEGER, got STRING"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestRandomIsPerVM(t *testing.T) {
+	run := func(input string, seed int64) string {
+		comp := compiler.New()
+		if err := comp.Compile(parse(input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+		vm.SetRand(object.NewRand(seed))
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error for %q: %s", input, err)
+		}
+		return vm.LastPoppedStackElem().Inspect()
+	}
+
+	input := "[random(), random_int(1, 100), shuffle(1..=10)]"
+	first := run(input, 42)
+	if second := run(input, 42); second != first {
+		t.Errorf("same seed gave different results. first=%q, second=%q", first, second)
+	}
+	if other := run(input, 43); other == first {
+		t.Errorf("different seeds gave the same result %q", first)
+	}
+
+	// Seeding one VM leaves a VM made before it unchanged
+	comp := compiler.New()
+	if err := comp.Compile(parse("random()")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	other := New(comp.Bytecode())
+	other.SetRand(object.NewRand(1))
+	run("seed(2); random(); random()", 1)
+
+	if err := other.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	want := fmt.Sprintf("%g", object.NewRand(1).Float64())
+	if actual := other.LastPoppedStackElem().Inspect(); actual != want {
+		t.Errorf("seed in another VM changed the result. want=%q, got=%q", want, actual)
+	}
+}
+
+func TestFileSystem(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(outside+"/secret", []byte("s"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, dir+"/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fs.read_file("DIR/a.txt")`, "Error: permission denied: `fs.read_file` needs file system access, run with --allow-fs=DIR"},
+		{`fs.write_file("DIR/a.txt", "one\n")`, "null"},
+		{`fs.append_file("DIR/a.txt", "two\t\"2\"\n")`, "null"},
+		{`fs.read_file("DIR/a.txt")`, "one\ntwo\t\"2\"\n"},
+		{`strings.lines(fs.read_file("DIR/a.txt"))`, "[one, two\t\"2\"]"},
+		{`fs.mkdir(path.join("DIR", "sub", "deep"))`, "null"},
+		{`fs.list_dir("DIR")`, "[a.txt, link, sub]"},
+		{`fs.exists("DIR/sub/deep")`, "true"},
+		{`fs.exists("DIR/nope")`, "false"},
+		{`fs.remove("DIR/sub/deep")`, "null"},
+		{`fs.list_dir("DIR/sub")`, "[]"},
+		{`fs.write_file("DIR/c.json", json_stringify({"n": [1, 2]})); json_parse(fs.read_file("DIR/c.json")).n`, "[1, 2]"},
+		{`fs.read_file("DIR/missing")`, "Error: `fs.read_file` failed for DIR/missing: no such file or directory"},
+		{`fs.remove("DIR/sub/x")`, "Error: `fs.remove` failed for DIR/sub/x: no such file or directory"},
+		{`fs.read_file("DIR/../x")`, "Error: permission denied: DIR/../x is outside the allowed directories"},
+		{`fs.read_file("DIR/link/secret")`, "Error: permission denied: DIR/link/secret is outside the allowed directories"},
+		{`fs.write_file("DIR/link/new", "x")`, "Error: permission denied: DIR/link/new is outside the allowed directories"},
+		{`fs.exists("/")`, "Error: permission denied: / is outside the allowed directories"},
+		{`fs.write_file("DIR/a.txt", 1)`, "Error: argument 2 to `fs.write_file` must be STRING, got INTEGER"},
+		{`fs.mkdir()`, "Error: wrong number of arguments. got=0, want=1"},
+		{`path.join("a", "b/", "../c.txt")`, "a/c.txt"},
+		{`path.base("a/b.txt")`, "b.txt"},
+		{`path.dir("a/b.txt")`, "a"},
+		{`path.ext("a/b.tar.gz")`, ".gz"},
+		{`path.join("a", 1)`, "Error: argument 2 to `path.join` must be STRING, got INTEGER"},
+	}
+
+	defer func() { object.FileSystemRoots = nil }()
+	for i, tt := range tests {
+		if i == 1 {
+			if err := object.AllowFileSystem(dir); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		program := parse(strings.ReplaceAll(tt.input, "DIR", dir))
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		expected := tt.expected
+		if i > 0 {
+			expected = strings.ReplaceAll(expected, "DIR", dir)
+		}
+		if actual != expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, expected, actual)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json_parse("{\"b\": 1, \"a\": [true, null, 1.5]}")`, "{b: 1, a: [true, null, 1.5]}"},
+		{`json_parse("[1, 2.0, 1e2, 123456789012345678901234567890]")`, "[1, 2, 100, 123456789012345678901234567890]"},
+		{`json_parse("2.0") == 2.0`, "true"},
+		{`json_parse("\"a\\nb\"") == "a\nb"`, "true"},
+		{`json_parse("{\"k\": \"v\"}").k`, "v"},
+		{`json_parse("\"\\u00e9\"")`, "é"},
+		{`json_stringify({"a": [1, 2.0, "x"], "b": {}, "c": json_parse("null")})`, `{"a":[1,2.0,"x"],"b":{},"c":null}`},
+		{`json_stringify(["a\nb", 'c', (1, true)])`, `["a\nb","c",[1,true]]`},
+		{`json_stringify([1, {"a": []}], 2)`, "[\n  1,\n  {\n    \"a\": []\n  }\n]"},
+		{`json_stringify([1], "\t")`, "[\n\t1\n]"},
+		{`let s = "{\"a\": [1, {\"b\": \"c\\\"d\"}]}"; json_stringify(json_parse(s)) == strings.replace(s, " ", "")`, "true"},
+		{`json_parse("[1,\n 2,\n x]")`, "Error: invalid JSON at line 3, column 2: unexpected character 'x'"},
+		{`json_parse("{\"a\": 1,}")`, "Error: invalid JSON at line 1, column 9: expected string key, got character '}'"},
+		{`json_parse(1)`, "Error: argument to `json_parse` must be STRING, got INTEGER"},
+		{`json_stringify({1: 2})`, "Error: hash keys must be STRING to encode as JSON, got INTEGER"},
+		{`json_stringify(math.nan)`, "Error: cannot encode NaN as JSON"},
+		{`json_stringify(fn() {})`, "Error: cannot encode CLOSURE as JSON"},
+		{`json_stringify(1, -1)`, "Error: argument 2 to `json_stringify` must not be negative, got -1"},
+		{`json_stringify(1, true)`, "Error: argument 2 to `json_stringify` must be INTEGER or STRING, got BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"math.sqrt(16)", "4"},
+		{"math.sqrt(2.25)", "1.5"},
+		{"math.pow(2, 10)", "1024"},
+		{"math.pow(2, 64)", "18446744073709551616"},
+		{"math.pow(2, -1)", "0.5"},
+		{"math.pow(4.0, 0.5)", "2"},
+		{"math.pow(1, 9223372036854775807)", "1"},
+		{"math.abs(-3)", "3"},
+		{"math.abs(-2.5)", "2.5"},
+		{"math.abs(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"math.floor(2.7)", "2"},
+		{"math.floor(-2.5)", "-3"},
+		{"math.ceil(2.1)", "3"},
+		{"math.round(2.5)", "3"},
+		{"math.round(-2.5)", "-3"},
+		{"math.round(7)", "7"},
+		{"math.floor(100000000000000000000.5)", "100000000000000000000"},
+		{"math.min(3, 1.5, 2)", "1.5"},
+		{"math.max(3, 1.5, 2)", "3"},
+		{"math.max([1, 5, 3])", "5"},
+		{"math.min(1, math.nan)", "NaN"},
+		{"math.clamp(15, 0, 10)", "10"},
+		{"math.clamp(-1, 0, 10)", "0"},
+		{"math.clamp(0.5, 0, 1)", "0.5"},
+		{"math.sin(0)", "0"},
+		{"math.cos(0)", "1"},
+		{"math.atan2(0, -1) == math.pi", "true"},
+		{"math.log(math.e)", "1"},
+		{"math.log10(1000)", "3"},
+		{"math.exp(0)", "1"},
+		{"math.gcd(12, 18)", "6"},
+		{"math.gcd(-4, 6)", "2"},
+		{"math.gcd(0, 0)", "0"},
+		{"math.lcm(4, 6)", "12"},
+		{"math.lcm(-4, 6)", "12"},
+		{"math.lcm(0, 5)", "0"},
+		{"math.pi", "3.141592653589793"},
+		{"-math.inf", "-Inf"},
+		{"math.is_nan(math.nan)", "true"},
+		{"math.is_nan(1)", "false"},
+		{"math.is_inf(-math.inf)", "true"},
+		{"math.is_inf(1)", "false"},
+		{"map([1.2, 2.8], math.round)", "[1, 3]"},
+		{`math.sqrt("a")`, "Error: argument 1 to `math.sqrt` must be INTEGER or FLOAT, got STRING"},
+		{`math.atan2(1, "a")`, "Error: argument 2 to `math.atan2` must be INTEGER or FLOAT, got STRING"},
+		{`math.clamp(1, 2, "a")`, "Error: argument 3 to `math.clamp` must be INTEGER or FLOAT, got STRING"},
+		{"math.clamp(1, 10, 0)", "Error: bounds of `math.clamp` are reversed, got 10 and 0"},
+		{"math.gcd(1.5, 2)", "Error: argument 1 to `math.gcd` must be INTEGER, got FLOAT"},
+		{`math.max([1, "a"])`, "Error: argument 1 to `math.max` must be an ARRAY of INTEGER or FLOAT, got STRING at 1"},
+		{"math.min([])", "Error: `math.min` needs at least one number"},
+		{"math.round(math.inf)", "Error: argument 1 to `math.round` must be finite, got +Inf"},
+		{"math.pow(3, 10000000)", "Error: result of `math.pow` is too large"},
+		{"math.pow(2)", "Error: wrong number of arguments. got=1, want=2"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
 func parse(input string) *ast.Program {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -422,7 +1354,7 @@ func runVMTests(t *testing.T, tests []vmTestCase) {
 
 		vm := New(comp.Bytecode())
 		err = vm.Run()
-		if err != nil {
+		if err != nil && !unhandled(vm, err) {
 			t.Fatalf("vm error: %s", err)
 		}
 
@@ -432,6 +1364,13 @@ func runVMTests(t *testing.T, tests []vmTestCase) {
 	}
 }
 
+// unhandled reports whether the VM stopped on an error value dropped by
+// a statement, the tests read that value like any other result
+func unhandled(vm *VM, err error) bool {
+	errObj, ok := vm.LastPoppedStackElem().(*object.Error)
+	return ok && err.Error() == errObj.Message
+}
+
 func testExpectedObject(
 	t *testing.T,
 	expected interface{},
@@ -483,31 +1422,48 @@ func testExpectedObject(
 			}
 		}
 
-	case map[object.HashKey]int64:
+	case map[object.Hashable]int64:
 		hash, ok := actual.(*object.Hash)
 		if !ok {
 			t.Errorf("object is not Hash. got=%T (%+v)", actual, actual)
 			return
 		}
 
-		if len(hash.Pairs) != len(expected) {
+		if hash.Len() != len(expected) {
 			t.Errorf("hash has wrong number of Pairs. want=%d, got=%d",
-				len(expected), len(hash.Pairs))
+				len(expected), hash.Len())
 			return
 		}
 
 		for expectedKey, expectedValue := range expected {
-			pair, ok := hash.Pairs[expectedKey]
+			value, ok := hash.Get(expectedKey)
 			if !ok {
 				t.Errorf("no pair for given key in Pairs")
 			}
 
-			err := testIntegerObject(expectedValue, pair.Value)
+			err := testIntegerObject(expectedValue, value)
 			if err != nil {
 				t.Errorf("testIntegerObject failed: %s", err)
 			}
 		}
 
+	case *object.Variant:
+		if actual.Inspect() != expected.Inspect() {
+			t.Errorf("object has wrong value. want=%s, got=%s",
+				expected.Inspect(), actual.Inspect())
+		}
+
+	case *object.Range:
+		rangeObj, ok := actual.(*object.Range)
+		if !ok {
+			t.Errorf("object is not Range: %T (%+v)", actual, actual)
+			return
+		}
+		if *rangeObj != *expected {
+			t.Errorf("range has wrong value. want=%s, got=%s",
+				expected.Inspect(), rangeObj.Inspect())
+		}
+
 	case *object.Error:
 		errObj, ok := actual.(*object.Error)
 		if !ok {
@@ -753,6 +1709,297 @@ func TestRecursiveFunctions(t *testing.T) {
 	runVMTests(t, tests)
 }
 
+func TestMutualRecursion(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			input: `
+			fn isEven(n) { if (n == 0) { true } else { isOdd(n - 1) } }
+			fn isOdd(n) { if (n == 0) { false } else { isEven(n - 1) } }
+			[isEven(10), isOdd(7), isEven(100001)] == [true, true, false]
+			`,
+			expected: true,
+		},
+		{
+			input: `
+			fn outer(n) {
+				fn even(n) { if (n == 0) { true } else { odd(n - 1) } }
+				fn odd(n) { if (n == 0) { false } else { even(n - 1) } }
+				even(n)
+			}
+			outer(9)
+			`,
+			expected: false,
+		},
+		{
+			input: `
+			let f = fn(base) {
+				fn ping(n) { if (n == 0) { base } else { pong(n - 1) } }
+				fn pong(n) { if (n == 0) { 0 - base } else { ping(n - 1) } }
+				ping(3)
+			};
+			f(5)
+			`,
+			expected: -5,
+		},
+		{
+			input: `
+			let f = fn() {
+				fn a() { b() + 1 }
+				fn b() { c() }
+				fn c() { 1 }
+				a()
+			};
+			f()
+			`,
+			expected: 2,
+		},
+		{`fn() { let h = [fn() { g() }]; fn g() { 1 } h[0]() }()`, 1},
+		{`fn() { let h = {"k": fn() { g() }}; fn g() { 2 } h["k"]() }()`, 2},
+		{`fn() { let h = push([], fn(x) { g() + x }); fn g() { 2 } h[0](1) }()`, 3},
+		{`fn() { if (false) { let h = fn() { g() }; } fn g() { 4 } g() }()`, 4},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []vmTestCase{
+		{`let count = fn(n) { if (n == 0) { return 0; } count(n - 1) }; count(100000)`, 0},
+		{`let count = fn(n) { if (n > 0) { count(n - 1) } else { "done" } }; count(100000)`, "done"},
+		{`let count = fn(n) { if (n == 0) { return "done"; } return count(n - 1); }; count(100000)`, "done"},
+		{`let count = fn(n) { match (n) { 0 => "done", _ => count(n - 1) } }; count(100000)`, "done"},
+		{`let sum = fn(n, acc) { if (n == 0) { acc } else { $sum(n - 1, acc + n) } }; sum(100000, 0)`, 5000050000},
+		{`let f = fn(a) { len(a) }; f([1, 2])`, 2},
+		{`enum E { A(x) } let f = fn(a) { A(a) }; match (f(3)) { A(x) => x }`, 3},
+		{`let g = fn*() { yield 4 }; let f = fn() { g() }; f()()`, 4},
+		{`let f = fn() { 1 }; let g = fn() { let a = f(); a + 1 }; g()`, 2},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestHashKeys(t *testing.T) {
+	tests := []vmTestCase{
+		{`{1.5: "a"}[1.5]`, "a"},
+		{`{0.0: "zero"}[-0.0]`, "zero"},
+		{`{[1, 2]: "pair"}[[1, 2]]`, "pair"},
+		{`{[1, [2]]: "nested"}[[1, [2]]]`, "nested"},
+		{`{[1, 2]: "pair"}[[2, 1]]`, Null},
+		{`{[][0]: "null"}[{}["missing"]]`, "null"},
+		{`{1: "int", 1.0: "float"}[1.0]`, "float"},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+		{`let f = fn() { 1 }; let g = fn() { 2 }; {[f]: "f", [g]: "g"}[[g]]`, "g"},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestHashInsertionOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"b": 1, "a": 2, 3: 4}`, "{b: 1, a: 2, 3: 4}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+		{`{[1]: 1.5, [][0]: true}`, "{[1]: 1.5, null: true}"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong hash for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestTuplesAndSets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(1, "a")`, "(1, a)"},
+		{"(1,)", "(1,)"},
+		{"()", "()"},
+		{"(1)", "1"},
+		{"(1, 2, 3)[1:]", "(2, 3)"},
+		{"let (a, b) = (1, 2); [b, a]", "[2, 1]"},
+		{"fn() { let (a, b) = [3, 4]; a * b }()", "12"},
+		{"{(1, 2): 3}[(1, 2)]", "3"},
+		{"#{1, 2, 2, 3}", "#{1, 2, 3}"},
+		{"#{}", "#{}"},
+		{"len(#{1, 1})", "1"},
+		{"#{1, 2} | #{2, 3}", "#{1, 2, 3}"},
+		{"#{1, 2} & #{2, 3}", "#{2}"},
+		{"#{1, 2} - #{2, 3}", "#{1}"},
+		{"#{1, 2} == #{2, 1}", "true"},
+		{"#{(1, 2), [3]}", "#{(1, 2), [3]}"},
+		{"2 in #{1, 2}", "true"},
+		{"(1, 2) in #{(1, 2)}", "true"},
+		{"3 in [1, 2]", "false"},
+		{"2 in (1, 2)", "true"},
+		{`"a" in {"a": 1}`, "true"},
+		{`"ell" in "hello"`, "true"},
+		{"3 in 1..3", "false"},
+		{"3 in 1..=3", "true"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestIndexAssignmentAndFreeze(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1, 2, 3]; a[0] = 4; a[-1] = 5; a", "[4, 2, 5]"},
+		{`let h = {"a": 1}; h["b"] = 2; h["a"] = 3; h`, "{a: 3, b: 2}"},
+		{"let a = [[1]]; let b = a[0]; b[0] = 2; a", "[[2]]"},
+		{"fn set(a) { a[0] = 0; } let a = [1]; set(a); a", "[0]"},
+		{"const a = [1, [2]]; a == [1, [2]]", "true"},
+		{"let a = [1]; let b = freeze(a); b == a", "true"},
+		{"let a = [1]; a[0] = a[:]; a", "[[1]]"},
+		{"let a = [1]; if (true) { a[0] = 2; } a", "[2]"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil && !unhandled(vm, err) {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		actual := vm.LastPoppedStackElem().Inspect()
+		if actual != tt.expected {
+			t.Errorf("wrong result for %q. want=%q, got=%q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestStructuralEquality(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5 == 1.5", true},
+		{"let a = 1.5; let b = 3.0 / 2.0; a == b", true},
+		{"1.5 != 2.5", true},
+		{"2.5 > 1.5", true},
+		{"1.5 < 2.5", true},
+		{"[1, 2] == [1, 2]", true},
+		{"[1, [2, 3]] == [1, [2, 3]]", true},
+		{"[1, 2] == [1, 3]", false},
+		{"[1, 2] != [1, 2, 3]", true},
+		{"[1, 2] < [1, 3]", true},
+		{"[1, 2] < [1, 2, 0]", true},
+		{`["b"] > ["a", "z"]`, true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"a": 1, "b": 2}`, false},
+		{"[][0] == {}[0]", true},
+		{"[][0] == false", false},
+		{"(1 < 2) == true", true},
+		{`"b" > "a"`, true},
+		{"1..3 == 1..=2", true},
+		{"enum E { A(x), B } A([1]) == A([1])", true},
+		{"enum E { A(x), B } A(1) == A(2)", false},
+		{"enum E { A(x), B } A(1) != B", true},
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
+		{`match ([1, 2]) { [1, 2] => "pair", _ => "other" }`, "pair"},
+		{`match (2.5) { 1.5 => "low", 2.5 => "high", _ => "none" }`, "high"},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []vmTestCase{
+		{"string(9223372036854775807 + 1)", "9223372036854775808"},
+		{"string(-9223372036854775807 - 2)", "-9223372036854775809"},
+		{"string(4611686018427387904 * 4)", "18446744073709551616"},
+		{"string(-(-9223372036854775807 - 1))", "9223372036854775808"},
+		{"string(99999999999999999999)", "99999999999999999999"},
+		{"99999999999999999999 - 99999999999999999998", 1},
+		{"-9223372036854775808", -9223372036854775808},
+		{"9223372036854775808 > 9223372036854775807", true},
+		{"(9223372036854775807 + 1) - 1", 9223372036854775807},
+		{"(9223372036854775807 + 1) / (9223372036854775807 + 1)", 1},
+		{"9223372036854775807 + 1 > 9223372036854775807", true},
+		{"9223372036854775807 < 9223372036854775807 * 2", true},
+		{"9223372036854775807 + 1 == 9223372036854775807 + 1", true},
+		{"9223372036854775807 + 1 != 9223372036854775807 + 2", true},
+		{`string(int("100000000000000000000") / 10)`, "10000000000000000000"},
+		{`int("100000000000000000000") == int("100000000000000000000")`, true},
+		{`{9223372036854775807 + 1: "big"}[9223372036854775807 + 1]`, "big"},
+		{"let fact = fn(n, acc) { if (n == 0) { return acc; } fact(n - 1, acc * n) }; string(fact(25, 1))",
+			"15511210043330985984000000"},
+	}
+
+	runVMTests(t, tests)
+}
+
+func TestPipelinesAndPartials(t *testing.T) {
+	funcs := `
+	let add = fn(a, b) { a + b };
+	let double = fn(x) { x * 2 };
+	`
+
+	tests := []vmTestCase{
+		{funcs + "[1, 2] |> push(_, 4)", []int{1, 2, 4}},
+		{funcs + "5 |> double", 10},
+		{funcs + "5 |> fn(x) { x - 1 }", 4},
+		{funcs + "let inc = add(1, _); inc(10)", 11},
+		{funcs + "add(_, _)(2, 3)", 5},
+		{funcs + "3 |> add(10, _) |> double", 26},
+		{funcs + "let f = add(1, _) >> double; f(2)", 6},
+		{funcs + "(double >> add(1, _))(2)", 5},
+		{funcs + "let _0 = 5; let _2 = fn(x) { x + _0 }; let f = add(_0, _) >> _2; f(1)", 11},
+		{funcs + "5 |> double >> double >> double", 40},
+		{funcs + "let mut n = 0; let next = fn() { n = n + 1; n }; let f = add(next(), _); f(0); f(0)", 1},
+		{"push(_, 9)([1])", []int{1, 9}},
+	}
+
+	runVMTests(t, tests)
+}
+
 func TestRecursiveFibonacci(t *testing.T) {
 	tests := []vmTestCase{
 		{
@@ -821,3 +2068,211 @@ func testStringObject(expected string, actual object.Object) error {
 
 	return nil
 }
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input   string
+		kind    ErrorKind
+		message string
+	}{
+		{"1 / 0", ZeroDivision, "division by zero"},
+		{"let zero = 0; 10 / zero", ZeroDivision, "division by zero"},
+		{"(9223372036854775807 + 1) / 0", ZeroDivision, "division by zero"},
+		{"let f = fn(n) { 1 + f(n) }; f(1)", StackOverflow, "stack overflow"},
+		{"let f = fn(n) { [f(n)] }; f(1)", StackOverflow, "stack overflow"},
+		{`1 > "a"`, TypeError, "unknown operator: INTEGER > STRING"},
+		{"true > false", TypeError, "unknown operator: BOOLEAN > BOOLEAN"},
+		{"{} > {}", TypeError, "unknown operator: HASH > HASH"},
+		{"{{}: 1}", TypeError, "unusable as hash key: HASH"},
+		{`"a" > 1`, TypeError, "unknown operator: STRING > INTEGER"},
+		{`1 + "a"`, TypeError, "unsupported types for binary operation: INTEGER STRING"},
+		{`-"a"`, TypeError, "unsupported type for negation: STRING"},
+		{"1(2)", TypeError, "calling non-closure and non-builtin"},
+		{"fn(a) { a }()", TypeError, "wrong number of arguments: want=1, got=0"},
+		{"if (false) { let y = 1; }; y", RuntimeError, "variable used before it is set"},
+		{"fn() { if (false) { let y = 1; }; y }()", RuntimeError, "variable used before it is set"},
+		{"fn first() { second() } first(); fn second() { 1 }", RuntimeError, "variable used before it is set"},
+		{"let x = x + 1;", RuntimeError, "variable used before it is set"},
+		{"fn() { let h = [fn() { g() }]; h[0](); fn g() { 1 } }()", RuntimeError, "variable used before it is set"},
+		{`len(1); say("after")`, RuntimeError, "argument to `len` not supported, got INTEGER"},
+		{`exit(300); say("after")`, RuntimeError, "argument to `exit` must be an INTEGER from 0 to 255, got 300"},
+		{"fn() { push(1, 2); 3 }()", RuntimeError, "argument to `push` must be ARRAY, got INTEGER"},
+		{"fn() { let y = [y]; }()", RuntimeError, "variable used before it is set"},
+		{"#{{}}", TypeError, "unusable as set element: HASH"},
+		{"let (a, b) = (1, 2, 3);", TypeError, "cannot destructure TUPLE of 3 elements into 2 names"},
+		{"let (a) = 1;", TypeError, "cannot destructure INTEGER"},
+		{"1 in 2", TypeError, "unsupported types for in: INTEGER in INTEGER"},
+		{`1 in "a"`, TypeError, "unsupported types for in: INTEGER in STRING"},
+		{"#{1} + #{2}", TypeError, "unknown set operator: +"},
+		{"1 | 2", TypeError, "unknown integer operator: |"},
+		{"map([1, 0], fn(x) { 1 / x })", ZeroDivision, "division by zero"},
+		{"time.seconds(1) / 0", ZeroDivision, "division by zero"},
+		{"time.seconds(1) / time.ms(0)", ZeroDivision, "division by zero"},
+		{"time.hours(2000000) + time.hours(2000000)", RuntimeError, "duration overflow"},
+		{"time.hours(2000000) * 2", RuntimeError, "duration overflow"},
+		{"now() + 1", TypeError, "unsupported types for binary operation: TIME INTEGER"},
+		{"now() + now()", TypeError, "unsupported types for binary operation: TIME TIME"},
+		{"now() > time.ms(1)", TypeError, "unknown operator: TIME > DURATION"},
+		{"map([1], fn(a, b) { a })", TypeError, "wrong number of arguments: want=2, got=1"},
+		{"let f = fn(n) { map([n], f) }; f(1)", StackOverflow, "stack overflow"},
+		{"strings.nope", RuntimeError, "module strings has no member nope"},
+		{`re("a").nope`, RuntimeError, "regex has no method nope"},
+		{`re("a")[0]`, TypeError, "regex method must be STRING, got INTEGER"},
+		{"strings[1]", TypeError, "module member must be STRING, got INTEGER"},
+		{`strings["upper"] = 1;`, TypeError, "index assignment not supported: MODULE"},
+		{"const a = [1]; a[0] = 2;", RuntimeError, "cannot change frozen ARRAY"},
+		{"const h = {1: [2]}; let a = h[1]; a[0] = 3;", RuntimeError, "cannot change frozen ARRAY"},
+		{"let k = [1]; let h = {k: 1}; k[0] = 2;", RuntimeError, "cannot change frozen ARRAY"},
+		{`let h = freeze({"a": 1}); h["b"] = 2;`, RuntimeError, "cannot change frozen HASH"},
+		{"let a = [1]; a[1] = 2;", RuntimeError, "index out of range: 1"},
+		{"let a = [1]; a[0] = [a];", RuntimeError, "cannot store ARRAY inside itself"},
+		{"let h = {}; h[1] = [h];", RuntimeError, "cannot store HASH inside itself"},
+		{`let a = [1]; a["x"] = 2;`, TypeError, "index must be INTEGER, got STRING"},
+		{"let h = {}; h[[]] = 1; h[{}] = 2;", TypeError, "unusable as hash key: HASH"},
+		{`let s = "a"; s[0] = "b";`, TypeError, "index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err == nil {
+			t.Errorf("expected VM error for %q but resulted in none", tt.input)
+			continue
+		}
+
+		vmErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("error is not *Error for %q. got=%T (%s)", tt.input, err, err)
+			continue
+		}
+		if vmErr.Kind != tt.kind {
+			t.Errorf("wrong error kind for %q. want=%s, got=%s", tt.input, tt.kind, vmErr.Kind)
+		}
+		if vmErr.Message != tt.message {
+			t.Errorf("wrong VM error for %q. want=%q, got=%q", tt.input, tt.message, vmErr.Message)
+		}
+	}
+}
+
+func TestMaxCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxCalls int
+		expected string
+	}{
+		{"let f = fn(n) { f(n + 1) }; f(0)", 100, "call limit of 100 exceeded"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(10)", 11, ""},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(10)", 10, "call limit of 10 exceeded"},
+		{"map(1..=5, fn(x) { x })", 4, "call limit of 4 exceeded"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		vm.SetMaxCalls(tt.maxCalls)
+		err = vm.Run()
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("unexpected VM error for %q: %s", tt.input, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong VM error for %q. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestComparingMixedTypes(t *testing.T) {
+	tests := []vmTestCase{
+		{`1 == "a"`, false},
+		{`"a" == 1`, false},
+		{`true == "a"`, false},
+		{`"a" == true`, false},
+		{`1 != "a"`, true},
+		{`(9223372036854775807 + 1) == "a"`, false},
+	}
+
+	runVMTests(t, tests)
+}
+
+func FuzzVM(f *testing.F) {
+	seeds := []string{
+		"1 + 2 * 3 - 4 / 2",
+		`let a = [1, "two", true]; a[1:] + a[-1:]`,
+		`let h = {"a": 1, 2: [3]}; h["a"] ?? h?[2]?[0]`,
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10)",
+		"let count = fn(n) { if (n == 0) { return 0; } count(n - 1) }; count(100)",
+		"enum E { A(x), B } match (A(1)) { A(x) => x, B => 0 }",
+		"fn* g(i) { yield i; $g(i + 1) } let it = g(0); it(); it(5); done(it)",
+		"let add = fn(a, b) { a + b }; [1, 2] |> push(_, 3) |> len |> add(1, _)",
+		`len(1..=10); string(9223372036854775807 * 3); int("12") + float("1.5")`,
+		`say("a\n", 1, [2]); head(tail([1, 2, 3]))`,
+		"fn f() { fn a(n) { if (n > 0) { b(n - 1) } else { 0 } } fn b(n) { a(n) } a(3) } f()",
+		`len("héllo"[1:]); 'é' < 'z'; string(bytes("é")[0:1]); char(int('a') + 1)`,
+		`let (a, b) = (1, #{2}); b | #{a} - #{3} & #{2}; (a,) in #{(1,)}`,
+		`const c = [1, {"a": [2]}]; let a = [c]; a[0] = freeze([a[:]]); c[1]["a"]`,
+		"reduce(map(1..5, fn(x) { [x] }), fn(a, x) { a + x[0] }, 0); sort_by([2, 1], fn(x) { -x })",
+		`let r = re("(?P<k>\\w+)=(\\d+)"); r.replace("a=1 b=2", fn(m) { m[2] + m[1] }) + r.find_all("c=3")[0] + r.named("d=4").k`,
+		`json_stringify(json_parse("{\"a\": [1, 2.5, \"x\\n\", null, {}]}"), 2)`,
+		`format("%-5s|%6.2f|%x|%v", "a", 1.5, 255, [1, {"k": 'c'}]) + format("%d%%", 3)`,
+		"math.clamp(math.pow(2, 70), math.min(1, 2.5), math.max([3])) + math.gcd(6, 4) + math.round(math.sqrt(2))",
+		`strings.join(strings.split(strings.pad_left(strings.trim(" a,b "), 6, '.'), ","), "é") |> strings.upper`,
+		`let t = now(); sleep(time.seconds(1.5)); [since(t) / 2, -time.ms(1) * 3, t + time.hours(1) > t, time.format(t, "%Y-%j %p")]`,
+		"seed(5); [random(), random_int(-3, 3), choice((1, 2)), shuffle(1..=4)]",
+		"!> >> 0",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		f.Fatalf("could not open %s: %s", os.DevNull, err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	f.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+	object.Scanner = bufio.NewScanner(strings.NewReader(""))
+	object.CurrentClock = object.NewFakeClock(time.Unix(0, 0))
+	defer func() { object.CurrentClock = object.SystemClock{} }()
+
+	f.Fuzz(func(t *testing.T, input string) {
+		l := lexer.New(input)
+		p := parser.New(l)
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			return
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return
+		}
+
+		vm := New(comp.Bytecode())
+		vm.SetMaxCalls(100000)
+		err := vm.Run()
+		if vmErr, ok := err.(*Error); ok && vmErr.Kind == InternalError {
+			t.Fatalf("VM fault for %q: %s", input, vmErr.Message)
+		}
+	})
+}